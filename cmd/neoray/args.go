@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,6 +15,7 @@ import (
 
 	"github.com/hismailbulut/Neoray/pkg/bench"
 	"github.com/hismailbulut/Neoray/pkg/fontfinder"
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
 	"github.com/olekukonko/tablewriter"
 	"github.com/sqweek/dialog"
@@ -41,8 +45,18 @@ Options:
 	Connect to existing neovim instance
 --multigrid
 	Enables multigrid support (experimental)
---list-fonts <file>
-	Lists all fonts and writes them to <file>
+--list-fonts [file]
+	Lists all fonts and writes them to [file] or stdout
+--format <json|csv|table>
+	Output format of the font list, default is table
+--monospace-only
+	Lists only monospaced fonts
+--family <name>
+	Lists only fonts whose family contains <name>
+--covers <codepoint>
+	Lists only fonts that have the glyph, like U+4E00 or U+2500-U+257F
+--rebuild-font-cache
+	Scans all fonts again and rebuilds the font cache
 --nofork
 	Do not detach process from terminal
 --version, -v
@@ -63,30 +77,46 @@ var ALLOWEDOS = func() bool {
 }()
 
 type ParsedArgs struct {
-	file       string
-	line       int
-	column     int
-	singleInst bool
-	execPath   string
-	address    string
-	multiGrid  bool
-	nofork     bool
-	others     []string
+	file         string
+	line         int
+	column       int
+	singleInst   bool
+	execPath     string
+	address      string
+	multiGrid    bool
+	nofork       bool
+	rebuildFonts bool
+	listFonts    bool
+	fontList     FontListOptions
+	others       []string
+}
+
+type FontListOptions struct {
+	file          string // Empty for stdout
+	format        string
+	monospaceOnly bool
+	family        string
+	covers        [][2]rune
 }
 
 // Last boolean value specifies if we should quit after parsing
 func ParseArgs(args []string) (ParsedArgs, error, bool) {
 	// Init defaults
 	options := ParsedArgs{
-		file:       "",
-		line:       -1,
-		column:     -1,
-		singleInst: false,
-		execPath:   "nvim",
-		address:    "",
-		multiGrid:  false,
-		nofork:     false,
-		others:     []string{},
+		file:         "",
+		line:         -1,
+		column:       -1,
+		singleInst:   false,
+		execPath:     "nvim",
+		address:      "",
+		multiGrid:    false,
+		nofork:       false,
+		rebuildFonts: false,
+		listFonts:    false,
+		fontList: FontListOptions{
+			format: "table",
+		},
+		others: []string{},
 	}
 	var err error
 	for i := 0; i < len(args); i++ {
@@ -137,12 +167,43 @@ func ParseArgs(args []string) (ParsedArgs, error, bool) {
 		case "--multigrid":
 			options.multiGrid = true
 		case "--list-fonts":
+			options.listFonts = true
+			// File name is optional
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				options.fontList.file = args[i+1]
+				i++
+			}
+		case "--format":
 			if i+1 >= len(args) {
-				return options, errors.New("specify file name after --list-fonts"), false
+				return options, errors.New("specify format after --format"), false
 			}
-			fileName := args[i+1]
-			ListFonts(fileName)
-			return options, nil, true
+			switch args[i+1] {
+			case "json", "csv", "table":
+				options.fontList.format = args[i+1]
+			default:
+				return options, errors.New("invalid format, must be json, csv or table"), false
+			}
+			i++
+		case "--monospace-only":
+			options.fontList.monospaceOnly = true
+		case "--family":
+			if i+1 >= len(args) {
+				return options, errors.New("specify family name after --family"), false
+			}
+			options.fontList.family = args[i+1]
+			i++
+		case "--covers":
+			if i+1 >= len(args) {
+				return options, errors.New("specify code point after --covers"), false
+			}
+			first, last, err := fontkit.ParseRange(args[i+1])
+			if err != nil {
+				return options, err, false
+			}
+			options.fontList.covers = append(options.fontList.covers, [2]rune{first, last})
+			i++
+		case "--rebuild-font-cache":
+			options.rebuildFonts = true
 		case "--nofork":
 			options.nofork = true
 		case "--version", "-v":
@@ -155,6 +216,14 @@ func ParseArgs(args []string) (ParsedArgs, error, bool) {
 			options.others = append(options.others, args[i])
 		}
 	}
+	if options.listFonts {
+		// Scripts read the list from stdout, logs must not be mixed with it
+		logger.SetOutput(os.Stderr)
+		if options.rebuildFonts {
+			fontfinder.RebuildCache()
+		}
+		return options, ListFonts(options.fontList), true
+	}
 	return options, nil, options.Fork()
 }
 
@@ -173,25 +242,113 @@ func PrintHelp() {
 	dialog.Message(msg).Title("Help").Info()
 }
 
-func ListFonts(fileName string) {
-	fontList := fontfinder.List()
-	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+// Font list entry, also used as json object
+type fontListEntry struct {
+	Family    string `json:"family"`
+	Style     string `json:"style"`
+	Name      string `json:"name"`
+	Filename  string `json:"filename"`
+	Index     int    `json:"index"`
+	Weight    int    `json:"weight"`
+	Italic    bool   `json:"italic"`
+	Monospace bool   `json:"monospace"`
+}
+
+func (entry fontListEntry) strings() []string {
+	return []string{
+		entry.Family,
+		entry.Style,
+		strconv.Itoa(entry.Weight),
+		strconv.FormatBool(entry.Italic),
+		strconv.FormatBool(entry.Monospace),
+		entry.Filename,
+		strconv.Itoa(entry.Index),
+		entry.Name,
+	}
+}
+
+var fontListHeader = []string{"Family", "Style", "Weight", "Italic", "Monospace", "Filename", "Index", "Name"}
+
+// Returns the fonts matching all of the filters.
+func (options FontListOptions) filter(fonts []*fontfinder.FontInfo) []fontListEntry {
+	family := strings.ToLower(options.family)
+	entries := []fontListEntry{}
+	for _, font := range fonts {
+		if options.monospaceOnly && !font.Monospace {
+			continue
+		}
+		if family != "" && !strings.Contains(strings.ToLower(font.Family), family) {
+			continue
+		}
+		covers := true
+		for _, r := range options.covers {
+			if !font.Covers(r[0], r[1]) {
+				covers = false
+				break
+			}
+		}
+		if !covers {
+			continue
+		}
+		entries = append(entries, fontListEntry{
+			Family:    font.Family,
+			Style:     font.Style,
+			Name:      font.Name,
+			Filename:  font.Filename,
+			Index:     font.Index,
+			Weight:    font.Weight,
+			Italic:    font.Italic,
+			Monospace: font.Monospace,
+		})
+	}
+	return entries
+}
+
+// Writes the system fonts to stdout or file in the requested format.
+func ListFonts(options FontListOptions) error {
+	entries := options.filter(fontfinder.List())
+	var out io.Writer = os.Stdout
+	if options.file != "" {
+		file, err := os.OpenFile(options.file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return fmt.Errorf("could not open %s for writing", options.file)
+		}
+		defer file.Close()
+		out = file
+	}
+	var err error
+	switch options.format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write(fontListHeader)
+		for _, entry := range entries {
+			writer.Write(entry.strings())
+		}
+		writer.Flush()
+		err = writer.Error()
+	default:
+		fmt.Fprintln(out, "Autogenerated by Neoray")
+		fmt.Fprintf(out, "Total of %d fonts found in your system\n", len(entries))
+		fmt.Fprintln(out, "You can use both family name, filename and font name in order to find the font you want")
+		table := tablewriter.NewWriter(out)
+		table.SetAutoWrapText(false)
+		table.SetHeader(fontListHeader)
+		for _, entry := range entries {
+			table.Append(entry.strings())
+		}
+		table.Render()
+	}
 	if err != nil {
-		logger.Log(logger.FATAL, "Could not open", fileName, "for writing")
+		return err
 	}
-	defer file.Close()
-	file.WriteString("Autogenerated by Neoray\n")
-	fmt.Fprintf(file, "Total of %d fonts found in your system\n", len(fontList))
-	file.WriteString("You can use both family name, filename and font name in order to find the font you want\n")
-	// Create a table and write to file
-	table := tablewriter.NewWriter(file)
-	table.SetAutoWrapText(false)
-	table.SetHeader([]string{"Family", "Filename", "Name"})
-	for _, font := range fontList {
-		table.Append([]string{font.Family, font.Filename, font.Name})
+	if options.file != "" {
+		logger.Log(logger.TRACE, "Font list written to", options.file)
 	}
-	table.Render()
-	logger.Log(logger.TRACE, "Font list written to", fileName)
+	return nil
 }
 
 // detach from terminal.
@@ -219,6 +376,9 @@ func (options ParsedArgs) Fork() bool {
 
 // Call this before starting neovim.
 func (options ParsedArgs) ProcessBefore() bool {
+	if options.rebuildFonts {
+		fontfinder.RebuildCache()
+	}
 	if options.singleInst {
 		// First we will check only once because sending and
 		// waiting http requests will make neoray opens slower.
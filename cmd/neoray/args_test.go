@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/bench"
+	"github.com/hismailbulut/Neoray/pkg/logger"
+)
+
+// Returns everything written to stdout while the function runs.
+func captureStdout(t *testing.T, f func()) []byte {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- data
+	}()
+	f()
+	os.Stdout = stdout
+	writer.Close()
+	return <-output
+}
+
+func TestListFontsOutputParses(t *testing.T) {
+	defer logger.SetOutput(os.Stdout)
+	for _, format := range []string{"json", "csv"} {
+		logger.SetOutput(os.Stdout)
+		output := captureStdout(t, func() {
+			// Colored logs like the main and a log after the listing
+			logger.Init(NAME, logger.Version{}, bench.BUILD_TYPE, true)
+			_, err, quit := ParseArgs([]string{"--list-fonts", "--format", format})
+			if err != nil || !quit {
+				t.Errorf("%s: listing returned %v, quit %t", format, err, quit)
+			}
+			logger.Log(logger.WARN, "Log after the font list")
+			logger.Shutdown()
+		})
+		switch format {
+		case "json":
+			var entries []fontListEntry
+			if err := json.Unmarshal(output, &entries); err != nil {
+				t.Errorf("json output doesn't parse: %v\n%q", err, output)
+			}
+		case "csv":
+			records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+			if err != nil {
+				t.Errorf("csv output doesn't parse: %v\n%q", err, output)
+				continue
+			}
+			if len(records) == 0 || len(records[0]) != len(fontListHeader) || records[0][0] != fontListHeader[0] {
+				t.Errorf("csv output doesn't start with the header: %q", output)
+			}
+		}
+	}
+}
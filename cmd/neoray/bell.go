@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/hismailbulut/Neoray/pkg/opengl"
+	"github.com/hismailbulut/Neoray/pkg/window"
+)
+
+// Bells received in this interval after the last one are ignored. Some
+// plugins and mappings may ring the bell hundreds of times in a second.
+const BELL_MIN_INTERVAL = 100 * time.Millisecond
+
+// Default flash opacity when the user didn't specified a color.
+const BELL_FLASH_ALPHA = 0.25
+
+type Bell struct {
+	last       time.Time // Time of the last handled bell
+	flashTime  float32   // Remaining flash time in seconds
+	cmdRunning bool      // Command hook is still running
+	cmdDone    chan error
+	buffer     *opengl.VertexBuffer
+}
+
+func NewBell(window *window.Window) *Bell {
+	bell := new(Bell)
+	bell.cmdDone = make(chan error, 1)
+	bell.buffer = window.GL().CreateVertexBuffer(1)
+	return bell
+}
+
+// Parses color in form of '#rrggbb' or '#rrggbbaa'
+func parseBellColor(str string) (common.Color, bool) {
+	str = strings.TrimPrefix(str, "#")
+	if len(str) != 6 && len(str) != 8 {
+		return common.ZeroColor, false
+	}
+	value, err := strconv.ParseUint(str, 16, 32)
+	if err != nil {
+		return common.ZeroColor, false
+	}
+	if len(str) == 6 {
+		return common.ColorFromUint(uint32(value)), true
+	}
+	color := common.ColorFromUint(uint32(value >> 8))
+	color.A = float32(value&0xff) / 255
+	return color, true
+}
+
+// Ring is called for both bell and visual_bell events. Visual bells only
+// flash the screen, audible bells also run the command hook and request
+// attention when the window isn't focused.
+func (bell *Bell) Ring(visual bool) {
+	now := time.Now()
+	if now.Sub(bell.last) < BELL_MIN_INTERVAL {
+		return
+	}
+	bell.last = now
+	if visual {
+		bell.flash()
+		return
+	}
+	if Editor.options.bellCommand == "" {
+		// There is no way to make a sound without a command, flash instead
+		bell.flash()
+	}
+	if Editor.options.bellAttention && !Editor.window.IsFocused() {
+		Editor.window.RequestAttention()
+	}
+	bell.runCommand()
+}
+
+func (bell *Bell) flash() {
+	if Editor.options.bellFlashTime <= 0 {
+		return
+	}
+	bell.flashTime = Editor.options.bellFlashTime
+	MarkRender()
+}
+
+func (bell *Bell) runCommand() {
+	if Editor.options.bellCommand == "" || bell.cmdRunning {
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", Editor.options.bellCommand)
+	} else {
+		cmd = exec.Command("sh", "-c", Editor.options.bellCommand)
+	}
+	err := cmd.Start()
+	if err != nil {
+		logger.Log(logger.ERROR, "Failed to run bell command:", err)
+		return
+	}
+	bell.cmdRunning = true
+	go func() {
+		bell.cmdDone <- cmd.Wait()
+	}()
+}
+
+func (bell *Bell) Update(delta float32) {
+	select {
+	case err := <-bell.cmdDone:
+		bell.cmdRunning = false
+		if err != nil {
+			logger.Log(logger.WARN, "Bell command failed:", err)
+		}
+	default:
+	}
+	if bell.flashTime > 0 {
+		bell.flashTime -= delta
+		// Render one more time when the flash is finished to clear it
+		MarkRender()
+	}
+}
+
+func (bell *Bell) Render() {
+	if bell.flashTime <= 0 {
+		return
+	}
+	color := Editor.options.bellFlashColor
+	if color == common.ZeroColor {
+		color = Editor.gridManager.foreground
+		color.A = BELL_FLASH_ALPHA
+	}
+	// Fade out
+	color.A *= common.Clamp(bell.flashTime/Editor.options.bellFlashTime, 0, 1)
+	bell.buffer.SetIndexPos(0, Editor.window.Viewport().ToF32())
+	bell.buffer.SetIndexBg(0, color)
+	Editor.window.GL().EnableBlending()
+	bell.buffer.Bind()
+	bell.buffer.Update()
+	bell.buffer.Render()
+	Editor.window.GL().DisableBlending()
+}
+
+func (bell *Bell) Destroy() {
+	bell.buffer.Destroy()
+	logger.Log(logger.DEBUG, "Bell destroyed")
+}
@@ -1,23 +1,65 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/hismailbulut/Neoray/pkg/bench"
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/mattn/go-runewidth"
 	"github.com/sqweek/dialog"
 )
 
+const (
+	// Highlight groups used for coloring the context menu. If the user
+	// doesn't define them we use reversed colors of the default grid.
+	HL_GROUP_MENU          = "NeorayMenu"
+	HL_GROUP_MENU_SEL      = "NeorayMenuSel"
+	HL_GROUP_MENU_DISABLED = "NeorayMenuDisabled"
+	// Characters used for drawing menu
+	CONTEXT_MENU_SEPARATOR_CHAR = '─'
+	CONTEXT_MENU_SUBMENU_CHAR   = '▸'
+)
+
 type ContextButton struct {
-	name string
-	fn   func()
+	name      string
+	fn        func()
+	enabled   func() bool     // Button is always enabled if this is nil
+	submenu   []ContextButton // Non empty submenu opens a new menu instead of calling fn
+	separator bool            // Separators are not selectable and only draws a line
+}
+
+// Returns a separator line for the context menu
+func ContextSeparator() ContextButton {
+	return ContextButton{separator: true}
+}
+
+func (button ContextButton) IsEnabled() bool {
+	if button.separator {
+		return false
+	}
+	if button.enabled != nil {
+		return button.enabled()
+	}
+	return true
+}
+
+func (button ContextButton) HasSubmenu() bool {
+	return len(button.submenu) > 0
+}
+
+// Cut and copy only makes sense when there is a selection
+func isVisualMode() bool {
+	return strings.HasPrefix(Editor.cursor.mode.current_mode_name, "visual")
 }
 
 // You can add more buttons here.
 var ContextMenuButtons = []ContextButton{
 	{
-		name: "Cut",
+		name:    "Cut",
+		enabled: isVisualMode,
 		fn: func() {
 			text := Editor.nvim.Cut()
 			if text != "" {
@@ -26,7 +68,8 @@ var ContextMenuButtons = []ContextButton{
 		},
 	},
 	{
-		name: "Copy",
+		name:    "Copy",
+		enabled: isVisualMode,
 		fn: func() {
 			text := Editor.nvim.Copy()
 			if text != "" {
@@ -46,6 +89,7 @@ var ContextMenuButtons = []ContextButton{
 			Editor.nvim.SelectAll()
 		},
 	},
+	ContextSeparator(),
 	{
 		name: "Open File",
 		fn: func() {
@@ -58,12 +102,82 @@ var ContextMenuButtons = []ContextButton{
 	},
 }
 
+// Adds button to the buttons, path is the names of the submenus which the
+// button will be added. Submenus are created if they are not exist.
+func addContextButton(buttons []ContextButton, path []string, button ContextButton) []ContextButton {
+	if len(path) == 0 {
+		return append(buttons, button)
+	}
+	for i := range buttons {
+		if !buttons[i].separator && buttons[i].name == path[0] {
+			buttons[i].submenu = addContextButton(buttons[i].submenu, path[1:], button)
+			return buttons
+		}
+	}
+	return append(buttons, ContextButton{
+		name:    path[0],
+		submenu: addContextButton(nil, path[1:], button),
+	})
+}
+
+type contextMenuColors struct {
+	normal   HighlightAttribute
+	selected HighlightAttribute
+	disabled HighlightAttribute
+}
+
+// Loads menu colors from the highlight groups. Every color not defined in
+// highlight groups will be the reversed color of the default grid.
+func loadContextMenuColors() contextMenuColors {
+	colors := contextMenuColors{
+		normal: HighlightAttribute{
+			foreground: Editor.gridManager.background,
+			background: Editor.gridManager.foreground,
+			bold:       true,
+		},
+		selected: HighlightAttribute{
+			foreground: Editor.gridManager.foreground,
+			background: Editor.gridManager.background,
+			bold:       true,
+		},
+	}
+	apply := func(attrib *HighlightAttribute, group string) {
+		hl, ok := Editor.nvim.HighlightByName(group)
+		if !ok {
+			return
+		}
+		if hl.reverse {
+			hl.foreground, hl.background = hl.background, hl.foreground
+		}
+		if hl.foreground.A > 0 {
+			attrib.foreground = hl.foreground
+		}
+		if hl.background.A > 0 {
+			attrib.background = hl.background
+		}
+		attrib.bold = hl.bold
+		attrib.italic = hl.italic
+	}
+	apply(&colors.normal, HL_GROUP_MENU)
+	apply(&colors.selected, HL_GROUP_MENU_SEL)
+	// Disabled buttons are faded to the background by default
+	colors.disabled = colors.normal
+	colors.disabled.foreground = colors.normal.foreground.Mix(colors.normal.background, 0.5)
+	apply(&colors.disabled, HL_GROUP_MENU_DISABLED)
+	return colors
+}
+
 type ContextMenu struct {
 	pos        common.Vector2[int]
 	hidden     bool
+	buttons    []ContextButton
+	enabled    []bool // Enabled state of the buttons, evaluated when the menu is shown
 	rows, cols int
 	cells      [][]rune
-	hlRow      int // Highlighted row index, -1 if none
+	hlRow      int          // Highlighted row index, -1 if none
+	parent     *ContextMenu // Parent menu if this is a submenu
+	child      *ContextMenu // Currently open submenu
+	colors     contextMenuColors
 	renderer   *GridRenderer
 }
 
@@ -73,53 +187,83 @@ func NewContextMenu() *ContextMenu {
 	menu.createCells()
 	menu.hlRow = -1
 	var err error
-	menu.renderer, err = NewGridRenderer(Editor.window, menu.rows, menu.cols, nil, DEFAULT_FONT_SIZE, menu.pos)
+	menu.renderer, err = NewGridRenderer(Editor.window, menu.rows, menu.cols, nil, DEFAULT_FONT_SIZE, fontkit.CellAdjust{}, menu.pos)
 	if err != nil {
 		logger.Log(logger.ERROR, "Failed to create context menu renderer")
 	}
 	return menu
 }
 
+// Root menu always uses global buttons
+func (menu *ContextMenu) Buttons() []ContextButton {
+	if menu.parent == nil {
+		return ContextMenuButtons
+	}
+	return menu.buttons
+}
+
 func (menu *ContextMenu) createCells() {
-	// Find the longest text.
-	longest := 0
-	for _, btn := range ContextMenuButtons {
-		if len(btn.name) > longest {
-			longest = len(btn.name)
+	buttons := menu.Buttons()
+	// Find the widest text and check for submenus
+	widest := 0
+	hasSubmenu := false
+	for _, btn := range buttons {
+		widest = common.Max(widest, runewidth.StringWidth(btn.name))
+		if btn.HasSubmenu() {
+			hasSubmenu = true
 		}
 	}
 	// Create cells
-	menu.cols = longest + 2
-	menu.rows = len(ContextMenuButtons)
-	menu.cells = make([][]rune, menu.rows, menu.rows)
+	menu.cols = widest + 2
+	if hasSubmenu {
+		// Space and arrow
+		menu.cols += 2
+	}
+	menu.rows = common.Max(len(buttons), 1)
+	menu.cells = make([][]rune, menu.rows)
 	for i := range menu.cells {
-		menu.cells[i] = make([]rune, menu.cols, menu.cols)
+		menu.cells[i] = make([]rune, menu.cols)
 	}
 	// Resize renderer
 	if menu.renderer != nil {
 		menu.renderer.Resize(menu.rows, menu.cols)
 	}
-	// Loop through all cells and give them correct characters
-	for row := 0; row < menu.rows; row++ {
-		for col := 1; col < menu.cols-1; col++ {
-			var c rune = 0
-			if col-1 < len(ContextMenuButtons[row].name) {
-				c = rune(ContextMenuButtons[row].name[col-1])
-				if c == ' ' {
-					c = 0
-				}
+	// Loop through all buttons and give cells correct characters
+	for row, btn := range buttons {
+		if btn.separator {
+			for col := 0; col < menu.cols; col++ {
+				menu.cells[row][col] = CONTEXT_MENU_SEPARATOR_CHAR
+			}
+			continue
+		}
+		col := 1
+		for _, c := range btn.name {
+			width := runewidth.RuneWidth(c)
+			if width == 0 {
+				// We can't draw combining characters
+				continue
+			}
+			if c == ' ' {
+				c = 0
 			}
 			menu.cells[row][col] = c
+			// Wide characters draws their second half to the next cell
+			col += width
+		}
+		if btn.HasSubmenu() {
+			menu.cells[row][menu.cols-2] = CONTEXT_MENU_SUBMENU_CHAR
 		}
 	}
 }
 
 func (menu *ContextMenu) SetFontKit(kit *fontkit.FontKit) {
+	menu.closeChild()
 	menu.renderer.SetFontKit(kit)
 	MarkForceDraw()
 }
 
 func (menu *ContextMenu) SetFontSize(size float64) {
+	menu.closeChild()
 	menu.renderer.SetFontSize(size, Editor.window.DPI())
 	MarkForceDraw()
 }
@@ -140,25 +284,19 @@ func (menu *ContextMenu) Draw() {
 	}
 	EndBenchmark := bench.BeginBenchmark()
 	for row := 0; row < menu.rows; row++ {
+		attrib := menu.colors.normal
+		if row < len(menu.enabled) && !menu.enabled[row] && !menu.isSeparator(row) {
+			attrib = menu.colors.disabled
+		} else if menu.hlRow == row {
+			attrib = menu.colors.selected
+		}
 		for col := 0; col < menu.cols; col++ {
-			char := menu.cells[row][col]
-			if menu.hlRow == row && col > 0 && col < menu.cols-1 {
-				// This is the highlighted cell
-				menu.renderer.DrawCell(row, col, char, HighlightAttribute{
-					foreground: Editor.gridManager.foreground,
-					background: Editor.gridManager.background,
-					bold:       true,
-				})
-			} else {
-				// Normally we swap menu colors
-				menu.renderer.DrawCell(row, col, char, HighlightAttribute{
-					foreground: Editor.gridManager.background,
-					background: Editor.gridManager.foreground,
-					bold:       true,
-				})
-			}
+			menu.renderer.DrawCell(row, col, menu.cells[row][col], attrib)
 		}
 	}
+	if menu.child != nil {
+		menu.child.Draw()
+	}
 	EndBenchmark("ContextMenu.Draw")
 }
 
@@ -167,23 +305,62 @@ func (menu *ContextMenu) Render() {
 		return
 	}
 	menu.renderer.Render()
+	if menu.child != nil {
+		menu.child.Render()
+	}
 }
 
+// Adds a button to the root menu. Button name may contain slashes and every
+// part before last slash is the name of a submenu. Buttons named "-" are
+// separators.
 func (menu *ContextMenu) AddButton(button ContextButton) {
-	ContextMenuButtons = append(ContextMenuButtons, button)
+	path := strings.Split(button.name, "/")
+	button.name = path[len(path)-1]
+	if button.name == "-" {
+		button = ContextSeparator()
+	}
+	ContextMenuButtons = addContextButton(ContextMenuButtons, path[:len(path)-1], button)
+	menu.closeChild()
 	menu.createCells()
 }
 
-func (menu *ContextMenu) ShowAt(pos common.Vector2[int]) {
-	menu.hidden = false
+// Moves menu to the position but keeps it inside the window
+func (menu *ContextMenu) setPos(pos common.Vector2[int]) {
+	size := menu.Dimensions()
+	windowSize := Editor.window.Size()
+	if pos.X+size.W > windowSize.Width() {
+		pos.X = common.Max(0, windowSize.Width()-size.W)
+	}
+	if pos.Y+size.H > windowSize.Height() {
+		pos.Y = common.Max(0, windowSize.Height()-size.H)
+	}
 	menu.pos = pos
 	menu.renderer.SetPos(pos)
+}
+
+func (menu *ContextMenu) ShowAt(pos common.Vector2[int]) {
+	menu.closeChild()
+	if menu.parent == nil {
+		// Colors may have been changed since the last time
+		menu.colors = loadContextMenuColors()
+	}
+	// Evaluate state of the buttons
+	buttons := menu.Buttons()
+	menu.enabled = make([]bool, len(buttons))
+	for i, btn := range buttons {
+		menu.enabled[i] = btn.IsEnabled()
+	}
+	menu.hlRow = -1
+	menu.hidden = false
+	menu.setPos(pos)
 	MarkDraw()
 }
 
 func (menu *ContextMenu) Hide() {
+	menu.closeChild()
 	if !menu.hidden {
 		menu.hidden = true
+		menu.hlRow = -1
 		MarkRender()
 	}
 }
@@ -198,60 +375,206 @@ func (menu *ContextMenu) Dimensions() common.Rectangle[int] {
 	}
 }
 
-// Returns true if given position IsIntersecting with menu,
-// and if the position is on the button, returns button index.
-func (menu *ContextMenu) IsIntersecting(pos common.Vector2[int]) (bool, int) {
+func (menu *ContextMenu) isSeparator(row int) bool {
+	buttons := menu.Buttons()
+	return row >= 0 && row < len(buttons) && buttons[row].separator
+}
+
+// Returns true if the row is a button which can be highlighted
+func (menu *ContextMenu) isSelectable(row int) bool {
+	return row >= 0 && row < len(menu.enabled) && menu.enabled[row]
+}
+
+// Opens submenu of the button at row
+func (menu *ContextMenu) openChild(row int) {
+	menu.closeChild()
+	buttons := menu.Buttons()
+	if !menu.isSelectable(row) || !buttons[row].HasSubmenu() {
+		return
+	}
+	child := new(ContextMenu)
+	child.parent = menu
+	child.buttons = buttons[row].submenu
+	child.colors = menu.colors
+	child.hlRow = -1
+	child.hidden = true
+	child.createCells()
+	var err error
+	child.renderer, err = NewGridRenderer(Editor.window, child.rows, child.cols, menu.renderer.atlas.FontKit(), menu.renderer.FontSize(), fontkit.CellAdjust{}, menu.pos)
+	if err != nil {
+		logger.Log(logger.ERROR, "Failed to create context submenu renderer")
+		return
+	}
+	menu.child = child
+	// Open at the right of the button, or left if there is no space
+	rect := menu.Dimensions()
+	pos := common.Vec2(rect.X+rect.W, rect.Y+row*menu.renderer.CellSize().Height())
+	if pos.X+child.Dimensions().W > Editor.window.Size().Width() {
+		pos.X = common.Max(0, rect.X-child.Dimensions().W)
+	}
+	child.ShowAt(pos)
+}
+
+func (menu *ContextMenu) closeChild() {
+	if menu.child != nil {
+		menu.child.Hide()
+		menu.child.renderer.Destroy()
+		menu.child = nil
+		MarkRender()
+	}
+}
+
+// Returns deepest open submenu, returns itself if there is no open submenu
+func (menu *ContextMenu) activeMenu() *ContextMenu {
+	active := menu
+	for active.child != nil {
+		active = active.child
+	}
+	return active
+}
+
+// Changes the highlighted row and opens submenu if highlighted button has one
+func (menu *ContextMenu) highlight(row int) {
+	if !menu.isSelectable(row) {
+		row = -1
+	}
+	if menu.hlRow == row {
+		return
+	}
+	menu.hlRow = row
+	menu.closeChild()
+	if row != -1 && menu.Buttons()[row].HasSubmenu() {
+		menu.openChild(row)
+	}
+	MarkDraw()
+}
+
+// Calls the function of the button at row. Returns true if the menu needs to
+// be closed.
+func (menu *ContextMenu) activate(row int) bool {
+	if !menu.isSelectable(row) {
+		return false
+	}
+	button := menu.Buttons()[row]
+	if button.HasSubmenu() {
+		if menu.child == nil {
+			menu.openChild(row)
+		}
+		if menu.child != nil {
+			menu.child.selectNext(1)
+		}
+		return false
+	}
+	if button.fn != nil {
+		button.fn()
+	}
+	return true
+}
+
+// Moves the highlight to the next selectable row in the direction.
+func (menu *ContextMenu) selectNext(direction int) {
+	row := menu.hlRow
+	if row == -1 && direction < 0 {
+		row = menu.rows
+	}
+	for i := 0; i < menu.rows; i++ {
+		row = (row + direction + menu.rows) % menu.rows
+		if menu.isSelectable(row) {
+			// Moving with keyboard shouldn't open submenus
+			menu.hlRow = row
+			menu.closeChild()
+			MarkDraw()
+			return
+		}
+	}
+}
+
+// Handles navigation keys when menu is open. Returns true if the key is
+// consumed by the menu and shouldn't be sent to neovim.
+func (menu *ContextMenu) KeyInput(keycode string) bool {
+	if menu.hidden {
+		return false
+	}
+	active := menu.activeMenu()
+	switch keycode {
+	case "<Down>", "<Tab>":
+		active.selectNext(1)
+	case "<Up>", "<S-Tab>":
+		active.selectNext(-1)
+	case "<Right>":
+		if active.isSelectable(active.hlRow) && active.Buttons()[active.hlRow].HasSubmenu() {
+			active.activate(active.hlRow)
+		}
+	case "<Left>":
+		if active.parent != nil {
+			active.parent.closeChild()
+		}
+	case "<CR>", "<kEnter>", "<Space>":
+		if active.activate(active.hlRow) {
+			menu.Hide()
+		}
+	case "<ESC>":
+		if active.parent != nil {
+			active.parent.closeChild()
+		} else {
+			menu.Hide()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Returns the menu and the row at position, searching from the deepest
+// submenu. Returned row is -1 if the position is on the menu but not on a
+// button. Returns nil if the position is not on any menu.
+func (menu *ContextMenu) menuAt(pos common.Vector2[int]) (*ContextMenu, int) {
+	if menu.hidden {
+		return nil, -1
+	}
+	if menu.child != nil {
+		child, row := menu.child.menuAt(pos)
+		if child != nil {
+			return child, row
+		}
+	}
 	menuRect := menu.Dimensions()
 	if pos.IsInRect(menuRect) {
-		// Areas are intersecting. Now we need to find button under the cursor.
-		// This is very simple. First we find the cell at the position.
-		relativePos := common.Vector2[int]{
-			X: pos.X - menu.pos.X,
-			Y: pos.Y - menu.pos.Y,
-		}
 		cellSize := menu.renderer.CellSize()
-		row := relativePos.Y / cellSize.Height()
-		col := relativePos.X / cellSize.Width()
+		row := (pos.Y - menu.pos.Y) / cellSize.Height()
+		col := (pos.X - menu.pos.X) / cellSize.Width()
 		if col > 0 && col < menu.cols-1 {
-			return true, row
+			return menu, row
 		}
-		return true, -1
+		return menu, -1
 	}
-	return false, -1
+	return nil, -1
+}
+
+// Returns true if the position is over this menu or one of its submenus
+func (menu *ContextMenu) IsInArea(pos common.Vector2[int]) bool {
+	child, _ := menu.menuAt(pos)
+	return child != nil
 }
 
 // Call this function when mouse moved.
 func (menu *ContextMenu) MouseMove(pos common.Vector2[int]) {
-	if !menu.hidden {
-		ok, index := menu.IsIntersecting(pos)
-		if ok {
-			// The index is not -1 means cursor is on top of a button. And
-			// index is the index of the button and also row of the popup menu.
-			if index != -1 {
-				if index < len(menu.cells) {
-					// Highlight this row.
-					if menu.hlRow != index {
-						menu.hlRow = index
-						MarkDraw()
-					}
-				}
-			} else {
-				if menu.hlRow != -1 {
-					menu.hlRow = -1
-					MarkDraw()
-				}
-			}
-		} else {
-			// If this uncommented, the context menu will be hidden
-			// when cursor goes out from on top of it.
-			// pmenu.Hide()
-			// Clear highlight
-			if menu.hlRow != -1 {
-				menu.hlRow = -1
-				MarkDraw()
-			}
+	if menu.hidden {
+		return
+	}
+	target, row := menu.menuAt(pos)
+	if target == nil {
+		// Clear highlight of the deepest menu but keep submenus open.
+		// If this hides the menu, the context menu will be hidden when
+		// cursor goes out from on top of it.
+		active := menu.activeMenu()
+		if active.hlRow != -1 {
+			active.hlRow = -1
+			MarkDraw()
 		}
+		return
 	}
+	target.highlight(row)
 }
 
 // Call this function when mouse clicked.
@@ -261,13 +584,10 @@ func (menu *ContextMenu) MouseMove(pos common.Vector2[int]) {
 func (menu *ContextMenu) MouseClick(rightbutton bool, pos common.Vector2[int]) bool {
 	if !rightbutton && !menu.hidden {
 		// If positions are intersecting then call button click event, hide popup menu otherwise.
-		ok, index := menu.IsIntersecting(pos)
-		if ok {
-			if index != -1 {
-				ContextMenuButtons[index].fn()
-				menu.Hide()
-			}
-		} else {
+		target, row := menu.menuAt(pos)
+		if target == nil {
+			menu.Hide()
+		} else if row != -1 && target.activate(row) {
 			menu.Hide()
 		}
 		return true
@@ -280,6 +600,7 @@ func (menu *ContextMenu) MouseClick(rightbutton bool, pos common.Vector2[int]) b
 }
 
 func (menu *ContextMenu) Destroy() {
+	menu.closeChild()
 	menu.renderer.Destroy()
 	logger.Log(logger.DEBUG, "Context menu destroyed")
 }
@@ -124,8 +124,23 @@ func (cursor *Cursor) SetPosition(id, row, col int) {
 			X: float32(targetGrid.PixelPos().X + (col * targetGrid.CellSize().Width())),
 			Y: float32(targetGrid.PixelPos().Y + (row * targetGrid.CellSize().Height())),
 		}
-		cursor.anim = common.NewAnimation(current, target, Editor.options.cursorAnimTime)
+		animTime := Editor.options.cursorAnimTime
+		if currentGrid.external != targetGrid.external {
+			// Cursor is moving between windows
+			animTime = 0
+		}
+		cursor.anim = common.NewAnimation(current, target, animTime)
 	}()
+	// Ligatures are not drawn under the cursor, rows of the old and new
+	// positions must be shaped again
+	if Editor.options.ligatures && (cursor.grid != id || cursor.row != row || cursor.col != col) {
+		if grid := cursor.Grid(); grid != nil {
+			grid.MarkRowForDraw(cursor.row)
+		}
+		if grid := Editor.gridManager.Grid(id); grid != nil {
+			grid.MarkRowForDraw(row)
+		}
+	}
 	cursor.grid = id
 	cursor.row = row
 	cursor.col = col
@@ -196,30 +211,36 @@ func (cursor *Cursor) Draw(delta float32) {
 	// Current grid where the cursor is
 	grid := cursor.Grid()
 	if grid != nil {
-		pos := cursor.anim.Step(delta).ToInt()
-		rect, blockShaped := cursor.modeRectangle(modeInfo, pos, grid.CellSize())
+		// Drawing a character may require to update grid's atlas
+		defer grid.bindContext()()
+		pos := cursor.anim.Step(delta)
+		// Move with the grid content while scrolling
+		pos.Y += grid.renderer.ScrollOffsetAt(cursor.row, cursor.col)
+		rect, blockShaped := cursor.modeRectangle(modeInfo, pos.ToInt(), grid.CellSize())
 		cell := grid.SafeCellAt(cursor.row, cursor.col)
 		// Only draw character to the cursor if animation is finished and cell
 		// has a printable character and cursor shape is block
 		if cursor.anim.IsFinished() && cell.char != 0 && blockShaped {
 			// We need to draw cell character to the cursor foreground
 			cellAttrib := cell.Attribute()
-			// Draw undercurl to cursor if cell has
-			if cellAttrib.undercurl {
-				cursor.buffer.SetIndexSp(0, cursorFg)
-			}
+			// Draw decorations of the cell with the cursor foreground
+			cursor.buffer.SetIndexStyle(0, cellAttrib.decorations())
+			cursor.buffer.SetIndexSp(0, cursorFg)
 			// Draw this cell to the cursor
-			charPos := grid.renderer.atlas.GetCharPos(cell.char, cellAttrib.bold, cellAttrib.italic, cellAttrib.underline, cellAttrib.strikethrough, grid.CellSize())
+			charPos, colored := grid.renderer.atlas.GetChar(cell.char, cellAttrib.bold, cellAttrib.italic, grid.CellSize())
 			if charPos.W > grid.CellSize().Width() {
 				charPos.W /= 2
 			}
 			cursor.buffer.SetIndexTex1(0, grid.renderer.atlas.Normalize(charPos))
+			cursor.buffer.SetIndexTex1Colored(0, colored)
 			cursor.buffer.SetIndexFg(0, cursorFg)
 		} else {
 			// No cell drawing needed. Clear foreground.
 			cursor.buffer.SetIndexTex1(0, common.ZeroRectangleF32)
+			cursor.buffer.SetIndexTex1Colored(0, false)
 			cursor.buffer.SetIndexFg(0, common.ZeroColor)
 			cursor.buffer.SetIndexSp(0, common.ZeroColor)
+			cursor.buffer.SetIndexStyle(0, 0)
 		}
 		// Background and position is always required
 		cursor.buffer.SetIndexBg(0, cursorBg)
@@ -227,12 +248,22 @@ func (cursor *Cursor) Draw(delta float32) {
 	}
 }
 
+func (cursor *Cursor) IsVisible() bool {
+	return !cursor.hidden && !cursor.bHidden
+}
+
+// Returns drawn vertex of the cursor
+func (cursor *Cursor) Vertex() opengl.Vertex {
+	return cursor.buffer.VertexAt(0)
+}
+
 func (cursor *Cursor) Render() {
 	if cursor.hidden || cursor.bHidden {
 		return
 	}
 	grid := cursor.Grid()
-	if grid != nil {
+	// External windows render the cursor themselves
+	if grid != nil && grid.external == nil {
 		// Because we are drawing grid's characters, we need it's atlas
 		grid.renderer.atlas.BindTexture()
 		cursor.buffer.Bind()
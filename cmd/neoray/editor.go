@@ -19,11 +19,32 @@ import (
 type Options struct {
 	// custom options
 	cursorAnimTime      float32
+	scrollAnimTime      float32
 	transparency        float32
 	targetTPS           int
 	contextMenuEnabled  bool
 	boxDrawingEnabled   bool
+	blockDrawingEnabled bool
+	powerlineDrawing    bool
+	cellWidthScale      float64
+	cellHeightScale     float64
+	baselineOffset      int
+	undercurlAmplitude  float64
+	undercurlWavelength float64
+	undercurlThickness  float64
+	hinting             fontkit.Hinting   // Used if the guifont doesn't set it
+	antialias           fontkit.Antialias // Used if the guifont doesn't set it
+	textGamma           float64
+	textContrast        float64
+	syntheticBold       bool
+	syntheticItalic     bool
+	fontVariations      fontkit.VariationSettings
+	ligatures           bool
 	imageViewerEnabled  bool
+	bellFlashTime       float32
+	bellFlashColor      common.Color // zero means default foreground with low alpha
+	bellAttention       bool
+	bellCommand         string
 	keyToggleFullscreen string
 	keyIncreaseFontSize string
 	keyDecreaseFontSize string
@@ -32,11 +53,25 @@ type Options struct {
 func DefaultOptions() Options {
 	return Options{
 		cursorAnimTime:      0.1,
+		scrollAnimTime:      0,
 		transparency:        1,
 		targetTPS:           60,
 		contextMenuEnabled:  true,
 		boxDrawingEnabled:   true,
+		blockDrawingEnabled: true,
+		powerlineDrawing:    true,
+		cellWidthScale:      1,
+		cellHeightScale:     1,
+		baselineOffset:      0,
+		undercurlAmplitude:  1,
+		undercurlWavelength: 1,
+		undercurlThickness:  1,
+		textGamma:           1,
+		syntheticBold:       true,
+		syntheticItalic:     true,
 		imageViewerEnabled:  true,
+		bellFlashTime:       0.15,
+		bellAttention:       true,
 		keyToggleFullscreen: "<F11>",
 		keyIncreaseFontSize: "<C-kPlus>",
 		keyDecreaseFontSize: "<C-kMinus>",
@@ -94,6 +129,8 @@ var Editor struct {
 	contextMenu *ContextMenu
 	// ImageViewer
 	imageViewer *ImageViewer
+	// Bell handles bell and visual_bell events, rendered as an overlay
+	bell *Bell
 	// UIOptions is a struct, holds some user ui uiOptions like guifont.
 	uiOptions UIOptions
 	// Neovim child process
@@ -128,7 +165,7 @@ func InitEditor() {
 	// Set window minimum size
 	Editor.window.SetMinSize(common.Vec2(300, 200))
 	// Set window icons
-	LoadDefaultIcons()
+	LoadDefaultIcons(Editor.window)
 	// Update opengl viewport
 	Editor.window.GL().SetViewport(Editor.window.Viewport())
 	// Print some opengl info
@@ -148,6 +185,8 @@ func InitEditor() {
 	Editor.contextMenu = NewContextMenu()
 	// Initialize imageViewer
 	Editor.imageViewer = NewImageViewer(Editor.window)
+	// Initialize bell
+	Editor.bell = NewBell(Editor.window)
 	// TODO Move this to gridManager
 	Editor.uiOptions = CreateUIOptions()
 	// Start neovim
@@ -165,7 +204,7 @@ func InitEditor() {
 	SetEditorState(EditorInitialized)
 }
 
-func LoadDefaultIcons() {
+func LoadDefaultIcons(win *window.Window) {
 	icons := [3]image.Image{}
 	icon48, err := png.Decode(bytes.NewReader(assets.NeovimIconData48x48))
 	if err != nil {
@@ -187,7 +226,7 @@ func LoadDefaultIcons() {
 	} else {
 		icons[2] = icon16
 	}
-	Editor.window.SetIcon(icons)
+	win.SetIcon(icons)
 }
 
 // A helper function, if default grid is not set by neovim yet we use this for cell size
@@ -197,10 +236,41 @@ func DefaultCellSize() common.Vector2[int] {
 		DPI:             Editor.window.DPI(),
 		UseBoxDrawing:   false,
 		UseBlockDrawing: false,
+		Adjust:          CellAdjust(),
 	})
 	return face.ImageSize()
 }
 
+// Returns cell size adjustments, these are linespace option and user options
+func CellAdjust() fontkit.CellAdjust {
+	return fontkit.CellAdjust{
+		LineSpace:      Editor.uiOptions.linespace,
+		Width:          Editor.uiOptions.guifontWidth,
+		WidthScale:     Editor.options.cellWidthScale,
+		HeightScale:    Editor.options.cellHeightScale,
+		BaselineOffset: Editor.options.baselineOffset,
+	}
+}
+
+// Returns the undercurl scales set by the user options
+func UndercurlAdjust() fontkit.UndercurlAdjust {
+	return fontkit.UndercurlAdjust{
+		AmplitudeScale:  Editor.options.undercurlAmplitude,
+		WavelengthScale: Editor.options.undercurlWavelength,
+		ThicknessScale:  Editor.options.undercurlThickness,
+	}
+}
+
+// Returns the glyph rendering settings set by the user options
+func TextRendering() fontkit.Rendering {
+	return fontkit.Rendering{
+		Hinting:   Editor.options.hinting,
+		Antialias: Editor.options.antialias,
+		Gamma:     Editor.options.textGamma,
+		Contrast:  Editor.options.textContrast,
+	}
+}
+
 func ResizeWindowInCellFormat(rows, cols int) {
 	var size common.Vector2[int]
 	defaultGrid := Editor.gridManager.Grid(1)
@@ -272,6 +342,7 @@ func MainLoop() {
 			}
 			// Handle with inputs first
 			Editor.window.PollEvents()
+			Editor.gridManager.PollExternalEvents()
 			// then update
 			UpdateHandler(float32(delta))
 		case <-Editor.quitChan:
@@ -285,9 +356,10 @@ func MainLoop() {
 func UpdateHandler(delta float32) {
 	// Update required stuff
 	Editor.nvim.Update()
-	Editor.gridManager.Update()
+	Editor.gridManager.Update(delta)
 	Editor.cursor.Update(delta)
 	Editor.imageViewer.Update()
+	Editor.bell.Update(delta)
 	if Editor.server != nil {
 		Editor.server.Update()
 	}
@@ -313,8 +385,11 @@ func UpdateHandler(delta float32) {
 			Editor.cursor.Render()
 			Editor.contextMenu.Render()
 			Editor.imageViewer.Render()
+			Editor.bell.Render()
 			// Flush to make changes visible
 			Editor.window.GL().Flush()
+			// External windows are rendered to their own contexts
+			Editor.gridManager.RenderExternals()
 			EndBenchmark("UpdateHandler.Render")
 		}
 		// Clear calls
@@ -435,6 +510,7 @@ func ShutdownEditor() {
 		Editor.server.Close()
 	}
 	Editor.nvim.Close()
+	Editor.bell.Destroy()
 	Editor.imageViewer.Destroy()
 	Editor.contextMenu.Destroy()
 	Editor.cursor.Destroy()
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/hismailbulut/Neoray/pkg/bench"
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/hismailbulut/Neoray/pkg/opengl"
+	"github.com/hismailbulut/Neoray/pkg/window"
+)
+
+// ExternalWindow is a top level window for an external neovim window. Only
+// available when multigrid is enabled. Opengl objects can't be shared between
+// contexts (except textures and buffers) and because of this every external
+// window has it's own renderer for the grid.
+type ExternalWindow struct {
+	grid   *Grid
+	window *window.Window
+	cursor *opengl.VertexBuffer // Copy of the cursor when the cursor is in this window
+	// Mouse state, same as the main window
+	mousePos    common.Vector2[int]
+	mouseButton string
+	mouseAction glfw.Action
+	dragRow     int
+	dragCol     int
+}
+
+func NewExternalWindow(grid *Grid) (*ExternalWindow, error) {
+	size := grid.Size()
+	win, err := window.New(NAME, size.Width(), size.Height(), bench.IsDebugBuild())
+	if err != nil {
+		return nil, err
+	}
+	ext := &ExternalWindow{
+		grid:   grid,
+		window: win,
+	}
+	// Window creation makes the new context current
+	win.GL().SetViewport(win.Viewport())
+	ext.cursor = win.GL().CreateVertexBuffer(1)
+	win.SetEventHandler(ext.eventHandler)
+	win.SetMinSize(common.Vec2(100, 50))
+	LoadDefaultIcons(win)
+	Editor.window.MakeContextCurrent()
+	// Move grid renderer to the new window
+	grid.moveRenderer(win)
+	win.Show()
+	logger.Log(logger.DEBUG, "External window created for grid", grid.id)
+	return ext, nil
+}
+
+// Resizes the window to fit the grid. Neovim may resize the grid itself.
+func (ext *ExternalWindow) FitToGrid() {
+	size := ext.grid.Size()
+	if size != ext.window.Size() {
+		ext.window.Resize(size)
+	}
+}
+
+func (ext *ExternalWindow) Show() {
+	if !ext.window.IsVisible() {
+		ext.window.Show()
+	}
+}
+
+func (ext *ExternalWindow) Hide() {
+	ext.window.Hide()
+}
+
+func (ext *ExternalWindow) PollEvents() {
+	ext.window.PollEvents()
+}
+
+func (ext *ExternalWindow) cellAt(pos common.Vector2[int]) (int, int) {
+	cellSize := ext.grid.CellSize()
+	row := common.Clamp(pos.Y/cellSize.Height(), 0, ext.grid.rows-1)
+	col := common.Clamp(pos.X/cellSize.Width(), 0, ext.grid.cols-1)
+	return row, col
+}
+
+func (ext *ExternalWindow) eventHandler(event window.WindowEvent) {
+	switch event.Type {
+	case window.WindowEventRefresh:
+		MarkRender()
+	case window.WindowEventResize:
+		{
+			width := event.Params[0].(int)
+			height := event.Params[1].(int)
+			if width <= 0 || height <= 0 {
+				break
+			}
+			ext.window.MakeContextCurrent()
+			ext.window.GL().SetViewport(ext.window.Viewport())
+			Editor.window.MakeContextCurrent()
+			MarkRender()
+			cellSize := ext.grid.CellSize()
+			rows := height / cellSize.Height()
+			cols := width / cellSize.Width()
+			if rows != ext.grid.rows || cols != ext.grid.cols {
+				Editor.nvim.TryResizeUIGrid(ext.grid.id, rows, cols)
+			}
+		}
+	case window.WindowEventKeyInput:
+		{
+			key := event.Params[0].(glfw.Key)
+			scancode := event.Params[1].(int)
+			action := event.Params[2].(glfw.Action)
+			mods := event.Params[3].(glfw.ModifierKey)
+			KeyInputHandler(key, scancode, action, mods)
+		}
+	case window.WindowEventCharInput:
+		{
+			char := event.Params[0].(rune)
+			CharInputHandler(char)
+		}
+	case window.WindowEventMouseInput:
+		{
+			button := event.Params[0].(glfw.MouseButton)
+			action := event.Params[1].(glfw.Action)
+			var buttonCode string
+			switch button {
+			case glfw.MouseButtonLeft:
+				buttonCode = "left"
+			case glfw.MouseButtonRight:
+				buttonCode = "right"
+			case glfw.MouseButtonMiddle:
+				buttonCode = "middle"
+			default:
+				return
+			}
+			actionCode := "press"
+			if action == glfw.Release {
+				actionCode = "release"
+			}
+			ext.dragRow, ext.dragCol = ext.cellAt(ext.mousePos)
+			sendMouseInput(buttonCode, actionCode, inputCache.modifiers, ext.grid.id, ext.dragRow, ext.dragCol)
+			ext.mouseButton = buttonCode
+			ext.mouseAction = action
+		}
+	case window.WindowEventMouseMove:
+		{
+			ext.mousePos.X = int(event.Params[0].(float64))
+			ext.mousePos.Y = int(event.Params[1].(float64))
+			if ext.mouseAction == glfw.Press {
+				row, col := ext.cellAt(ext.mousePos)
+				if row != ext.dragRow || col != ext.dragCol {
+					sendMouseInput(ext.mouseButton, "drag", inputCache.modifiers, ext.grid.id, row, col)
+					ext.dragRow, ext.dragCol = row, col
+				}
+			}
+		}
+	case window.WindowEventScroll:
+		{
+			yoff := event.Params[1].(float64)
+			action := "up"
+			if yoff < 0 {
+				action = "down"
+			}
+			row, col := ext.cellAt(ext.mousePos)
+			sendMouseInput("wheel", action, inputCache.modifiers, ext.grid.id, row, col)
+		}
+	case window.WindowEventDrop:
+		{
+			files := event.Params[0].([]string)
+			DropHandler(files)
+		}
+	case window.WindowEventScaleChanged:
+		{
+			ext.grid.SetFontSize(ext.grid.renderer.FontSize(), ext.window.DPI())
+			ext.FitToGrid()
+			MarkForceDraw()
+		}
+	case window.WindowEventClose:
+		{
+			// Window will be destroyed when neovim closes it
+			ext.window.KeepAlive()
+			go Editor.nvim.CloseWindow(ext.grid.window)
+		}
+	}
+}
+
+func (ext *ExternalWindow) Render() {
+	if !ext.window.IsVisible() {
+		return
+	}
+	ext.window.MakeContextCurrent()
+	defer Editor.window.MakeContextCurrent()
+	bg := Editor.gridManager.background
+	bg.A = Editor.options.transparency
+	ext.window.GL().ClearScreen(bg)
+	ext.grid.renderer.Render()
+	// Cursor buffer is created in the main context, we only copy its data
+	if Editor.cursor.IsVisible() && Editor.cursor.grid == ext.grid.id {
+		ext.cursor.SetVertex(0, Editor.cursor.Vertex())
+		ext.grid.renderer.atlas.BindTexture()
+		ext.cursor.Bind()
+		ext.cursor.Update()
+		ext.cursor.Render()
+	}
+	ext.window.GL().Flush()
+}
+
+// Moves grid back to the main window and destroys the window.
+func (ext *ExternalWindow) Destroy() {
+	ext.grid.moveRenderer(Editor.window)
+	ext.window.MakeContextCurrent()
+	ext.cursor.Destroy()
+	ext.window.Destroy()
+	Editor.window.MakeContextCurrent()
+	logger.Log(logger.DEBUG, "External window destroyed for grid", ext.grid.id)
+}
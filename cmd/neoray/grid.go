@@ -7,6 +7,7 @@ import (
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/hismailbulut/Neoray/pkg/opengl"
 	"github.com/hismailbulut/Neoray/pkg/window"
 	"github.com/neovim/go-client/nvim"
 )
@@ -14,9 +15,10 @@ import (
 type GridType int32
 
 const (
-	GridTypeNormal  GridType = iota // Normal grid
-	GridTypeMessage                 // Message grid, will be rendered front of the normal grids
-	GridTypeFloat                   // Float window, will be rendered most front
+	GridTypeNormal   GridType = iota // Normal grid
+	GridTypeMessage                  // Message grid, will be rendered front of the normal grids
+	GridTypeFloat                    // Float window, will be rendered most front
+	GridTypeExternal                 // External window, will be rendered in it's own window
 )
 
 func (gridType GridType) String() string {
@@ -27,6 +29,8 @@ func (gridType GridType) String() string {
 		return "Message"
 	case GridTypeFloat:
 		return "Float"
+	case GridTypeExternal:
+		return "External"
 	}
 	panic("unknown grid type")
 }
@@ -94,7 +98,11 @@ type Grid struct {
 	hidden     bool
 	typ        GridType
 	renderer   *GridRenderer
+	external   *ExternalWindow // Only set if the grid is an external window
 	cells      [][]Cell
+
+	// Some cells are marked for drawing by an atlas eviction during the draw
+	invalidated bool
 }
 
 // For debugging purposes.
@@ -124,33 +132,126 @@ func NewGrid(window *window.Window, id, number int, rows, cols int, kit *fontkit
 	}
 	// Create renderer
 	var err error
-	grid.renderer, err = NewGridRenderer(window, rows, cols, kit, fontSize, position)
+	grid.renderer, err = NewGridRenderer(window, rows, cols, kit, fontSize, CellAdjust(), position)
 	if err != nil {
 		return nil, err
 	}
+	grid.renderer.SetInvalidateHandler(grid.invalidateCell)
 	logger.Log(logger.DEBUG, "Grid created:", grid)
 	return grid, nil
 }
 
+// External grids have their own opengl context. This must be called before
+// any opengl related operation of the grid. Returned function makes the main
+// context current again.
+func (grid *Grid) bindContext() func() {
+	if grid.renderer.window == Editor.window {
+		return func() {}
+	}
+	grid.renderer.window.MakeContextCurrent()
+	return Editor.window.MakeContextCurrent
+}
+
+// Recreates renderer in the given window, opengl objects can't be shared
+// between windows.
+func (grid *Grid) moveRenderer(win *window.Window) {
+	if grid.renderer.window == win {
+		return
+	}
+	kit := grid.renderer.atlas.FontKit()
+	fallbacks := grid.renderer.atlas.FallbackFontKits()
+	wideKit := grid.renderer.atlas.WideFontKit()
+	ranges := grid.renderer.atlas.FontRanges()
+	fontSize := grid.renderer.FontSize()
+	adjust := grid.renderer.atlas.CellAdjust()
+	grid.renderer.window.MakeContextCurrent()
+	grid.renderer.Destroy()
+	win.MakeContextCurrent()
+	var err error
+	grid.renderer, err = NewGridRenderer(win, grid.rows, grid.cols, kit, fontSize, adjust, common.Vec2(0, 0))
+	if err != nil {
+		logger.Log(logger.FATAL, "Grid renderer creation failed:", err)
+	}
+	grid.renderer.SetInvalidateHandler(grid.invalidateCell)
+	grid.renderer.SetFallbackFontKits(fallbacks)
+	grid.renderer.SetWideFontKit(wideKit)
+	grid.renderer.SetFontRanges(ranges)
+	Editor.window.MakeContextCurrent()
+	MarkForceDraw()
+}
+
 // Font related
 
 func (grid *Grid) SetFontKit(kit *fontkit.FontKit) {
+	defer grid.bindContext()()
 	grid.renderer.SetFontKit(kit)
 }
 
+func (grid *Grid) SetFallbackFontKits(kits []*fontkit.FontKit) {
+	defer grid.bindContext()()
+	grid.renderer.SetFallbackFontKits(kits)
+}
+
+func (grid *Grid) SetWideFontKit(kit *fontkit.FontKit) {
+	defer grid.bindContext()()
+	grid.renderer.SetWideFontKit(kit)
+}
+
+func (grid *Grid) SetFontRanges(ranges []fontkit.FontRange) {
+	defer grid.bindContext()()
+	grid.renderer.SetFontRanges(ranges)
+}
+
 func (grid *Grid) SetFontSize(fontSize, dpi float64) {
+	defer grid.bindContext()()
 	grid.renderer.SetFontSize(fontSize, dpi)
 }
 
 func (grid *Grid) AddFontSize(v, dpi float64) {
+	defer grid.bindContext()()
 	fontSize := grid.renderer.FontSize() + v
 	grid.renderer.SetFontSize(fontSize, dpi)
 }
 
+func (grid *Grid) SetCellAdjust(adjust fontkit.CellAdjust) {
+	defer grid.bindContext()()
+	grid.renderer.SetCellAdjust(adjust)
+}
+
+func (grid *Grid) SetUndercurlAdjust(adjust fontkit.UndercurlAdjust) {
+	grid.renderer.SetUndercurlAdjust(adjust)
+}
+
+func (grid *Grid) SetTextRendering(rendering fontkit.Rendering) {
+	defer grid.bindContext()()
+	grid.renderer.SetTextRendering(rendering)
+}
+
 func (grid *Grid) SetBoxDrawing(useBoxDrawing, useBlockDrawing bool) {
+	defer grid.bindContext()()
 	grid.renderer.SetBoxDrawing(useBoxDrawing, useBlockDrawing)
 }
 
+func (grid *Grid) SetWideEmoji(wide bool) {
+	defer grid.bindContext()()
+	grid.renderer.SetWideEmoji(wide)
+}
+
+func (grid *Grid) SetPowerlineDrawing(usePowerline bool) {
+	defer grid.bindContext()()
+	grid.renderer.SetPowerlineDrawing(usePowerline)
+}
+
+func (grid *Grid) SetSyntheticStyles(bold, italic bool) {
+	defer grid.bindContext()()
+	grid.renderer.SetSyntheticStyles(bold, italic)
+}
+
+func (grid *Grid) SetVariationSettings(settings fontkit.VariationSettings) {
+	defer grid.bindContext()()
+	grid.renderer.SetVariationSettings(settings)
+}
+
 func (grid *Grid) Size() common.Vector2[int] {
 	return common.Vector2[int]{
 		X: grid.cols * grid.CellSize().Width(),
@@ -194,6 +295,7 @@ func (grid *Grid) Scroll(top, bot, rows, left, right int) {
 		copy(grid.cells[dst][left:right], grid.cells[src][left:right])
 		grid.renderer.CopyRow(dst, src, left, right)
 	}
+	grid.renderer.BeginScroll(top, bot, left, right, rows)
 	if rows > 0 { // Scroll down, move up
 		for y := top + rows; y < bot; y++ {
 			copyRow(y-rows, y, left, right)
@@ -203,6 +305,10 @@ func (grid *Grid) Scroll(top, bot, rows, left, right int) {
 			copyRow(y-rows, y, left, right)
 		}
 	}
+	// When multigrid is on, animation starts with the win_viewport event
+	if !Editor.parsedArgs.multiGrid {
+		grid.renderer.AnimateScroll(rows)
+	}
 	MarkRender()
 }
 
@@ -238,9 +344,17 @@ func (grid *Grid) Resize(rows, cols int) {
 	grid.cols = cols
 	// Resizing renderer also clears it's buffer, because of this we must redraw every cell
 	MarkForceDraw()
+	if grid.external != nil {
+		grid.external.FitToGrid()
+	}
 }
 
 func (grid *Grid) SetPos(win nvim.Window, sRow, sCol int, rows, cols int, typ GridType, position common.Vector2[int]) {
+	if grid.external != nil {
+		// Window is no longer external
+		grid.external.Destroy()
+		grid.external = nil
+	}
 	grid.window = win
 	grid.typ = typ
 	grid.hidden = false
@@ -253,12 +367,53 @@ func (grid *Grid) SetPos(win nvim.Window, sRow, sCol int, rows, cols int, typ Gr
 	MarkForceDraw()
 }
 
+// Makes the grid an external window, creates a top level window for it.
+func (grid *Grid) SetExternal(win nvim.Window) {
+	grid.window = win
+	grid.typ = GridTypeExternal
+	grid.hidden = false
+	grid.sRow = 0
+	grid.sCol = 0
+	if grid.external == nil {
+		var err error
+		grid.external, err = NewExternalWindow(grid)
+		if err != nil {
+			logger.Log(logger.ERROR, "Failed to create external window:", err)
+			return
+		}
+	} else {
+		grid.external.Show()
+	}
+	logger.Log(logger.DEBUG, "Grid is external:", grid)
+	MarkForceDraw()
+}
+
 func (grid *Grid) Draw(force bool) {
 	if grid.hidden {
 		return
 	}
+	defer grid.bindContext()()
 	EndBenchmark := bench.BeginBenchmark()
+	grid.drawCells(force)
+	// Cells drawn from an evicted atlas page are drawn again, evictions
+	// caused by them are handled in the next frame
+	if grid.invalidated {
+		grid.invalidated = false
+		grid.drawCells(false)
+	}
+	if force {
+		EndBenchmark("Grid.ForceDraw")
+	} else {
+		EndBenchmark("Grid.Draw")
+	}
+}
+
+func (grid *Grid) drawCells(force bool) {
 	for row := 0; row < grid.rows; row++ {
+		if Editor.options.ligatures {
+			grid.drawShapedRow(row, force)
+			continue
+		}
 		for col := 0; col < grid.cols; col++ {
 			cell := grid.CellAt(row, col)
 			if cell.needsDraw || force {
@@ -267,10 +422,90 @@ func (grid *Grid) Draw(force bool) {
 			}
 		}
 	}
-	if force {
-		EndBenchmark("Grid.ForceDraw")
-	} else {
-		EndBenchmark("Grid.Draw")
+}
+
+// Marks the cell for drawing after its glyph is evicted from the atlas.
+func (grid *Grid) invalidateCell(row, col int) {
+	if row < grid.rows && col < grid.cols {
+		grid.cells[row][col].needsDraw = true
+		grid.invalidated = true
+	}
+}
+
+// Draws the row with ligatures. Cells are shaped in runs of the same
+// attribute and a run is drawn again if one of its cells needs to be drawn,
+// because a change may break or form a ligature with the other cells.
+func (grid *Grid) drawShapedRow(row int, force bool) {
+	cells := grid.cells[row]
+	for start := 0; start < grid.cols; {
+		end := start + 1
+		if cells[start].char != 0 {
+			for end < grid.cols && cells[end].char != 0 && cells[end].attribID == cells[start].attribID {
+				end++
+			}
+		}
+		needsDraw := force
+		for col := start; col < end && !needsDraw; col++ {
+			needsDraw = cells[col].needsDraw
+		}
+		if needsDraw {
+			grid.drawRun(row, start, end)
+		}
+		start = end
+	}
+}
+
+func (grid *Grid) drawRun(row, start, end int) {
+	cells := grid.cells[row]
+	attrib := cells[start].Attribute()
+	col := start
+	if cells[start].char != 0 {
+		chars := make([]rune, end-start)
+		for i := range chars {
+			chars[i] = cells[start+i].char
+		}
+		for _, cluster := range grid.ligatures(row, start, grid.renderer.atlas.Shape(chars, attrib.bold, attrib.italic)) {
+			first, last := start+cluster.Start, start+cluster.End
+			for ; col < first; col++ {
+				grid.renderer.DrawCell(row, col, cells[col].char, attrib)
+			}
+			grid.renderer.DrawCluster(row, first, cluster, attrib)
+			col = last
+		}
+	}
+	for ; col < end; col++ {
+		grid.renderer.DrawCell(row, col, cells[col].char, attrib)
+	}
+	for col := start; col < end; col++ {
+		cells[col].needsDraw = false
+	}
+}
+
+// Returns the clusters of the run starting from the column which are drawn
+// as ligatures. Ligatures are drawn as separate chars under the cursor.
+func (grid *Grid) ligatures(row, start int, clusters []opengl.LigatureCluster) []opengl.LigatureCluster {
+	drawn := make([]opengl.LigatureCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if !grid.hasCursor(row, start+cluster.Start, start+cluster.End) {
+			drawn = append(drawn, cluster)
+		}
+	}
+	return drawn
+}
+
+// Reports whether the cursor is in the columns [first, last) of the row.
+func (grid *Grid) hasCursor(row, first, last int) bool {
+	cursor := Editor.cursor
+	return cursor.grid == grid.id && cursor.row == row && cursor.col >= first && cursor.col < last
+}
+
+// Marks every cell of the row for drawing.
+func (grid *Grid) MarkRowForDraw(row int) {
+	if row < 0 || row >= grid.rows {
+		return
+	}
+	for col := range grid.cells[row] {
+		grid.cells[row][col].needsDraw = true
 	}
 }
 
@@ -283,5 +518,9 @@ func (grid *Grid) Render() {
 
 func (grid *Grid) Destroy() {
 	logger.Log(logger.DEBUG, "Grid destroyed:", grid)
+	if grid.external != nil {
+		grid.external.Destroy()
+		grid.external = nil
+	}
 	grid.renderer.Destroy()
 }
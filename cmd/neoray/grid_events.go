@@ -5,6 +5,7 @@ import (
 	"unicode"
 
 	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/neovim/go-client/nvim"
 )
 
@@ -19,6 +20,8 @@ func (manager *GridManager) HandleEvents() {
 			title := event[1].([]interface{})[0].(string)
 			Editor.window.SetTitle(title)
 		case "set_icon":
+			icon := event[1].([]interface{})[0].(string)
+			Editor.window.SetIconTitle(icon)
 		case "mode_info_set":
 			manager.mode_info_set(event[1:])
 		case "option_set":
@@ -26,15 +29,20 @@ func (manager *GridManager) HandleEvents() {
 		case "mode_change":
 			manager.mode_change(event[1:])
 		case "mouse_on":
+			SetMouseEnabled(true)
 		case "mouse_off":
+			SetMouseEnabled(false)
 		case "busy_start":
-			Editor.cursor.Hide()
+			SetBusy(true)
 		case "busy_stop":
-			Editor.cursor.Show()
+			SetBusy(false)
 		case "suspend":
+			Editor.window.Minimize()
 		case "update_menu":
 		case "bell":
+			Editor.bell.Ring(false)
 		case "visual_bell":
+			Editor.bell.Ring(true)
 		case "flush":
 			if Editor.state < EditorFirstFlush {
 				SetEditorState(EditorFirstFlush)
@@ -119,14 +127,17 @@ func (manager *GridManager) option_set(args []interface{}) {
 			options.ambiwidth = val.(string)
 		case "emoji":
 			options.emoji = val.(bool)
+			// Emoji are double width when the option is set
+			Editor.gridManager.SetWideEmoji(options.emoji)
 		case "guifont":
 			options.setGuiFont(val.(string))
 		case "guifontset":
 			options.guifontset = val.(string)
 		case "guifontwide":
-			options.guifontwide = val.(string)
+			options.setGuiFontWide(val.(string))
 		case "linespace":
 			options.linespace = to_int(val)
+			Editor.gridManager.SetCellAdjust(CellAdjust())
 		case "pumblend":
 			options.pumblend = to_int(val)
 		case "showtabline":
@@ -214,48 +225,54 @@ func (manager *GridManager) hl_attr_define(args []interface{}) {
 		// contains attribute keys
 		hl_id := to_int(arg[0])
 		attribs := arg[1].(map[string]interface{})
-		hl_attr := HighlightAttribute{}
-		// iterate over map and set attributes
-		for k, v := range attribs {
-			switch k {
-			case "foreground":
-				fg := to_uint32(v)
-				hl_attr.foreground = common.ColorFromUint(fg)
-			case "background":
-				bg := to_uint32(v)
-				hl_attr.background = common.ColorFromUint(bg)
-			case "special":
-				sp := to_uint32(v)
-				hl_attr.special = common.ColorFromUint(sp)
-			// All boolean keys default to false,
-			// and will only be sent when they are true.
-			case "reverse":
-				hl_attr.reverse = true
-			case "italic":
-				hl_attr.italic = true
-			case "bold":
-				hl_attr.bold = true
-			case "strikethrough":
-				hl_attr.strikethrough = true
-			case "underline":
-				hl_attr.underline = true
-			case "underlineline":
-				// hl_attr.underlineline = true
-			case "undercurl":
-				hl_attr.undercurl = true
-			case "underdot":
-				// hl_attr.underdot = true
-			case "underdash":
-				// hl_attr.underdash = true
-			case "blend":
-				// hl_attr.blend = int(val.Convert(t_uint).Uint())
-			}
-		}
-		manager.attributes[hl_id] = hl_attr
+		manager.attributes[hl_id] = parseHighlightAttribute(attribs)
 		MarkForceDraw()
 	}
 }
 
+// Creates highlight attribute from neovim's attribute map. The same map is
+// used in hl_attr_define event and nvim_get_hl_by_name api call.
+func parseHighlightAttribute(attribs map[string]interface{}) HighlightAttribute {
+	hl_attr := HighlightAttribute{}
+	// iterate over map and set attributes
+	for k, v := range attribs {
+		switch k {
+		case "foreground":
+			fg := to_uint32(v)
+			hl_attr.foreground = common.ColorFromUint(fg)
+		case "background":
+			bg := to_uint32(v)
+			hl_attr.background = common.ColorFromUint(bg)
+		case "special":
+			sp := to_uint32(v)
+			hl_attr.special = common.ColorFromUint(sp)
+		// All boolean keys default to false,
+		// and will only be sent when they are true.
+		case "reverse":
+			hl_attr.reverse = true
+		case "italic":
+			hl_attr.italic = true
+		case "bold":
+			hl_attr.bold = true
+		case "strikethrough":
+			hl_attr.strikethrough = true
+		case "underline":
+			hl_attr.underline = true
+		case "underlineline":
+			hl_attr.underlineline = true
+		case "undercurl":
+			hl_attr.undercurl = true
+		case "underdot":
+			hl_attr.underdot = true
+		case "underdash":
+			hl_attr.underdash = true
+		case "blend":
+			// hl_attr.blend = int(val.Convert(t_uint).Uint())
+		}
+	}
+	return hl_attr
+}
+
 func (manager *GridManager) grid_line(args []interface{}) {
 	for _, arg := range args {
 		arg := arg.([]interface{})
@@ -272,7 +289,9 @@ func (manager *GridManager) grid_line(args []interface{}) {
 			var char rune
 			str := cell[0].(string)
 			if len(str) > 0 {
-				char = []rune(str)[0]
+				// Grapheme clusters of multiple code points are
+				// represented by an interned rune
+				char = fontkit.ClusterRune(str)
 				// If this is a space, we set it to zero
 				// because otherwise we try to draw every space
 				if unicode.IsSpace(char) {
@@ -382,14 +401,12 @@ func (manager *GridManager) win_float_pos(args []interface{}) {
 }
 
 func (manager *GridManager) win_external_pos(args []interface{}) {
-	// NOTE: Currently not supported
-	/*
-		for _, arg := range args {
-			arg := arg.([]interface{})
-			grid := to_int(arg[0])
-			win := arg[1].(nvim.Window)
-		}
-	*/
+	for _, arg := range args {
+		arg := arg.([]interface{})
+		grid_id := to_int(arg[0])
+		win := arg[1].(nvim.Window)
+		manager.SetGridExternal(grid_id, win)
+	}
 }
 
 func (manager *GridManager) win_hide(args []interface{}) {
@@ -426,15 +443,29 @@ func (manager *GridManager) msg_set_pos(args []interface{}) {
 }
 
 func (manager *GridManager) win_viewport(args []interface{}) {
-	/*
-		for _, arg := range args {
-			arg := arg.([]interface{})
-			grid_id := to_int(arg[0])
-			win := arg[1].(nvim.Window)
-			topline := to_int(arg[2])
-			botline := to_int(arg[3])
-			curline := to_int(arg[4])
-			curcol := to_int(arg[5])
+	// Only used for smooth scrolling when multigrid is on
+	if !Editor.parsedArgs.multiGrid {
+		return
+	}
+	for _, arg := range args {
+		arg := arg.([]interface{})
+		grid_id := to_int(arg[0])
+		// win := arg[1].(nvim.Window)
+		// topline := to_int(arg[2])
+		// botline := to_int(arg[3])
+		// curline := to_int(arg[4])
+		// curcol := to_int(arg[5])
+		grid := manager.Grid(grid_id)
+		if grid == nil {
+			continue
 		}
-	*/
+		// Older versions of neovim doesn't send line_count and scroll_delta,
+		// use scrolled rows instead
+		delta := grid.renderer.PendingScroll()
+		if len(arg) >= 8 {
+			// line_count := to_int(arg[6])
+			delta = to_int(arg[7])
+		}
+		grid.renderer.AnimateScroll(delta)
+	}
 }
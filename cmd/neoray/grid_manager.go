@@ -14,9 +14,12 @@ type GridManager struct {
 	grids       map[int]*Grid
 	sortedGrids []*Grid
 	// These are used for creating new grids
-	totalGridsCreated int              // total number of grids created (including deleted ones)
-	kit               *fontkit.FontKit // last globally set font kit
-	fontSize          float64          // last globally set font size
+	totalGridsCreated int                 // total number of grids created (including deleted ones)
+	kit               *fontkit.FontKit    // last globally set font kit
+	fallbacks         []*fontkit.FontKit  // fallback fonts of the guifont
+	wideKit           *fontkit.FontKit    // font kit for double width characters
+	fontRanges        []fontkit.FontRange // fonts for user specified unicode ranges
+	fontSize          float64             // last globally set font size
 	// style information
 	attributes map[int]HighlightAttribute
 	foreground common.Color // Default foreground color
@@ -52,6 +55,33 @@ func (manager *GridManager) SetGridFontKit(id int, kit *fontkit.FontKit) {
 	MarkForceDraw()
 }
 
+// Fallback fonts, wide font kit and font ranges are same for all grids
+func (manager *GridManager) SetFallbackFontKits(kits []*fontkit.FontKit) {
+	for _, grid := range manager.grids {
+		grid.SetFallbackFontKits(kits)
+	}
+	manager.fallbacks = kits
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetWideFontKit(kit *fontkit.FontKit) {
+	for _, grid := range manager.grids {
+		grid.SetWideFontKit(kit)
+	}
+	manager.wideKit = kit
+	MarkForceDraw()
+}
+
+// Adds a font for the unicode range. Newer ranges overrides older ones if
+// they intersect.
+func (manager *GridManager) AddFontRange(fontRange fontkit.FontRange) {
+	manager.fontRanges = append(manager.fontRanges, fontRange)
+	for _, grid := range manager.grids {
+		grid.SetFontRanges(manager.fontRanges)
+	}
+	MarkForceDraw()
+}
+
 func (manager *GridManager) ResetFontSize() {
 	for _, grid := range manager.grids {
 		grid.SetFontSize(grid.renderer.FontSize(), Editor.window.DPI())
@@ -95,6 +125,30 @@ func (manager *GridManager) AddGridFontSize(id int, v float64) {
 	MarkForceDraw()
 }
 
+func (manager *GridManager) SetCellAdjust(adjust fontkit.CellAdjust) {
+	for _, grid := range manager.grids {
+		grid.SetCellAdjust(adjust)
+	}
+	manager.CheckDefaultGridSize()
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetUndercurlAdjust(adjust fontkit.UndercurlAdjust) {
+	for _, grid := range manager.grids {
+		grid.SetUndercurlAdjust(adjust)
+	}
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetTextRendering(rendering fontkit.Rendering) {
+	for _, grid := range manager.grids {
+		grid.SetTextRendering(rendering)
+	}
+	// Hinting changes the metrics of the cells
+	manager.CheckDefaultGridSize()
+	MarkForceDraw()
+}
+
 func (manager *GridManager) SetBoxDrawing(useBoxDrawing, useBlockDrawing bool) {
 	for _, grid := range manager.grids {
 		grid.SetBoxDrawing(useBoxDrawing, useBlockDrawing)
@@ -102,6 +156,34 @@ func (manager *GridManager) SetBoxDrawing(useBoxDrawing, useBlockDrawing bool) {
 	MarkForceDraw()
 }
 
+func (manager *GridManager) SetWideEmoji(wide bool) {
+	for _, grid := range manager.grids {
+		grid.SetWideEmoji(wide)
+	}
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetPowerlineDrawing(usePowerline bool) {
+	for _, grid := range manager.grids {
+		grid.SetPowerlineDrawing(usePowerline)
+	}
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetSyntheticStyles(bold, italic bool) {
+	for _, grid := range manager.grids {
+		grid.SetSyntheticStyles(bold, italic)
+	}
+	MarkForceDraw()
+}
+
+func (manager *GridManager) SetVariationSettings(settings fontkit.VariationSettings) {
+	for _, grid := range manager.grids {
+		grid.SetVariationSettings(settings)
+	}
+	MarkForceDraw()
+}
+
 func (manager *GridManager) CheckDefaultGridSize() {
 	// We should resize the default grid after font or fontsize change because cell size may has changed
 	defaultGrid := manager.Grid(1)
@@ -182,7 +264,7 @@ func (manager *GridManager) CellAt(pos common.Vector2[int]) (int, int, int) {
 	} else {
 		// Multigrid enabled
 		for _, grid := range manager.sortedGrids {
-			if grid.hidden {
+			if grid.hidden || grid.external != nil {
 				continue
 			}
 			gridPos := manager.GridPosition(grid.sRow, grid.sCol)
@@ -225,6 +307,9 @@ func (manager *GridManager) printCellInfoAt(pos common.Vector2[int]) {
 		Bold:          %v
 		Italic:        %v
 		Underline:     %v
+		Underlineline: %v
+		Underdot:      %v
+		Underdash:     %v
 		Strikethrough: %v
 		Undercurl:     %v
 	Vertex:
@@ -233,19 +318,23 @@ func (manager *GridManager) printCellInfoAt(pos common.Vector2[int]) {
 		Tex2: %v Area: %f
 		Fg:   %v
 		Bg:   %v
-		Sp:   %v`
+		Sp:   %v
+		Style: %v`
 		logger.LogF(logger.DEBUG,
 			format,
 			gridID, row, col,
 			cell,
 			grid,
-			grid.renderer.atlas.GetCharPos(cell.char, attrib.bold, attrib.italic, attrib.underline, attrib.strikethrough, grid.CellSize()),
+			grid.renderer.atlas.GetCharPos(cell.char, attrib.bold, attrib.italic, grid.CellSize()),
 			attrib.foreground,
 			attrib.background,
 			attrib.special,
 			attrib.bold,
 			attrib.italic,
 			attrib.underline,
+			attrib.underlineline,
+			attrib.underdot,
+			attrib.underdash,
 			attrib.strikethrough,
 			attrib.undercurl,
 			vertex.Pos, vertex.Pos.Area(),
@@ -254,6 +343,7 @@ func (manager *GridManager) printCellInfoAt(pos common.Vector2[int]) {
 			vertex.Fg,
 			vertex.Bg,
 			vertex.Sp,
+			vertex.Style,
 		)
 	}
 }
@@ -300,6 +390,15 @@ func (manager *GridManager) ResizeGrid(id int, rows, cols int) {
 		if err != nil {
 			logger.Log(logger.FATAL, "Grid creation failed:", err)
 		}
+		if len(manager.fallbacks) > 0 {
+			grid.SetFallbackFontKits(manager.fallbacks)
+		}
+		if manager.wideKit != nil {
+			grid.SetWideFontKit(manager.wideKit)
+		}
+		if len(manager.fontRanges) > 0 {
+			grid.SetFontRanges(manager.fontRanges)
+		}
 		manager.grids[id] = grid
 	}
 	MarkForceDraw()
@@ -312,10 +411,21 @@ func (manager *GridManager) ScrollGrid(id, top, bot, rows, left, right int) {
 	}
 }
 
+// Only works when multigrid is enabled
+func (manager *GridManager) SetGridExternal(id int, win nvim.Window) {
+	grid, ok := manager.grids[id]
+	if ok {
+		grid.SetExternal(win)
+	}
+}
+
 func (manager *GridManager) HideGrid(id int) {
 	grid, ok := manager.grids[id]
 	if ok {
 		grid.hidden = true
+		if grid.external != nil {
+			grid.external.Hide()
+		}
 		// NOTE: Hide and destroy functions are only calling when multigrid is on.
 		// When this functions called from neovim, we know which grid is hided or
 		// destroyed but we dont know how many grids affected. Because grids can
@@ -368,9 +478,12 @@ func (manager *GridManager) SetCell(id, x int, y *int, char rune, attribId, repe
 	}
 }
 
-func (manager *GridManager) Update() {
+func (manager *GridManager) Update(delta float32) {
 	EndBenchmark := bench.BeginBenchmark()
 	manager.HandleEvents()
+	for _, grid := range manager.grids {
+		grid.renderer.UpdateScroll(delta)
+	}
 	EndBenchmark("GridManager.Update")
 }
 
@@ -383,8 +496,29 @@ func (manager *GridManager) Draw(force bool) {
 	}
 }
 
+// Renders all grids except external ones to the main window
 func (manager *GridManager) Render() {
 	for _, grid := range manager.sortedGrids {
-		grid.Render()
+		if grid.external == nil {
+			grid.Render()
+		}
+	}
+}
+
+// External windows have their own context, they must be rendered after the
+// main window is flushed.
+func (manager *GridManager) RenderExternals() {
+	for _, grid := range manager.sortedGrids {
+		if grid.external != nil && !grid.hidden {
+			grid.external.Render()
+		}
+	}
+}
+
+func (manager *GridManager) PollExternalEvents() {
+	for _, grid := range manager.grids {
+		if grid.external != nil {
+			grid.external.PollEvents()
+		}
 	}
 }
@@ -8,16 +8,28 @@ import (
 )
 
 type GridRenderer struct {
+	window   *window.Window       // Window which owns the opengl objects of this renderer
 	atlas    *opengl.Atlas        // Font atlas of this renderer
 	buffer   *opengl.VertexBuffer // Vertex buffer of this renderer
 	position common.Vector2[int]
 	rows     int
 	cols     int
+	scroll   GridScroll // Smooth scrolling state
+	// Called for the cells cleared by an atlas eviction, may be nil
+	invalidateHandler func(row, col int)
 }
 
-func NewGridRenderer(window *window.Window, rows, cols int, kit *fontkit.FontKit, fontSize float64, position common.Vector2[int]) (*GridRenderer, error) {
+func NewGridRenderer(window *window.Window, rows, cols int, kit *fontkit.FontKit, fontSize float64, adjust fontkit.CellAdjust, position common.Vector2[int]) (*GridRenderer, error) {
 	renderer := new(GridRenderer)
-	renderer.atlas = window.GL().NewAtlas(kit, fontSize, window.DPI(), Editor.options.boxDrawingEnabled, Editor.options.boxDrawingEnabled)
+	renderer.window = window
+	renderer.atlas = window.GL().NewAtlas(kit, fontSize, window.DPI(), Editor.options.boxDrawingEnabled, Editor.options.blockDrawingEnabled, adjust)
+	renderer.atlas.SetPowerlineDrawing(Editor.options.powerlineDrawing)
+	renderer.atlas.SetUndercurlAdjust(UndercurlAdjust())
+	renderer.atlas.SetRendering(TextRendering())
+	renderer.atlas.SetSyntheticStyles(Editor.options.syntheticBold, Editor.options.syntheticItalic)
+	renderer.atlas.SetVariationSettings(Editor.options.fontVariations)
+	renderer.atlas.SetWideEmoji(Editor.uiOptions.emoji)
+	renderer.atlas.SetEvictHandler(renderer.atlasPageEvicted)
 	renderer.buffer = window.GL().CreateVertexBuffer(rows * cols)
 	renderer.rows = rows
 	renderer.cols = cols
@@ -26,11 +38,29 @@ func NewGridRenderer(window *window.Window, rows, cols int, kit *fontkit.FontKit
 	return renderer, nil
 }
 
+// Sets the function called for every cell which must be drawn again because
+// its glyph is evicted from the atlas.
+func (renderer *GridRenderer) SetInvalidateHandler(handler func(row, col int)) {
+	renderer.invalidateHandler = handler
+}
+
 func (renderer *GridRenderer) SetFontKit(kit *fontkit.FontKit) {
 	renderer.atlas.SetFontKit(kit)
 	renderer.UpdatePositions()
 }
 
+func (renderer *GridRenderer) SetFallbackFontKits(kits []*fontkit.FontKit) {
+	renderer.atlas.SetFallbackFontKits(kits)
+}
+
+func (renderer *GridRenderer) SetWideFontKit(kit *fontkit.FontKit) {
+	renderer.atlas.SetWideFontKit(kit)
+}
+
+func (renderer *GridRenderer) SetFontRanges(ranges []fontkit.FontRange) {
+	renderer.atlas.SetFontRanges(ranges)
+}
+
 func (renderer *GridRenderer) FontSize() float64 {
 	return renderer.atlas.FontSize()
 }
@@ -40,11 +70,45 @@ func (renderer *GridRenderer) SetFontSize(fontSize, dpi float64) {
 	renderer.UpdatePositions()
 }
 
+func (renderer *GridRenderer) SetCellAdjust(adjust fontkit.CellAdjust) {
+	renderer.atlas.SetCellAdjust(adjust)
+	renderer.UpdatePositions()
+}
+
+func (renderer *GridRenderer) SetUndercurlAdjust(adjust fontkit.UndercurlAdjust) {
+	renderer.atlas.SetUndercurlAdjust(adjust)
+}
+
+func (renderer *GridRenderer) SetTextRendering(rendering fontkit.Rendering) {
+	renderer.atlas.SetRendering(rendering)
+	renderer.UpdatePositions()
+}
+
 func (renderer *GridRenderer) SetBoxDrawing(useBoxDrawing, useBlockDrawing bool) {
 	renderer.atlas.SetBoxDrawing(useBoxDrawing, useBlockDrawing)
 }
 
+func (renderer *GridRenderer) SetPowerlineDrawing(usePowerline bool) {
+	renderer.atlas.SetPowerlineDrawing(usePowerline)
+}
+
+func (renderer *GridRenderer) SetSyntheticStyles(bold, italic bool) {
+	renderer.atlas.SetSyntheticStyles(bold, italic)
+}
+
+func (renderer *GridRenderer) SetVariationSettings(settings fontkit.VariationSettings) {
+	renderer.atlas.SetVariationSettings(settings)
+}
+
+func (renderer *GridRenderer) SetWideEmoji(wide bool) {
+	renderer.atlas.SetWideEmoji(wide)
+}
+
 func (renderer *GridRenderer) SetPos(position common.Vector2[int]) {
+	if position == renderer.position {
+		// Neovim sends positions of the windows with every layout change
+		return
+	}
 	renderer.position = position
 	renderer.UpdatePositions()
 }
@@ -89,6 +153,8 @@ func (renderer *GridRenderer) cellIndex(row, col int) int {
 }
 
 func (renderer *GridRenderer) UpdatePositions() {
+	// Scroll animation is no longer valid when the layout changed
+	renderer.resetScroll()
 	cellSize := renderer.atlas.ImageSize()
 	for row := 0; row < renderer.rows; row++ {
 		for col := 0; col < renderer.cols; col++ {
@@ -120,40 +186,26 @@ func (renderer *GridRenderer) DrawCell(row, col int, char rune, attrib Highlight
 	// Draw background
 	renderer.buffer.SetIndexBg(index, attrib.background)
 
+	// Empty cells may have decorations too, like the trailing spaces
+	renderer.drawSpecial(index, attrib)
+
 	if char == 0 {
 		// This is an empty cell, clear foreground data (not color)
 		// We will not clear foreground color because may the previous cell is a multiwidth character
 		// and it may set the foreground color of this cell
 		renderer.buffer.SetIndexTex1(index, common.ZeroRectangleF32)
-		renderer.buffer.SetIndexSp(index, common.ZeroColor)
+		renderer.buffer.SetIndexTex1Colored(index, false)
 		if nextIndex != -1 {
 			// Clear next cells second texture
 			renderer.buffer.SetIndexTex2(nextIndex, common.ZeroRectangleF32)
+			renderer.buffer.SetIndexTex2Colored(nextIndex, false)
 		}
 		return
 	}
 
 	cellSize := renderer.atlas.ImageSize()
-
-	if attrib.undercurl {
-		undercurlRect, firstDraw := renderer.atlas.Undercurl(cellSize)
-		if firstDraw {
-			// This is the first time we draw undercurl, because of this we must update
-			// it's position to the shader
-			// Buffer must bound while we updating undercurl rectangle
-			renderer.buffer.Bind()
-			renderer.buffer.SetUndercurlRect(renderer.atlas.Normalize(undercurlRect))
-		}
-		renderer.buffer.SetIndexSp(index, attrib.special)
-	} else {
-		// Setting special color to zero means clear the undercurl. Undercurl
-		// will always be drawed for every cell and multiplied by the special
-		// color. And setting special color to zero makes undercurl fully
-		// transparent. This is also true for other color layouts.
-		renderer.buffer.SetIndexSp(index, common.ZeroColor)
-	}
 	// Get character position in atlas texture
-	atlasPos := renderer.atlas.GetCharPos(char, attrib.bold, attrib.italic, attrib.underline, attrib.strikethrough, cellSize)
+	atlasPos, colored := renderer.atlas.GetChar(char, attrib.bold, attrib.italic, cellSize)
 	// Check if there is a require for second texture in next cell
 	if atlasPos.W > cellSize.Width() {
 		// The atlas width will be 2 times wider if the char is a multiwidth char
@@ -170,26 +222,136 @@ func (renderer *GridRenderer) DrawCell(row, col int, char rune, attrib Highlight
 				H: cellSize.Height(),
 			}
 			renderer.buffer.SetIndexTex2(nextIndex, renderer.atlas.Normalize(secAtlasPos))
+			renderer.buffer.SetIndexTex2Colored(nextIndex, colored)
 			renderer.buffer.SetIndexFg(nextIndex, attrib.foreground)
 		}
 	} else if nextIndex != -1 {
 		// Clear second texture.
 		renderer.buffer.SetIndexTex2(nextIndex, common.ZeroRectangleF32)
+		renderer.buffer.SetIndexTex2Colored(nextIndex, false)
 	}
 	// Draw
 	renderer.buffer.SetIndexTex1(index, renderer.atlas.Normalize(atlasPos))
+	renderer.buffer.SetIndexTex1Colored(index, colored)
 	renderer.buffer.SetIndexFg(index, attrib.foreground)
 }
 
+// Returns the decorations of the attribute drawn by the shaders.
+func (attrib HighlightAttribute) decorations() opengl.Decoration {
+	var style opengl.Decoration
+	if attrib.underline {
+		style |= opengl.DecorationUnderline
+	}
+	if attrib.underlineline {
+		style |= opengl.DecorationUnderlineLine
+	}
+	if attrib.underdot {
+		style |= opengl.DecorationUnderdot
+	}
+	if attrib.underdash {
+		style |= opengl.DecorationUnderdash
+	}
+	if attrib.undercurl {
+		style |= opengl.DecorationUndercurl
+	}
+	if attrib.strikethrough {
+		style |= opengl.DecorationStrikethrough
+	}
+	return style
+}
+
+// Sets the decorations and special color of the cell. All decorations are
+// drawn with the special color.
+func (renderer *GridRenderer) drawSpecial(index int, attrib HighlightAttribute) {
+	renderer.buffer.SetIndexStyle(index, attrib.decorations())
+	renderer.buffer.SetIndexSp(index, attrib.special)
+}
+
+// Draws the cells of the ligature cluster starting from the column. Every
+// cell draws its own part of the cluster image. Cells must have the same
+// attribute.
+func (renderer *GridRenderer) DrawCluster(row, col int, cluster opengl.LigatureCluster, attrib HighlightAttribute) {
+	cellSize := renderer.atlas.ImageSize()
+	atlasPos := renderer.atlas.GetClusterPos(cluster, attrib.bold, attrib.italic, cellSize)
+	for i := 0; i < cluster.End-cluster.Start; i++ {
+		index := renderer.cellIndex(row, col+i)
+		renderer.buffer.SetIndexBg(index, attrib.background)
+		renderer.drawSpecial(index, attrib)
+		cellPos := common.Rectangle[int]{
+			X: atlasPos.X + i*cellSize.Width(),
+			Y: atlasPos.Y,
+			W: cellSize.Width(),
+			H: cellSize.Height(),
+		}
+		renderer.buffer.SetIndexTex1(index, renderer.atlas.Normalize(cellPos))
+		renderer.buffer.SetIndexTex1Colored(index, false)
+		renderer.buffer.SetIndexFg(index, attrib.foreground)
+		if col+i+1 < renderer.cols {
+			renderer.buffer.SetIndexTex2(index+1, common.ZeroRectangleF32)
+			renderer.buffer.SetIndexTex2Colored(index+1, false)
+		}
+	}
+}
+
 func (renderer *GridRenderer) Render() {
 	renderer.atlas.BindTexture()
 	renderer.buffer.Bind()
 	renderer.buffer.Update()
-	renderer.buffer.SetProjection(Editor.window.Viewport().ToF32())
+	renderer.buffer.SetProjection(renderer.window.Viewport().ToF32())
+	renderer.buffer.SetDecorationMetrics(renderer.atlas.DecorationMetrics())
+	for index := 0; index < renderer.rows*renderer.cols; index++ {
+		renderer.touchPages(renderer.buffer.VertexAt(index))
+	}
 	renderer.buffer.Render()
+	if renderer.scroll.active {
+		renderer.renderScroll()
+	}
+	renderer.atlas.NextGeneration()
+}
+
+// Marks the atlas pages of the vertex textures as used in this frame.
+func (renderer *GridRenderer) touchPages(vertex opengl.Vertex) {
+	if vertex.Tex1.W > 0 {
+		renderer.atlas.TouchPage(int(vertex.Tex1.Y))
+	}
+	if vertex.Tex2.W > 0 {
+		renderer.atlas.TouchPage(int(vertex.Tex2.Y))
+	}
+}
+
+// Clears the textures of the cells drawn from the evicted atlas page, the
+// page may be filled with other glyphs. Integer part of the normalized
+// texture position is the page.
+func (renderer *GridRenderer) atlasPageEvicted(page int) {
+	for row := 0; row < renderer.rows; row++ {
+		for col := 0; col < renderer.cols; col++ {
+			index := renderer.cellIndex(row, col)
+			vertex := renderer.buffer.VertexAt(index)
+			if vertex.Tex1.W > 0 && int(vertex.Tex1.Y) == page {
+				renderer.buffer.SetIndexTex1(index, common.ZeroRectangleF32)
+				renderer.invalidate(row, col)
+			}
+			// Second texture is drawn by the previous cell
+			if vertex.Tex2.W > 0 && int(vertex.Tex2.Y) == page {
+				renderer.buffer.SetIndexTex2(index, common.ZeroRectangleF32)
+				if col > 0 {
+					renderer.invalidate(row, col-1)
+				}
+			}
+		}
+	}
+	renderer.evictScrollRows(page)
+	MarkDraw()
+}
+
+func (renderer *GridRenderer) invalidate(row, col int) {
+	if renderer.invalidateHandler != nil {
+		renderer.invalidateHandler(row, col)
+	}
 }
 
 func (renderer *GridRenderer) Destroy() {
 	renderer.atlas.Destroy()
 	renderer.buffer.Destroy()
+	renderer.destroyScroll()
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/opengl"
+)
+
+// Smooth scrolling
+//
+// When a region of the grid scrolled, cells are copied immediately like
+// before but the region is rendered with a pixel offset which makes the
+// content look like it is still in its old position. The offset is animated
+// to zero. Rows scrolled out of the region are kept in an off-screen buffer
+// and rendered next to the region with the same offset until the animation
+// finishes.
+
+// A row scrolled out of the scroll region
+type scrollbackRow struct {
+	row      int // Virtual row position relative to the grid, may be out of the grid
+	vertices []opengl.Vertex
+}
+
+type GridScroll struct {
+	active   bool                 // There is a scroll animation
+	top, bot int                  // Scroll region rows [top, bot)
+	left     int                  // Scroll region columns [left, right)
+	right    int                  //
+	offset   float32              // Current vertical pixel offset of the region
+	anim     common.Animation     // Animates offset to zero, only Y is used
+	rows     []scrollbackRow      // Rows scrolled out of the region
+	buffer   *opengl.VertexBuffer // Off-screen buffer for scrolled out rows
+	dirty    bool                 // Rows changed, buffer must be updated
+	pending  int                  // Scrolled rows not animated yet
+}
+
+func (renderer *GridRenderer) resetScroll() {
+	renderer.scroll.active = false
+	renderer.scroll.offset = 0
+	renderer.scroll.rows = renderer.scroll.rows[:0]
+}
+
+func (renderer *GridRenderer) IsScrolling() bool {
+	return renderer.scroll.active
+}
+
+// Returns current pixel offset of the cell. Only cells in scroll region
+// are offset.
+func (renderer *GridRenderer) ScrollOffsetAt(row, col int) float32 {
+	scroll := &renderer.scroll
+	if scroll.active && row >= scroll.top && row < scroll.bot && col >= scroll.left && col < scroll.right {
+		return scroll.offset
+	}
+	return 0
+}
+
+// Must be called before rows copied. Saves rows will be scrolled out of the
+// region. Does nothing if smooth scrolling is disabled.
+func (renderer *GridRenderer) BeginScroll(top, bot, left, right, rows int) {
+	if Editor.options.scrollAnimTime <= 0 {
+		return
+	}
+	scroll := &renderer.scroll
+	sameRegion := top == scroll.top && bot == scroll.bot && left == scroll.left && right == scroll.right
+	if !sameRegion || (!scroll.active && scroll.pending == 0) {
+		// Another region is scrolling or saved rows belongs to a scroll
+		// which is never animated. We can only animate one region.
+		renderer.resetScroll()
+		scroll.pending = 0
+	}
+	scroll.top, scroll.bot = top, bot
+	scroll.left, scroll.right = left, right
+	scroll.pending += rows
+	// Shift already saved rows
+	for i := range scroll.rows {
+		scroll.rows[i].row -= rows
+	}
+	// Save rows
+	begin, end := top, top+rows
+	if rows < 0 {
+		begin, end = bot+rows, bot
+	}
+	for row := common.Max(begin, top); row < common.Min(end, bot); row++ {
+		saved := scrollbackRow{
+			row:      row - rows,
+			vertices: make([]opengl.Vertex, right-left),
+		}
+		for col := left; col < right; col++ {
+			saved.vertices[col-left] = renderer.buffer.VertexAt(renderer.cellIndex(row, col))
+		}
+		scroll.rows = append(scroll.rows, saved)
+	}
+	scroll.dirty = true
+}
+
+// Returns the total number of rows scrolled since the last AnimateScroll call
+func (renderer *GridRenderer) PendingScroll() int {
+	return renderer.scroll.pending
+}
+
+// Starts or continues the scroll animation of the last scrolled region.
+// If there is no scroll region then whole grid will be animated.
+func (renderer *GridRenderer) AnimateScroll(rows int) {
+	scroll := &renderer.scroll
+	scroll.pending = 0
+	if Editor.options.scrollAnimTime <= 0 || rows == 0 {
+		return
+	}
+	if scroll.bot <= scroll.top || scroll.right <= scroll.left {
+		scroll.top, scroll.bot = 0, renderer.rows
+		scroll.left, scroll.right = 0, renderer.cols
+	}
+	cellHeight := float32(renderer.atlas.ImageSize().Height())
+	limit := float32(scroll.bot-scroll.top) * cellHeight
+	offset := common.Clamp(scroll.offset+float32(rows)*cellHeight, -limit, limit)
+	scroll.anim = common.NewAnimation(common.Vec2[float32](0, offset), common.Vec2[float32](0, 0), Editor.options.scrollAnimTime)
+	scroll.offset = offset
+	scroll.active = true
+	// Remove the rows that will never be visible
+	visibleRows := int(math.Ceil(float64(common.Abs(offset) / cellHeight)))
+	i := 0
+	for _, saved := range scroll.rows {
+		if saved.row >= scroll.top-visibleRows && saved.row < scroll.bot+visibleRows {
+			scroll.rows[i] = saved
+			i++
+		}
+	}
+	scroll.rows = scroll.rows[:i]
+	scroll.dirty = true
+	MarkDraw()
+}
+
+func (renderer *GridRenderer) UpdateScroll(delta float32) {
+	scroll := &renderer.scroll
+	if !scroll.active {
+		return
+	}
+	scroll.offset = scroll.anim.Step(delta).Y
+	if scroll.anim.IsFinished() {
+		renderer.resetScroll()
+		scroll.top, scroll.bot = 0, 0
+		scroll.left, scroll.right = 0, 0
+	}
+	// Cursor also needs to be drawn for every frame
+	MarkDraw()
+}
+
+// Offsets the projection vertically. Positive offset moves the content down.
+func offsetProjection(viewport common.Rectangle[float32], offset float32) common.Rectangle[float32] {
+	// SetProjection uses X as top and H as bottom
+	viewport.X -= offset
+	viewport.H -= offset
+	return viewport
+}
+
+func (renderer *GridRenderer) renderScroll() {
+	scroll := &renderer.scroll
+	cellSize := renderer.atlas.ImageSize()
+	// Only scroll region will be affected
+	region := common.Rectangle[int]{
+		X: renderer.position.X + scroll.left*cellSize.Width(),
+		Y: renderer.position.Y + scroll.top*cellSize.Height(),
+		W: (scroll.right - scroll.left) * cellSize.Width(),
+		H: (scroll.bot - scroll.top) * cellSize.Height(),
+	}
+	context := renderer.window.GL()
+	context.EnableScissor(region)
+	defer context.DisableScissor()
+	// Clear region, there may be no content for some parts of it
+	bg := Editor.gridManager.background
+	bg.A = Editor.options.transparency
+	context.ClearScreen(bg)
+	viewport := renderer.window.Viewport().ToF32()
+	renderer.buffer.SetProjection(offsetProjection(viewport, scroll.offset))
+	for row := scroll.top; row < scroll.bot; row++ {
+		renderer.buffer.RenderRange(renderer.cellIndex(row, scroll.left), scroll.right-scroll.left)
+	}
+	if len(scroll.rows) > 0 {
+		if scroll.buffer == nil {
+			scroll.buffer = context.CreateVertexBuffer(len(scroll.rows) * len(scroll.rows[0].vertices))
+		}
+		scroll.buffer.Bind()
+		if scroll.dirty {
+			cols := scroll.right - scroll.left
+			scroll.buffer.Resize(len(scroll.rows) * cols)
+			for i, saved := range scroll.rows {
+				for col, vertex := range saved.vertices {
+					vertex.Pos = renderer.cellPos(saved.row, scroll.left+col, cellSize)
+					scroll.buffer.SetVertex(i*cols+col, vertex)
+				}
+			}
+			scroll.buffer.Update()
+			scroll.dirty = false
+		}
+		for _, saved := range scroll.rows {
+			for _, vertex := range saved.vertices {
+				renderer.touchPages(vertex)
+			}
+		}
+		scroll.buffer.Render()
+	}
+	// Restore projection for the next renderers
+	renderer.buffer.SetProjection(viewport)
+}
+
+// Clears the textures of the scrolled out rows drawn from the evicted atlas
+// page. These rows are not in the grid anymore and can't be drawn again, they
+// are rendered without the glyphs until the animation finishes.
+func (renderer *GridRenderer) evictScrollRows(page int) {
+	scroll := &renderer.scroll
+	for _, saved := range scroll.rows {
+		for col := range saved.vertices {
+			vertex := &saved.vertices[col]
+			if vertex.Tex1.W > 0 && int(vertex.Tex1.Y) == page {
+				vertex.Tex1 = common.ZeroRectangleF32
+				scroll.dirty = true
+			}
+			if vertex.Tex2.W > 0 && int(vertex.Tex2.Y) == page {
+				vertex.Tex2 = common.ZeroRectangleF32
+				scroll.dirty = true
+			}
+		}
+	}
+}
+
+func (renderer *GridRenderer) destroyScroll() {
+	if renderer.scroll.buffer != nil {
+		renderer.scroll.buffer.Destroy()
+		renderer.scroll.buffer = nil
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/opengl"
+)
+
+func TestEvictScrollRows(t *testing.T) {
+	renderer := &GridRenderer{}
+	first := common.Rectangle[float32]{X: 0.25, Y: 0.5, W: 0.1, H: 0.2}
+	second := common.Rectangle[float32]{X: 0.25, Y: 1.5, W: 0.1, H: 0.2}
+	renderer.scroll.rows = []scrollbackRow{
+		{row: -1, vertices: []opengl.Vertex{{Tex1: first, Tex2: second}, {Tex1: second}}},
+		{row: 5, vertices: []opengl.Vertex{{Tex1: first}}},
+	}
+	renderer.evictScrollRows(1)
+	if !renderer.scroll.dirty {
+		t.Error("scroll buffer is not updated after the eviction")
+	}
+	rows := renderer.scroll.rows
+	if rows[0].vertices[0].Tex1 != first || rows[1].vertices[0].Tex1 != first {
+		t.Error("textures in other pages are cleared")
+	}
+	if rows[0].vertices[0].Tex2 != common.ZeroRectangleF32 || rows[0].vertices[1].Tex1 != common.ZeroRectangleF32 {
+		t.Error("textures in the evicted page are not cleared")
+	}
+}
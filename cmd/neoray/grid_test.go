@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/opengl"
+)
+
+func TestLigaturesAreSplitUnderCursor(t *testing.T) {
+	defer func(cursor *Cursor) { Editor.cursor = cursor }(Editor.cursor)
+	grid := &Grid{id: 2, rows: 3, cols: 20}
+	// Run starts from the column 4, clusters are at 5-7 and 10-11
+	clusters := []opengl.LigatureCluster{{Start: 1, End: 4}, {Start: 6, End: 8}}
+	for _, test := range []struct {
+		name   string
+		cursor Cursor
+		want   []int // Start of the drawn clusters
+	}{
+		{"other grid", Cursor{grid: 1, row: 1, col: 6}, []int{1, 6}},
+		{"other row", Cursor{grid: 2, row: 0, col: 6}, []int{1, 6}},
+		{"before", Cursor{grid: 2, row: 1, col: 4}, []int{1, 6}},
+		{"first cell", Cursor{grid: 2, row: 1, col: 5}, []int{6}},
+		{"inside", Cursor{grid: 2, row: 1, col: 6}, []int{6}},
+		{"last cell", Cursor{grid: 2, row: 1, col: 7}, []int{6}},
+		{"between", Cursor{grid: 2, row: 1, col: 8}, []int{1, 6}},
+		{"second", Cursor{grid: 2, row: 1, col: 11}, []int{1}},
+	} {
+		cursor := test.cursor
+		Editor.cursor = &cursor
+		drawn := grid.ligatures(1, 4, clusters)
+		if len(drawn) != len(test.want) {
+			t.Errorf("%s: %d clusters are drawn, expected %d", test.name, len(drawn), len(test.want))
+			continue
+		}
+		for i, cluster := range drawn {
+			if cluster.Start != test.want[i] {
+				t.Errorf("%s: cluster %d starts at %d, expected %d", test.name, i, cluster.Start, test.want[i])
+			}
+		}
+	}
+}
@@ -102,7 +102,7 @@ func (viewer *ImageViewer) SetImage(path string) error {
 		W: imgRGBA.Bounds().Dx(),
 		H: imgRGBA.Bounds().Dy(),
 	}
-	viewer.texture.Draw(imgRGBA, dest)
+	viewer.texture.Draw(imgRGBA, 0, dest)
 	// This is always same for every texture
 	viewer.buffer.SetIndexTex1(0, common.Rectangle[float32]{X: 0, Y: 0, W: 1, H: 1})
 	return nil
@@ -5,6 +5,7 @@ import (
 	"github.com/hismailbulut/Neoray/pkg/bench"
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/hismailbulut/Neoray/pkg/window"
 )
 
 const (
@@ -84,10 +85,16 @@ var (
 		dragGrid    int
 		dragRow     int
 		dragCol     int
+		mouseOff    bool // Neovim doesn't want mouse events (mouse option is empty)
+		busy        bool // Neovim is busy, mouse shape is busy cursor
 	}
 )
 
 func sendKeyInput(keycode string) {
+	// Context menu can be navigated with keyboard while it's open
+	if Editor.contextMenu.KeyInput(keycode) {
+		return
+	}
 	if !checkNeorayKeybindings(keycode) {
 		Editor.nvim.Input(keycode)
 	}
@@ -130,10 +137,48 @@ func sendMouseInput(button, action string, mods common.BitMask, grid, row, colum
 			// :h nvim_input_mouse() says send 0 for grid if multigrid is off
 			grid = 0
 		}
+		if inputCache.mouseOff {
+			return
+		}
 		Editor.nvim.InputMouse(button, action, modsStr(mods), grid, row, column)
 	}
 }
 
+// Sets mouse mode. Neoray keybindings and context menu are still works
+// when the mouse is off.
+func SetMouseEnabled(enabled bool) {
+	inputCache.mouseOff = !enabled
+}
+
+func SetBusy(busy bool) {
+	inputCache.busy = busy
+	if busy {
+		Editor.cursor.Hide()
+	} else {
+		Editor.cursor.Show()
+	}
+	updateMouseShape()
+}
+
+// Chooses mouse cursor shape from what is under the mouse
+func updateMouseShape() {
+	if inputCache.busy {
+		Editor.window.SetCursorShape(window.CursorShapeBusy)
+		return
+	}
+	if Editor.options.contextMenuEnabled && Editor.contextMenu.IsInArea(inputCache.mousePos) {
+		Editor.window.SetCursorShape(window.CursorShapeArrow)
+		return
+	}
+	gridID, _, _ := Editor.gridManager.CellAt(inputCache.mousePos)
+	grid := Editor.gridManager.Grid(gridID)
+	if grid == nil || grid.typ == GridTypeFloat {
+		Editor.window.SetCursorShape(window.CursorShapeArrow)
+		return
+	}
+	Editor.window.SetCursorShape(window.CursorShapeIBeam)
+}
+
 // Returns true if the key is emitted from neoray, and dont send it to neovim.
 func checkNeorayKeybindings(keycode string) bool {
 	// Handle neoray keybindings
@@ -370,6 +415,7 @@ func MouseMoveHandler(xpos, ypos float64) {
 	if Editor.options.contextMenuEnabled {
 		Editor.contextMenu.MouseMove(inputCache.mousePos)
 	}
+	updateMouseShape()
 
 	// If mouse moving when holding button, it's a drag event
 	if inputCache.mouseAction == glfw.Press {
@@ -12,14 +12,38 @@ function s:NeorayCompletion(A, L, P)
 	return 
 	\	[
 	\	'CursorAnimTime',
+	\	'ScrollAnimTime',
 	\	'Transparency',
 	\	'TargetTPS',
 	\	'ContextMenu',
 	\	'ContextButton',
 	\	'BoxDrawing',
+	\	'BlockDrawing',
+	\	'PowerlineDrawing',
+	\	'CellWidthScale',
+	\	'CellHeightScale',
+	\	'BaselineOffset',
+	\	'UndercurlAmplitude',
+	\	'UndercurlWavelength',
+	\	'UndercurlThickness',
+	\	'Hinting',
+	\	'Antialias',
+	\	'TextGamma',
+	\	'TextContrast',
+	\	'FontRange',
+	\	'SyntheticBold',
+	\	'SyntheticItalic',
+	\	'FontWeight',
+	\	'FontBoldWeight',
+	\	'FontVariations',
+	\	'Ligatures',
 	\	'ImageViewer',
 	\	'WindowState',
 	\	'WindowSize',
+	\	'BellFlashTime',
+	\	'BellFlashColor',
+	\	'BellAttention',
+	\	'BellCommand',
 	\	'KeyFullscreen',
 	\	'KeyZoomIn',
 	\	'KeyZoomOut' 
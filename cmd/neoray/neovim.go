@@ -9,6 +9,7 @@ import (
 
 	"github.com/hismailbulut/Neoray/pkg/bench"
 	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
 	"github.com/neovim/go-client/nvim"
 )
@@ -16,14 +17,38 @@ import (
 const (
 	// New options
 	OPTION_CURSOR_ANIM    = "CursorAnimTime"
+	OPTION_SCROLL_ANIM    = "ScrollAnimTime"
 	OPTION_TRANSPARENCY   = "Transparency"
 	OPTION_TARGET_TPS     = "TargetTPS"
 	OPTION_CONTEXT_MENU   = "ContextMenu"
 	OPTION_CONTEXT_BUTTON = "ContextButton"
 	OPTION_BOX_DRAWING    = "BoxDrawing"
+	OPTION_BLOCK_DRAWING  = "BlockDrawing"
+	OPTION_POWERLINE      = "PowerlineDrawing"
+	OPTION_CELL_WIDTH     = "CellWidthScale"
+	OPTION_CELL_HEIGHT    = "CellHeightScale"
+	OPTION_BASELINE       = "BaselineOffset"
+	OPTION_CURL_AMPLITUDE = "UndercurlAmplitude"
+	OPTION_CURL_LENGTH    = "UndercurlWavelength"
+	OPTION_CURL_THICKNESS = "UndercurlThickness"
+	OPTION_HINTING        = "Hinting"
+	OPTION_ANTIALIAS      = "Antialias"
+	OPTION_TEXT_GAMMA     = "TextGamma"
+	OPTION_TEXT_CONTRAST  = "TextContrast"
+	OPTION_FONT_RANGE     = "FontRange"
+	OPTION_SYNTH_BOLD     = "SyntheticBold"
+	OPTION_SYNTH_ITALIC   = "SyntheticItalic"
+	OPTION_FONT_WEIGHT    = "FontWeight"
+	OPTION_BOLD_WEIGHT    = "FontBoldWeight"
+	OPTION_FONT_VARIATION = "FontVariations"
+	OPTION_LIGATURES      = "Ligatures"
 	OPTION_IMAGE_VIEWER   = "ImageViewer"
 	OPTION_WINDOW_STATE   = "WindowState"
 	OPTION_WINDOW_SIZE    = "WindowSize"
+	OPTION_BELL_FLASH     = "BellFlashTime"
+	OPTION_BELL_COLOR     = "BellFlashColor"
+	OPTION_BELL_ATTENTION = "BellAttention"
+	OPTION_BELL_COMMAND   = "BellCommand"
 	// Keybindings
 	OPTION_KEY_FULLSCRN = "KeyFullscreen"
 	OPTION_KEY_ZOOMIN   = "KeyZoomIn"
@@ -273,6 +298,16 @@ func (proc *NvimProcess) processOption(opt []string) {
 			logger.Log(logger.DEBUG, "Option", OPTION_CURSOR_ANIM, "is", opt[1])
 			Editor.options.cursorAnimTime = float32(value)
 		}
+	case OPTION_SCROLL_ANIM:
+		{
+			value, err := strconv.ParseFloat(opt[1], 32)
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_SCROLL_ANIM, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_SCROLL_ANIM, "is", opt[1])
+			Editor.options.scrollAnimTime = common.Max(float32(value), 0)
+		}
 	case OPTION_TRANSPARENCY:
 		{
 			value, err := strconv.ParseFloat(opt[1], 32)
@@ -314,6 +349,10 @@ func (proc *NvimProcess) processOption(opt []string) {
 					name: opt[1],
 					fn:   func() { proc.Command(cmd) },
 				})
+			} else if len(opt) == 2 && (opt[1] == "-" || strings.HasSuffix(opt[1], "/-")) {
+				// Separators don't need a command
+				logger.Log(logger.DEBUG, "Option", OPTION_CONTEXT_BUTTON, "separator", opt[1])
+				Editor.contextMenu.AddButton(ContextButton{name: opt[1]})
 			} else {
 				logger.Log(logger.WARN, "Not enough argument for option", OPTION_CONTEXT_BUTTON)
 			}
@@ -327,8 +366,214 @@ func (proc *NvimProcess) processOption(opt []string) {
 			}
 			logger.Log(logger.DEBUG, "Option", OPTION_BOX_DRAWING, "is", value)
 			Editor.options.boxDrawingEnabled = value
-			// Currently we didn't separate this two options but may be in the future
-			Editor.gridManager.SetBoxDrawing(Editor.options.boxDrawingEnabled, Editor.options.boxDrawingEnabled)
+			Editor.gridManager.SetBoxDrawing(Editor.options.boxDrawingEnabled, Editor.options.blockDrawingEnabled)
+		}
+	case OPTION_BLOCK_DRAWING:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_BLOCK_DRAWING, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BLOCK_DRAWING, "is", value)
+			Editor.options.blockDrawingEnabled = value
+			Editor.gridManager.SetBoxDrawing(Editor.options.boxDrawingEnabled, Editor.options.blockDrawingEnabled)
+		}
+	case OPTION_POWERLINE:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_POWERLINE, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_POWERLINE, "is", value)
+			Editor.options.powerlineDrawing = value
+			Editor.gridManager.SetPowerlineDrawing(Editor.options.powerlineDrawing)
+		}
+	case OPTION_CELL_WIDTH:
+		{
+			value, err := strconv.ParseFloat(opt[1], 64)
+			if err != nil || value <= 0 {
+				logger.Log(logger.WARN, OPTION_CELL_WIDTH, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_CELL_WIDTH, "is", value)
+			Editor.options.cellWidthScale = value
+			Editor.gridManager.SetCellAdjust(CellAdjust())
+		}
+	case OPTION_CELL_HEIGHT:
+		{
+			value, err := strconv.ParseFloat(opt[1], 64)
+			if err != nil || value <= 0 {
+				logger.Log(logger.WARN, OPTION_CELL_HEIGHT, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_CELL_HEIGHT, "is", value)
+			Editor.options.cellHeightScale = value
+			Editor.gridManager.SetCellAdjust(CellAdjust())
+		}
+	case OPTION_BASELINE:
+		{
+			value, err := strconv.Atoi(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_BASELINE, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BASELINE, "is", value)
+			Editor.options.baselineOffset = value
+			Editor.gridManager.SetCellAdjust(CellAdjust())
+		}
+	case OPTION_CURL_AMPLITUDE, OPTION_CURL_LENGTH, OPTION_CURL_THICKNESS:
+		{
+			value, err := strconv.ParseFloat(opt[1], 64)
+			if err != nil || value <= 0 {
+				logger.Log(logger.WARN, opt[0], "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", opt[0], "is", value)
+			switch opt[0] {
+			case OPTION_CURL_AMPLITUDE:
+				Editor.options.undercurlAmplitude = value
+			case OPTION_CURL_LENGTH:
+				Editor.options.undercurlWavelength = value
+			case OPTION_CURL_THICKNESS:
+				Editor.options.undercurlThickness = value
+			}
+			Editor.gridManager.SetUndercurlAdjust(UndercurlAdjust())
+		}
+	case OPTION_HINTING:
+		{
+			value, err := fontkit.ParseHinting(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_HINTING, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_HINTING, "is", opt[1])
+			Editor.options.hinting = value
+			Editor.gridManager.SetTextRendering(TextRendering())
+		}
+	case OPTION_ANTIALIAS:
+		{
+			value, err := fontkit.ParseAntialias(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_ANTIALIAS, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_ANTIALIAS, "is", opt[1])
+			Editor.options.antialias = value
+			Editor.gridManager.SetTextRendering(TextRendering())
+		}
+	case OPTION_TEXT_GAMMA:
+		{
+			value, err := strconv.ParseFloat(opt[1], 64)
+			if err != nil || value <= 0 {
+				logger.Log(logger.WARN, OPTION_TEXT_GAMMA, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_TEXT_GAMMA, "is", value)
+			Editor.options.textGamma = value
+			Editor.gridManager.SetTextRendering(TextRendering())
+		}
+	case OPTION_TEXT_CONTRAST:
+		{
+			value, err := strconv.ParseFloat(opt[1], 64)
+			if err != nil || value < 0 {
+				logger.Log(logger.WARN, OPTION_TEXT_CONTRAST, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_TEXT_CONTRAST, "is", value)
+			Editor.options.textContrast = value
+			Editor.gridManager.SetTextRendering(TextRendering())
+		}
+	case OPTION_FONT_RANGE:
+		{
+			if len(opt) < 3 {
+				logger.Log(logger.WARN, "Not enough argument for option", OPTION_FONT_RANGE)
+				break
+			}
+			first, last, err := fontkit.ParseRange(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_FONT_RANGE, "value isn't valid.")
+				break
+			}
+			// Font name may contain spaces and may be quoted
+			name := strings.Trim(strings.Join(opt[2:], " "), "\"'")
+			name = strings.ReplaceAll(name, "_", " ")
+			kit, err := loadFontKit(name, fontkit.KitOptions{})
+			if err != nil {
+				Editor.nvim.EchoError("Font %s not found", name)
+				break
+			}
+			logger.LogF(logger.DEBUG, "Option %s is U+%04X-U+%04X %s", OPTION_FONT_RANGE, first, last, name)
+			Editor.gridManager.AddFontRange(fontkit.FontRange{First: first, Last: last, Kit: kit})
+		}
+	case OPTION_SYNTH_BOLD:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_SYNTH_BOLD, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_SYNTH_BOLD, "is", value)
+			Editor.options.syntheticBold = value
+			Editor.gridManager.SetSyntheticStyles(Editor.options.syntheticBold, Editor.options.syntheticItalic)
+		}
+	case OPTION_SYNTH_ITALIC:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_SYNTH_ITALIC, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_SYNTH_ITALIC, "is", value)
+			Editor.options.syntheticItalic = value
+			Editor.gridManager.SetSyntheticStyles(Editor.options.syntheticBold, Editor.options.syntheticItalic)
+		}
+	case OPTION_FONT_WEIGHT, OPTION_BOLD_WEIGHT:
+		{
+			// Value is a weight or a named instance of the variable font
+			value := strings.Trim(strings.Join(opt[1:], " "), "\"'")
+			weight, err := strconv.Atoi(value)
+			if value == "" || (err == nil && (weight < 0 || weight > 1000)) {
+				logger.Log(logger.WARN, opt[0], "value isn't valid.")
+				break
+			}
+			instance := ""
+			if err != nil {
+				weight = 0
+				instance = value
+			}
+			logger.Log(logger.DEBUG, "Option", opt[0], "is", value)
+			if opt[0] == OPTION_FONT_WEIGHT {
+				Editor.options.fontVariations.Weight = weight
+				Editor.options.fontVariations.Instance = instance
+			} else {
+				Editor.options.fontVariations.BoldWeight = weight
+				Editor.options.fontVariations.BoldInstance = instance
+			}
+			Editor.gridManager.SetVariationSettings(Editor.options.fontVariations)
+		}
+	case OPTION_FONT_VARIATION:
+		{
+			value, err := fontkit.ParseVariation(strings.Join(opt[1:], ""))
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_FONT_VARIATION, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_FONT_VARIATION, "is", value)
+			Editor.options.fontVariations.Axes = value
+			Editor.gridManager.SetVariationSettings(Editor.options.fontVariations)
+		}
+	case OPTION_LIGATURES:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_LIGATURES, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_LIGATURES, "is", value)
+			Editor.options.ligatures = value
+			MarkForceDraw()
 		}
 	case OPTION_IMAGE_VIEWER:
 		{
@@ -381,6 +626,46 @@ func (proc *NvimProcess) processOption(opt []string) {
 			logger.Log(logger.DEBUG, "Option", OPTION_WINDOW_SIZE, "is", cols, rows)
 			ResizeWindowInCellFormat(rows, cols)
 		}
+	case OPTION_BELL_FLASH:
+		{
+			value, err := strconv.ParseFloat(opt[1], 32)
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_BELL_FLASH, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BELL_FLASH, "is", opt[1])
+			Editor.options.bellFlashTime = common.Max(float32(value), 0)
+		}
+	case OPTION_BELL_COLOR:
+		{
+			value, ok := parseBellColor(opt[1])
+			if !ok {
+				logger.Log(logger.WARN, OPTION_BELL_COLOR, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BELL_COLOR, "is", opt[1])
+			Editor.options.bellFlashColor = value
+		}
+	case OPTION_BELL_ATTENTION:
+		{
+			value, err := strconv.ParseBool(opt[1])
+			if err != nil {
+				logger.Log(logger.WARN, OPTION_BELL_ATTENTION, "value isn't valid.")
+				break
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BELL_ATTENTION, "is", value)
+			Editor.options.bellAttention = value
+		}
+	case OPTION_BELL_COMMAND:
+		{
+			// Empty string or <> disables the command
+			cmd := strings.Join(opt[1:], " ")
+			if cmd == "<>" {
+				cmd = ""
+			}
+			logger.Log(logger.DEBUG, "Option", OPTION_BELL_COMMAND, "is", cmd)
+			Editor.options.bellCommand = cmd
+		}
 	case OPTION_KEY_FULLSCRN:
 		{
 			logger.Log(logger.DEBUG, "Option", OPTION_KEY_FULLSCRN, "is", opt[1])
@@ -412,6 +697,15 @@ func (proc *NvimProcess) Command(format string, args ...interface{}) bool {
 	return true
 }
 
+// Closes the neovim window, fails if the window is the last one or buffer
+// has unsaved changes.
+func (proc *NvimProcess) CloseWindow(win nvim.Window) {
+	err := proc.handle.CloseWindow(win, false)
+	if err != nil {
+		logger.Log(logger.WARN, "Failed to close window:", err)
+	}
+}
+
 // Returns current mode
 func (proc *NvimProcess) Mode() string {
 	mode, err := proc.handle.Mode()
@@ -429,6 +723,18 @@ func (proc *NvimProcess) EchoError(format string, args ...interface{}) {
 	logger.LogF(logger.ERROR, format, args...)
 }
 
+// Returns the attributes of the highlight group. Returns false if the group
+// is not defined or cleared.
+func (proc *NvimProcess) HighlightByName(name string) (HighlightAttribute, bool) {
+	var attribs map[string]interface{}
+	err := proc.handle.Call("nvim_get_hl_by_name", &attribs, name, true)
+	if err != nil {
+		logger.Log(logger.DEBUG, "Api call nvim_get_hl_by_name() failed:", err)
+		return HighlightAttribute{}, false
+	}
+	return parseHighlightAttribute(attribs), len(attribs) > 0
+}
+
 func (proc *NvimProcess) GetRegister(register string) string {
 	var content string
 	err := proc.handle.Call("getreg", &content, register)
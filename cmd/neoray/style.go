@@ -18,9 +18,10 @@ type UIOptions struct {
 	emoji         bool
 	guifont       string
 	guifontset    string
-	guifontwide   string // TODO
-	linespace     int    // TODO
-	pumblend      int    // TODO
+	guifontwide   string
+	guifontWidth  float64 // width option of the guifont
+	linespace     int
+	pumblend      int // TODO
 	showtabline   int
 	termguicolors bool
 	mousehide     bool // will be implemented soon, currently always true
@@ -28,65 +29,196 @@ type UIOptions struct {
 
 func CreateUIOptions() UIOptions {
 	return UIOptions{
+		emoji:     true, // Neovim default
 		mousehide: true,
 	}
 }
 
+// A font in the guifont option
+type GuiFont struct {
+	name    string
+	size    float64 // Zero if not specified
+	width   float64 // Cell width in points, zero if not specified
+	options fontkit.KitOptions
+}
+
+// Parses guifont option. The option is a comma separated list of fonts, every
+// font may have colon separated options like 'Name:h12:b:i:W300:#h-none'.
+// Commas in names can be escaped with a backslash.
+func parseGuiFont(guifont string) []GuiFont {
+	fonts := []GuiFont{}
+	for _, str := range splitGuiFontList(guifont) {
+		// treat underlines like whitespaces
+		str = strings.ReplaceAll(str, "_", " ")
+		// parse font options
+		fontOptions := strings.Split(str, ":")
+		font := GuiFont{
+			name: strings.TrimSpace(fontOptions[0]),
+		}
+		bold := false
+		for _, opt := range fontOptions[1:] {
+			if len(opt) == 0 {
+				continue
+			}
+			var err error
+			switch {
+			case opt[0] == 'h':
+				// Font size
+				font.size, err = strconv.ParseFloat(opt[1:], 64)
+			case opt[0] == 'w':
+				// Cell width
+				font.width, err = strconv.ParseFloat(opt[1:], 64)
+			case opt[0] == 'W':
+				// Weight
+				font.options.Weight, err = strconv.Atoi(opt[1:])
+				if err == nil && (font.options.Weight < 100 || font.options.Weight > 900) {
+					err = strconv.ErrRange
+				}
+			case opt == "b":
+				bold = true
+			case opt == "i":
+				font.options.Italic = true
+			case strings.HasPrefix(opt, "#e-"):
+				font.options.Antialias, err = fontkit.ParseAntialias(opt[3:])
+			case strings.HasPrefix(opt, "#h-"):
+				font.options.Hinting, err = fontkit.ParseHinting(opt[3:])
+			default:
+				// Other vim options like underline, charset and quality are
+				// not supported and silently ignored.
+			}
+			if err != nil {
+				logger.Log(logger.WARN, "Invalid guifont option", opt, "for font", font.name)
+			}
+		}
+		// Weight option overrides bold
+		if bold && font.options.Weight == 0 {
+			font.options.Weight = 700
+		}
+		fonts = append(fonts, font)
+	}
+	return fonts
+}
+
+// Splits the font list by unescaped commas
+func splitGuiFontList(guifont string) []string {
+	list := []string{}
+	var current strings.Builder
+	for i := 0; i < len(guifont); i++ {
+		if guifont[i] == '\\' && i+1 < len(guifont) && guifont[i+1] == ',' {
+			current.WriteByte(',')
+			i++
+		} else if guifont[i] == ',' {
+			list = append(list, current.String())
+			current.Reset()
+		} else {
+			current.WriteByte(guifont[i])
+		}
+	}
+	return append(list, current.String())
+}
+
+// Finds and loads the font kit, also logs the loaded files
+func loadFontKit(name string, options fontkit.KitOptions) (*fontkit.FontKit, error) {
+	logger.Log(logger.TRACE, "Loading font", name)
+	kit, err := fontkit.CreateKitWithOptions(name, options)
+	if err != nil {
+		return nil, err
+	}
+	// Log some info
+	if kit.Regular() != nil {
+		logger.Log(logger.TRACE, "Regular:", kit.Regular().FilePath())
+	}
+	if kit.Bold() != nil {
+		logger.Log(logger.TRACE, "Bold:", kit.Bold().FilePath())
+	}
+	if kit.Italic() != nil {
+		logger.Log(logger.TRACE, "Italic:", kit.Italic().FilePath())
+	}
+	if kit.BoldItalic() != nil {
+		logger.Log(logger.TRACE, "BoldItalic:", kit.BoldItalic().FilePath())
+	}
+	return kit, nil
+}
+
+// Loads all fonts in the list, fonts that can't be found are skipped.
+// Returns false if the list has font names but none of them found.
+func loadGuiFontKits(fonts []GuiFont) ([]*fontkit.FontKit, bool) {
+	kits := []*fontkit.FontKit{}
+	names := []string{}
+	for _, font := range fonts {
+		if font.name == "" {
+			continue
+		}
+		names = append(names, font.name)
+		kit, err := loadFontKit(font.name, font.options)
+		if err != nil {
+			logger.Log(logger.WARN, "Font", font.name, "not found")
+			continue
+		}
+		kits = append(kits, kit)
+	}
+	if len(names) > 0 && len(kits) == 0 {
+		Editor.nvim.EchoError("Font %s not found", strings.Join(names, ", "))
+		return nil, false
+	}
+	return kits, true
+}
+
 func (options *UIOptions) setGuiFont(guifont string) {
 	// Load Font
 	if guifont == options.guifont {
 		return
 	}
 	options.guifont = guifont
-	var size float64 = DEFAULT_FONT_SIZE
-	// treat underlines like whitespaces
-	guifont = strings.ReplaceAll(guifont, "_", " ")
-	// parse font options
-	fontOptions := strings.Split(guifont, ":")
-	name := fontOptions[0]
-	for _, opt := range fontOptions[1:] {
-		if len(opt) > 1 && opt[0] == 'h' {
-			// Font size
-			tsize, err := strconv.ParseFloat(opt[1:], 32)
-			if err == nil {
-				size = tsize
-			}
-		}
-	}
-	if name == "" {
-		// Set nil to disable font
-		Editor.gridManager.SetGridFontKit(1, nil)
-		Editor.contextMenu.SetFontKit(nil)
-	} else {
-		// Create and set font
-		logger.Log(logger.TRACE, "Loading font", name)
-		kit, err := fontkit.CreateKit(name)
-		if err != nil {
-			Editor.nvim.EchoError("Font %s not found", name)
+	fonts := parseGuiFont(guifont)
+	kits, ok := loadGuiFontKits(fonts)
+	if ok {
+		if len(kits) == 0 {
+			// Set nil to disable font
+			Editor.gridManager.SetGridFontKit(1, nil)
+			Editor.gridManager.SetFallbackFontKits(nil)
+			Editor.contextMenu.SetFontKit(nil)
 		} else {
-			// Log some info
-			if kit.Regular() != nil {
-				logger.Log(logger.TRACE, "Regular:", kit.Regular().FilePath())
-			}
-			if kit.Bold() != nil {
-				logger.Log(logger.TRACE, "Bold:", kit.Bold().FilePath())
-			}
-			if kit.Italic() != nil {
-				logger.Log(logger.TRACE, "Italic:", kit.Italic().FilePath())
-			}
-			if kit.BoldItalic() != nil {
-				logger.Log(logger.TRACE, "BoldItalic:", kit.BoldItalic().FilePath())
-			}
-			// Set fonts
-			Editor.gridManager.SetGridFontKit(1, kit)
-			Editor.contextMenu.SetFontKit(kit)
+			// First found font is the main font, others are fallbacks
+			Editor.gridManager.SetGridFontKit(1, kits[0])
+			Editor.gridManager.SetFallbackFontKits(kits[1:])
+			Editor.contextMenu.SetFontKit(kits[0])
 		}
 	}
-	// Always set font size to default if user not set
+	// Size and width of the first font is used for all fonts. Always set
+	// font size to default if user not set.
+	var size float64 = DEFAULT_FONT_SIZE
+	if len(fonts) > 0 && fonts[0].size > 0 {
+		size = fonts[0].size
+	}
+	options.guifontWidth = 0
+	if len(fonts) > 0 {
+		options.guifontWidth = fonts[0].width
+	}
 	Editor.gridManager.SetGridFontSize(1, size)
+	Editor.gridManager.SetCellAdjust(CellAdjust())
 	Editor.contextMenu.SetFontSize(size)
 }
 
+// Wide font is used for double width characters. It has the same size with
+// guifont, size options of the guifontwide are ignored. First found font in
+// the list is used.
+func (options *UIOptions) setGuiFontWide(guifontwide string) {
+	if guifontwide == options.guifontwide {
+		return
+	}
+	options.guifontwide = guifontwide
+	kits, ok := loadGuiFontKits(parseGuiFont(guifontwide))
+	if !ok {
+		return
+	}
+	if len(kits) == 0 {
+		Editor.gridManager.SetWideFontKit(nil)
+		return
+	}
+	Editor.gridManager.SetWideFontKit(kits[0])
+}
+
 type HighlightAttribute struct {
 	foreground    common.Color
 	background    common.Color
@@ -96,10 +228,10 @@ type HighlightAttribute struct {
 	bold          bool
 	strikethrough bool
 	underline     bool
-	// underlineline bool
-	undercurl bool
-	// underdot  bool
-	// underdash bool
+	underlineline bool
+	undercurl     bool
+	underdot      bool
+	underdash     bool
 	// blend     int
 	// TODO: Implement commented attributes
 }
@@ -3,21 +3,19 @@ module github.com/hismailbulut/Neoray
 go 1.18
 
 require (
-	github.com/adrg/sysfont v0.1.2
+	github.com/adrg/xdg v0.4.0
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20220806181222-55e207c401ad
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/neovim/go-client v1.2.1
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/sqweek/dialog v0.0.0-20220809060634-e981b270ebbf
 	golang.org/x/image v0.0.0-20220722155232-062f8c9fd539
+	golang.org/x/text v0.3.7
 )
 
 require (
 	github.com/TheTitanrain/w32 v0.0.0-20200114052255-2654d97dbd3d // indirect
-	github.com/adrg/strutil v0.3.0 // indirect
-	github.com/adrg/xdg v0.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/rivo/uniseg v0.3.4 // indirect
 	golang.org/x/sys v0.0.0-20220808155132-1c4a2a72c664 // indirect
-	golang.org/x/text v0.3.7 // indirect
 )
@@ -28,3 +28,13 @@ func ColorFromUint(color uint32) Color {
 		A: 1.0,
 	}
 }
+
+// Linearly interpolates between c and other, t = 0 returns c and t = 1 returns other
+func (c Color) Mix(other Color, t float32) Color {
+	return Color{
+		R: c.R + (other.R-c.R)*t,
+		G: c.G + (other.G-c.G)*t,
+		B: c.B + (other.B-c.B)*t,
+		A: c.A + (other.A-c.A)*t,
+	}
+}
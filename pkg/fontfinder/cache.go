@@ -0,0 +1,255 @@
+package fontfinder
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"github.com/hismailbulut/Neoray/pkg/logger"
+)
+
+// Font index is cached on disk for faster startup. Every font directory is
+// stored with its modification time, which changes when a file is added to
+// or removed from the directory. Directories that haven't changed are used
+// from the cache without reading them, files in the changed directories are
+// only read again if their modification time or size is changed.
+
+const (
+	// Increase this when the cached data changes, old caches will be ignored
+	fontCacheVersion = 1
+	fontCacheName    = "fontcache.gob"
+)
+
+var fontExtensions = map[string]bool{
+	".ttf": true,
+	".otf": true,
+	".ttc": true,
+	".otc": true,
+}
+
+type fontCache struct {
+	Version int
+	Dirs    map[string]*cachedDir
+}
+
+type cachedDir struct {
+	ModTime int64
+	Subdirs []string
+	Files   map[string]*cachedFile // By file name
+}
+
+type cachedFile struct {
+	ModTime int64
+	Size    int64
+	Faces   []*FontInfo // Empty for files we can't read
+}
+
+var (
+	// Serializes scanning and saving of the cache
+	fontCacheGuard sync.Mutex
+)
+
+func fontCachePath() string {
+	return filepath.Join(xdg.CacheHome, "neoray", fontCacheName)
+}
+
+func newFontCache() *fontCache {
+	return &fontCache{
+		Version: fontCacheVersion,
+		Dirs:    make(map[string]*cachedDir),
+	}
+}
+
+// Returns an empty cache if there is no cache or it is invalid.
+func loadFontCache() *fontCache {
+	file, err := os.Open(fontCachePath())
+	if err != nil {
+		return newFontCache()
+	}
+	defer file.Close()
+	cache := new(fontCache)
+	err = gob.NewDecoder(file).Decode(cache)
+	if err != nil || cache.Version != fontCacheVersion || cache.Dirs == nil {
+		logger.Log(logger.DEBUG, "Font cache is invalid, fonts will be scanned")
+		return newFontCache()
+	}
+	return cache
+}
+
+func (cache *fontCache) save() {
+	path := fontCachePath()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		logger.Log(logger.WARN, "Failed to create cache directory:", err)
+		return
+	}
+	// Write to a temporary file first, other instances may read the cache
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		logger.Log(logger.WARN, "Failed to create font cache:", err)
+		return
+	}
+	err = gob.NewEncoder(file).Encode(cache)
+	file.Close()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		logger.Log(logger.WARN, "Failed to save font cache:", err)
+	}
+}
+
+// Returns all faces in the cache, ordered by their paths.
+func (cache *fontCache) list() []*FontInfo {
+	dirs := make([]string, 0, len(cache.Dirs))
+	for dir := range cache.Dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	list := []*FontInfo{}
+	for _, dir := range dirs {
+		files := cache.Dirs[dir].Files
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			list = append(list, files[name].Faces...)
+		}
+	}
+	return list
+}
+
+// Scans the font directories and returns the new cache. Unchanged
+// directories and files are taken from the old cache. Second value reports
+// whether anything is changed.
+func (cache *fontCache) update() (*fontCache, bool) {
+	scanner := fontScanner{
+		old:     cache,
+		new:     newFontCache(),
+		visited: make(map[string]bool),
+	}
+	for _, dir := range xdg.FontDirs {
+		scanner.scanDir(dir)
+	}
+	// Removed directories
+	if len(scanner.new.Dirs) != len(cache.Dirs) {
+		scanner.changed = true
+	}
+	return scanner.new, scanner.changed
+}
+
+type fontScanner struct {
+	old, new *fontCache
+	visited  map[string]bool // Prevents loops caused by symbolic links
+	changed  bool
+}
+
+func (scanner *fontScanner) scanDir(path string) {
+	if scanner.visited[path] {
+		return
+	}
+	scanner.visited[path] = true
+	stat, err := os.Stat(path)
+	if err != nil || !stat.IsDir() {
+		return
+	}
+	modTime := stat.ModTime().UnixNano()
+	old := scanner.old.Dirs[path]
+	if old != nil && old.ModTime == modTime {
+		scanner.new.Dirs[path] = old
+		for _, subdir := range old.Subdirs {
+			scanner.scanDir(subdir)
+		}
+		return
+	}
+	scanner.changed = true
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+	dir := &cachedDir{
+		ModTime: modTime,
+		Files:   make(map[string]*cachedFile),
+	}
+	scanner.new.Dirs[path] = dir
+	for _, entry := range entries {
+		fullPath := filepath.Join(path, entry.Name())
+		// Entry type doesn't follow symbolic links
+		stat, err := os.Stat(fullPath)
+		if err != nil {
+			continue
+		}
+		if stat.IsDir() {
+			dir.Subdirs = append(dir.Subdirs, fullPath)
+			scanner.scanDir(fullPath)
+			continue
+		}
+		if !fontExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		file := &cachedFile{
+			ModTime: stat.ModTime().UnixNano(),
+			Size:    stat.Size(),
+		}
+		if old != nil {
+			oldFile := old.Files[entry.Name()]
+			if oldFile != nil && oldFile.ModTime == file.ModTime && oldFile.Size == file.Size {
+				dir.Files[entry.Name()] = oldFile
+				continue
+			}
+		}
+		file.Faces, _ = readFaces(fullPath)
+		dir.Files[entry.Name()] = file
+	}
+}
+
+// Loads the system font list from the cache and updates the cache in the
+// background. Fonts are scanned before returning if there is no cache.
+// systemFontListGuard must be locked by the caller, it will be unlocked
+// after the font list is loaded.
+func loadSystemFonts() {
+	cache := loadFontCache()
+	if len(cache.Dirs) == 0 {
+		cache, _ = cache.update()
+		systemFontList = cache.list()
+		systemFontListGuard.Unlock()
+		logger.Log(logger.DEBUG, "Font cache created with", len(systemFontList), "fonts")
+		fontCacheGuard.Lock()
+		defer fontCacheGuard.Unlock()
+		cache.save()
+		return
+	}
+	systemFontList = cache.list()
+	systemFontListGuard.Unlock()
+	// Lock order must be same with the RebuildCache
+	fontCacheGuard.Lock()
+	defer fontCacheGuard.Unlock()
+	cache, changed := cache.update()
+	if changed {
+		systemFontListGuard.Lock()
+		systemFontList = cache.list()
+		systemFontListGuard.Unlock()
+		logger.Log(logger.DEBUG, "Font cache updated with", len(systemFontList), "fonts")
+		cache.save()
+	}
+}
+
+// Scans all fonts again without using the cache and replaces the cache.
+func RebuildCache() {
+	fontCacheGuard.Lock()
+	defer fontCacheGuard.Unlock()
+	cache, _ := newFontCache().update()
+	systemFontListGuard.Lock()
+	systemFontList = cache.list()
+	systemFontListGuard.Unlock()
+	logger.Log(logger.DEBUG, "Font cache rebuilt with", len(systemFontList), "fonts")
+	cache.save()
+}
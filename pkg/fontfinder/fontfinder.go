@@ -5,200 +5,267 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"unicode"
 
-	"github.com/adrg/sysfont"
+	"github.com/hismailbulut/Neoray/pkg/common"
 )
 
+// A font face installed in the system. Font collections have an entry for
+// every face in them. All information is read from the font tables.
+type FontInfo struct {
+	Family    string // Typographic family name if the font has it
+	Style     string // Subfamily name, like "Bold Italic"
+	Name      string // Full name
+	Filename  string
+	Index     int  // Index of the face in the collection, always zero for single font files
+	Weight    int  // Weight class in range [100, 900], 400 is regular and 700 is bold
+	Italic    bool // Italic or oblique
+	Monospace bool
+	Coverage  []Range // Sorted ranges of the characters in the font
+}
+
+// Inclusive range of characters
+type Range struct {
+	First, Last rune
+}
+
+// Reports whether the font has a glyph for the character.
+func (info *FontInfo) HasGlyph(char rune) bool {
+	return info.Covers(char, char)
+}
+
+// Reports whether the font has glyphs for every character in the range.
+func (info *FontInfo) Covers(first, last rune) bool {
+	i := sort.Search(len(info.Coverage), func(i int) bool {
+		return info.Coverage[i].Last >= first
+	})
+	return i < len(info.Coverage) && info.Coverage[i].First <= first && info.Coverage[i].Last >= last
+}
+
+// Location of a font face
+type FontFile struct {
+	Path  string
+	Index int // Index of the face in the collection
+}
+
+func (info *FontInfo) File() FontFile {
+	return FontFile{Path: info.Filename, Index: info.Index}
+}
+
 type FontPathInfo struct {
-	Regular    string
-	BoldItalic string
-	Italic     string
-	Bold       string
-}
-
-type fontSearchInfo struct {
-	handle    *sysfont.Font
-	nameWords []string
-	// Style words is not actually styles. But extracted from filename
-	// and removed unneeded stuff and splitted to words. On very wide
-	// range of the fonts this gives correct hints of the style.
-	// But for the fonts which filename has no info (like consola, consolai)
-	// we are using the font name if the name is in the sysfont data.
-	// Otherwise this package wouldn't help you.
-	styleWords   []string
-	baseNameWExt string
-	hasRegular   bool
-	hasItalic    bool
-	hasBold      bool
+	Regular    FontFile
+	BoldItalic FontFile
+	Italic     FontFile
+	Bold       FontFile
 }
 
 var (
 	// List of installed system fonts.
-	systemFontList      []*sysfont.Font
+	systemFontList      []*FontInfo
 	systemFontListGuard sync.Mutex
 
-	// Add more if you know any other filename used in
-	// font names. All characters must be lowercase.
-	regularStrings = []string{"regular", "normal"}
-	italicStrings  = []string{"italic", "oblique", "slanted", "it"}
-	boldStrings    = []string{"bold"}
+	// Weight names used in style names and their css weight values. Only
+	// used if the font has no weight class. All characters must be lowercase.
+	weightStrings = map[string]int{
+		"thin":       100,
+		"hairline":   100,
+		"extralight": 200,
+		"ultralight": 200,
+		"light":      300,
+		"book":       400,
+		"regular":    400,
+		"normal":     400,
+		"medium":     500,
+		"semibold":   600,
+		"demibold":   600,
+		"bold":       700,
+		"extrabold":  800,
+		"ultrabold":  800,
+		"black":      900,
+		"heavy":      900,
+	}
+
+	// Generic family names and the families tried for them in order. If none
+	// of them is installed, first monospace family is used for monospace.
+	genericFamilies = map[string][]string{
+		"monospace": {"DejaVu Sans Mono", "Liberation Mono", "Noto Sans Mono", "Ubuntu Mono", "Cascadia Mono", "Consolas", "Menlo", "SF Mono", "Courier New"},
+		"sansserif": {"DejaVu Sans", "Liberation Sans", "Noto Sans", "Ubuntu", "Segoe UI", "Helvetica Neue", "Arial"},
+		"serif":     {"DejaVu Serif", "Liberation Serif", "Noto Serif", "Times New Roman", "Times"},
+	}
+	// Other names of the generic families, names are normalized
+	genericAliases = map[string]string{
+		"mono": "monospace",
+		"sans": "sansserif",
+	}
 )
 
 func init() {
-	// On some systems (Windows 10) which has many fonts, this function takes so long.
-	// Because of this we are doing this in initilization and in another
-	// goroutine. Updates systemFontListReady value to true when finished.
-	// And Find() will wait for this to be done only for first time.
-	go func() {
-		systemFontListGuard.Lock()
-		defer systemFontListGuard.Unlock()
-		finder := sysfont.NewFinder(&sysfont.FinderOpts{
-			Extensions: []string{".ttf", ".otf"},
-		})
-		systemFontList = finder.List()
-	}()
-}
-
-func List() []*sysfont.Font {
+	// On some systems (Windows 10) which has many fonts, reading all of them
+	// takes so long. Because of this we are loading them from the cache in
+	// another goroutine and updating the cache after the fonts are loaded.
+	// Find() will wait for the fonts to be loaded only for first time. The
+	// lock is taken here, otherwise Find() could run before the goroutine.
+	systemFontListGuard.Lock()
+	go loadSystemFonts()
+}
+
+func List() []*FontInfo {
 	systemFontListGuard.Lock()
 	defer systemFontListGuard.Unlock()
 	return systemFontList
 }
 
+// Finds the regular, bold, italic and bold italic fonts of the family.
 func Find(name string) FontPathInfo {
+	return FindStyle(name, 400, false)
+}
+
+// Finds the font files closest to the given weight. If italic is true, all
+// styles will be italic. Bold styles are the closest files to the bold
+// weight, or black if the weight is already bold. Name may be a family name,
+// full name of a face, file name or a generic family like monospace.
+func FindStyle(name string, weight int, italic bool) FontPathInfo {
 	systemFontListGuard.Lock()
 	defer systemFontListGuard.Unlock()
-	return find(name)
+	faces := familyFaces(name)
+	minBold := common.Max(600, weight+200)
+	boldWeight := common.Max(700, minBold)
+	info := FontPathInfo{
+		Regular:    closestStyle(faces, weight, italic, 0),
+		Bold:       closestStyle(faces, boldWeight, italic, minBold),
+		Italic:     closestStyle(faces, weight, true, 0),
+		BoldItalic: closestStyle(faces, boldWeight, true, minBold),
+	}
+	if italic && info.Regular.Path == "" {
+		// Family has no italic style, use upright styles instead
+		info.Regular = closestStyle(faces, weight, false, 0)
+		info.Bold = closestStyle(faces, boldWeight, false, minBold)
+	}
+	return info
 }
 
-func find(name string) FontPathInfo {
-
-	fonts := []fontSearchInfo{}
+// Lowercase and without spaces, dashes and underscores
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
 
+func familyOf(family string) []*FontInfo {
+	key := normalizeName(family)
+	faces := []*FontInfo{}
 	for _, f := range systemFontList {
-		if fontContains(f, name) {
-			base := filepath.Base(f.Filename)
-			baseWithoutExt := strings.Replace(base, filepath.Ext(base), "", 1)
-			styles := strings.Replace(baseWithoutExt, name, "", 1)
-			fonts = append(fonts, fontSearchInfo{
-				handle:       f,
-				nameWords:    splitWords(f.Name),
-				styleWords:   splitWords(styles),
-				baseNameWExt: baseWithoutExt,
-			})
+		if normalizeName(f.Family) == key {
+			faces = append(faces, f)
 		}
 	}
+	return faces
+}
 
-	for i := 0; i < len(fonts); i++ {
-		f := &fonts[i]
-		f.hasRegular = fontHasStyle(f, regularStrings)
-		f.hasItalic = fontHasStyle(f, italicStrings)
-		f.hasBold = fontHasStyle(f, boldStrings)
-	}
-
-	// Sort fonts according to file name lengths in descending order.
-	sortFileNameLen(&fonts)
-
-	info := FontPathInfo{}
-	regularFounded := false
-	for _, f := range fonts {
-		// Order is important here.
-		if f.hasItalic && f.hasBold {
-			info.BoldItalic = f.handle.Filename
-		} else if f.hasItalic {
-			info.Italic = f.handle.Filename
-		} else if f.hasBold {
-			info.Bold = f.handle.Filename
-		} else if f.hasRegular {
-			info.Regular = f.handle.Filename
-			// If a font has 'Regular' string, it is the regular.
-			// No look for others. If no font has 'Regular' or 'Normal'
-			// then the font has smallest filename length and has no
-			// italic or bold will be the regular.
-			regularFounded = true
-		} else if !regularFounded {
-			info.Regular = f.handle.Filename
+// Returns all faces of the family matching the name. Name is matched with
+// family names first, then full names and file names, and lastly the
+// shortest family name contains the name.
+func familyFaces(name string) []*FontInfo {
+	key := normalizeName(name)
+	if key == "" {
+		return nil
+	}
+	if alias, ok := genericAliases[key]; ok {
+		key = alias
+	}
+	if families, ok := genericFamilies[key]; ok {
+		return genericFaces(key, families)
+	}
+	faces := familyOf(key)
+	if len(faces) > 0 {
+		return faces
+	}
+	for _, f := range systemFontList {
+		base := filepath.Base(f.Filename)
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		if normalizeName(f.Name) == key || normalizeName(base) == key {
+			return familyOf(f.Family)
 		}
 	}
-
-	return info
+	best := ""
+	for _, f := range systemFontList {
+		if strings.Contains(normalizeName(f.Family), key) && (best == "" || len(f.Family) < len(best)) {
+			best = f.Family
+		}
+	}
+	if best != "" {
+		return familyOf(best)
+	}
+	return nil
 }
 
-func fontHasStyle(info *fontSearchInfo, stylenames []string) bool {
-	for _, s := range stylenames {
-		// Split font name to words and look for exact match.
-		for _, w := range info.nameWords {
-			if s == strings.ToLower(w) {
-				return true
-			}
+func genericFaces(generic string, families []string) []*FontInfo {
+	for _, family := range families {
+		faces := familyOf(family)
+		if len(faces) > 0 {
+			return faces
 		}
-		// Do the same thing for the stylenames extracted from filename.
-		for _, w := range info.styleWords {
-			if s == strings.ToLower(w) {
-				return true
-			}
+	}
+	if generic != "monospace" {
+		return nil
+	}
+	// Alphabetically first monospace family
+	monospaced := []string{}
+	for _, f := range systemFontList {
+		if f.Monospace {
+			monospaced = append(monospaced, f.Family)
 		}
 	}
-	return false
-}
-
-func sortFileNameLen(fonts *[]fontSearchInfo) {
-	sort.Slice(*fonts, func(i, j int) bool {
-		fi := (*fonts)[i]
-		fj := (*fonts)[j]
-		return len(fi.baseNameWExt) > len(fj.baseNameWExt)
-	})
+	if len(monospaced) == 0 {
+		return nil
+	}
+	sort.Strings(monospaced)
+	return familyOf(monospaced[0])
 }
 
-// Splits string to words according to delimiters and casing.
-// Example:
-//  This:           "HelloWorld_from-Turkey"
-//  Turns to this:  [Hello, World, from, Turkey]
-func splitWords(str string) []string {
-	// CamelCase pascalCase and "-_ "
-	arr := []string{}
-	runes := []rune(str)
-	index := 0
-	for i := 0; i < len(runes); i++ {
-		switch runes[i] {
-		case '-', '_', ' ', '.', ',':
-			word := runes[index:i]
-			if len(word) > 0 {
-				arr = append(arr, string(word))
-				index = i + 1
-			}
-		default:
-			if i < len(runes)-1 {
-				curUpper := unicode.IsUpper(runes[i])
-				nexUpper := unicode.IsUpper(runes[i+1])
-				if curUpper && !nexUpper {
-					// Like "Ca"
-					word := runes[index:i]
-					if len(word) > 0 {
-						arr = append(arr, string(word))
-						index = i
-					}
-				} else if !curUpper && nexUpper {
-					// Like "aC"
-					word := runes[index : i+1]
-					if len(word) > 0 {
-						arr = append(arr, string(word))
-						index = i + 1
-					}
-				}
-			}
+// Returns the face which has the closest weight to the given weight and at
+// least minWeight. Returns empty path if there is no face with this style.
+func closestStyle(faces []*FontInfo, weight int, italic bool, minWeight int) FontFile {
+	var found *FontInfo
+	distance := 0
+	for _, f := range faces {
+		if f.Italic != italic || f.Weight < minWeight {
+			continue
+		}
+		d := common.Abs(weight - f.Weight)
+		if found == nil || d < distance ||
+			// Prefer simpler style names like "Bold" over "Bold Condensed"
+			(d == distance && len(f.Style) < len(found.Style)) {
+			found = f
+			distance = d
 		}
 	}
-	word := runes[index:]
-	if len(word) > 0 {
-		arr = append(arr, string(word))
+	if found == nil {
+		return FontFile{}
 	}
-	return arr
+	return found.File()
 }
 
-func fontContains(f *sysfont.Font, str string) bool {
-	return strings.Contains(f.Name, str) ||
-		strings.Contains(f.Family, str) ||
-		strings.Contains(f.Filename, str)
+// Returns weight of the style name, 400 if it has no weight name.
+func styleWeight(style string) int {
+	words := strings.FieldsFunc(strings.ToLower(style), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	})
+	for i, w := range words {
+		// Check joined words first, like "Semi Bold"
+		if i+1 < len(words) {
+			weight, ok := weightStrings[w+words[i+1]]
+			if ok {
+				return weight
+			}
+		}
+		weight, ok := weightStrings[w]
+		if ok && weight != 400 {
+			return weight
+		}
+	}
+	return 400
 }
@@ -0,0 +1,366 @@
+package fontfinder
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+)
+
+// A minimal OpenType reader, only reads the tables we need for identifying
+// the fonts. This is much faster than parsing whole font because we only
+// read a few hundred bytes from every file.
+// https://docs.microsoft.com/en-us/typography/opentype/spec/otff
+
+const (
+	// Maximum size of the name table we read, larger tables are truncated
+	maxNameTableSize = 1 << 20
+	// Maximum number of fonts in a collection
+	maxCollectionFonts = 256
+	// Maximum size of the character map subtable we read
+	maxCmapSubtableSize = 1 << 22
+)
+
+const (
+	nameIDFamily               = 1
+	nameIDSubfamily            = 2
+	nameIDFull                 = 4
+	nameIDTypographicFamily    = 16
+	nameIDTypographicSubfamily = 17
+)
+
+var errInvalidFont = errors.New("invalid font file")
+
+type tableRecord struct {
+	offset, length uint32
+}
+
+func readAt(r io.ReaderAt, offset, length uint32) ([]byte, error) {
+	buf := make([]byte, length)
+	_, err := r.ReadAt(buf, int64(offset))
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Reads every face in the file. Single font files have only one face.
+func readFaces(filename string) ([]*FontInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header, err := readAt(file, 0, 12)
+	if err != nil {
+		return nil, err
+	}
+	offsets := []uint32{0}
+	if string(header[:4]) == "ttcf" {
+		numFonts := binary.BigEndian.Uint32(header[8:])
+		if numFonts == 0 || numFonts > maxCollectionFonts {
+			return nil, errInvalidFont
+		}
+		buf, err := readAt(file, 12, 4*numFonts)
+		if err != nil {
+			return nil, err
+		}
+		offsets = make([]uint32, numFonts)
+		for i := range offsets {
+			offsets[i] = binary.BigEndian.Uint32(buf[4*i:])
+		}
+	}
+	faces := make([]*FontInfo, 0, len(offsets))
+	for i, offset := range offsets {
+		info, e := readFace(file, offset)
+		if e != nil {
+			err = e
+			continue
+		}
+		info.Filename = filename
+		info.Index = i
+		faces = append(faces, info)
+	}
+	if len(faces) == 0 {
+		return nil, err
+	}
+	return faces, nil
+}
+
+func readFace(r io.ReaderAt, offset uint32) (*FontInfo, error) {
+	header, err := readAt(r, offset, 12)
+	if err != nil {
+		return nil, err
+	}
+	switch binary.BigEndian.Uint32(header) {
+	case 0x00010000, 0x4f54544f, 0x74727565: // 0x10000, "OTTO", "true"
+	default:
+		return nil, errInvalidFont
+	}
+	numTables := uint32(binary.BigEndian.Uint16(header[4:]))
+	dir, err := readAt(r, offset+12, 16*numTables)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]tableRecord, numTables)
+	for i := uint32(0); i < numTables; i++ {
+		record := dir[16*i:]
+		tables[string(record[:4])] = tableRecord{
+			offset: binary.BigEndian.Uint32(record[8:]),
+			length: binary.BigEndian.Uint32(record[12:]),
+		}
+	}
+	info := new(FontInfo)
+	// Names
+	table, ok := tables["name"]
+	if !ok {
+		return nil, errInvalidFont
+	}
+	buf, err := readAt(r, table.offset, common.Min(table.length, maxNameTableSize))
+	if err != nil {
+		return nil, err
+	}
+	names := parseNameTable(buf)
+	info.Family = names[nameIDTypographicFamily]
+	if info.Family == "" {
+		info.Family = names[nameIDFamily]
+	}
+	if info.Family == "" {
+		return nil, errInvalidFont
+	}
+	info.Style = names[nameIDTypographicSubfamily]
+	if info.Style == "" {
+		info.Style = names[nameIDSubfamily]
+	}
+	info.Name = names[nameIDFull]
+	if info.Name == "" {
+		info.Name = strings.TrimSpace(info.Family + " " + info.Style)
+	}
+	// Weight, style and monospace information
+	if table, ok := tables["OS/2"]; ok && table.length >= 64 {
+		buf, err := readAt(r, table.offset, 64)
+		if err != nil {
+			return nil, err
+		}
+		info.Weight = int(binary.BigEndian.Uint16(buf[4:]))
+		if info.Weight > 0 && info.Weight < 10 {
+			// Some old fonts use 1-9 scale
+			info.Weight *= 100
+		}
+		// Panose family kind 2 is latin text and proportion 9 is monospaced
+		if buf[32] == 2 && buf[35] == 9 {
+			info.Monospace = true
+		}
+		fsSelection := binary.BigEndian.Uint16(buf[62:])
+		// Italic and oblique bits
+		info.Italic = fsSelection&(1<<0) != 0 || fsSelection&(1<<9) != 0
+	}
+	if table, ok := tables["post"]; ok && table.length >= 16 {
+		buf, err := readAt(r, table.offset, 16)
+		if err != nil {
+			return nil, err
+		}
+		if binary.BigEndian.Uint32(buf[12:]) != 0 {
+			info.Monospace = true
+		}
+	}
+	// Characters in the font, font is still usable without them
+	if table, ok := tables["cmap"]; ok {
+		info.Coverage = readCoverage(r, table)
+	}
+	// Use style names if the font doesn't have OS/2 table
+	if info.Weight == 0 {
+		info.Weight = styleWeight(info.Style)
+	}
+	if !info.Italic {
+		style := strings.ToLower(info.Style)
+		info.Italic = strings.Contains(style, "italic") || strings.Contains(style, "oblique")
+	}
+	return info, nil
+}
+
+// Returns best names for every name id we need. Windows english names are
+// preferred, then unicode and then macintosh names.
+func parseNameTable(buf []byte) map[int]string {
+	names := make(map[int]string)
+	if len(buf) < 6 {
+		return names
+	}
+	count := int(binary.BigEndian.Uint16(buf[2:]))
+	storage := int(binary.BigEndian.Uint16(buf[4:]))
+	scores := make(map[int]int)
+	for i := 0; i < count; i++ {
+		record := buf[6+12*i:]
+		if len(record) < 12 {
+			break
+		}
+		platform := binary.BigEndian.Uint16(record[0:])
+		encoding := binary.BigEndian.Uint16(record[2:])
+		language := binary.BigEndian.Uint16(record[4:])
+		nameID := int(binary.BigEndian.Uint16(record[6:]))
+		length := int(binary.BigEndian.Uint16(record[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+		switch nameID {
+		case nameIDFamily, nameIDSubfamily, nameIDFull, nameIDTypographicFamily, nameIDTypographicSubfamily:
+		default:
+			continue
+		}
+		if offset+length > len(buf) {
+			continue
+		}
+		score := 0
+		isUTF16 := true
+		switch {
+		case platform == 3 && (encoding == 1 || encoding == 10) && language == 0x409:
+			score = 4
+		case platform == 3 && (encoding == 1 || encoding == 10):
+			score = 3
+		case platform == 0:
+			score = 2
+		case platform == 1 && encoding == 0 && language == 0:
+			score = 1
+			isUTF16 = false
+		default:
+			continue
+		}
+		if score <= scores[nameID] {
+			continue
+		}
+		data := buf[offset : offset+length]
+		var name string
+		if isUTF16 {
+			name = decodeUTF16(data)
+		} else {
+			name = decodeLatin1(data)
+		}
+		if name != "" {
+			names[nameID] = name
+			scores[nameID] = score
+		}
+	}
+	return names
+}
+
+func decodeUTF16(data []byte) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return strings.TrimSpace(string(utf16.Decode(u)))
+}
+
+// Macintosh roman is same with latin1 for the characters used in font names
+func decodeLatin1(data []byte) string {
+	r := make([]rune, len(data))
+	for i, b := range data {
+		r[i] = rune(b)
+	}
+	return strings.TrimSpace(string(r))
+}
+
+// Reads the ranges of the characters mapped by the best unicode subtable of
+// the character map. Only formats 4 and 12 are supported, other formats are
+// rarely used for unicode. Returned ranges are sorted and merged.
+func readCoverage(r io.ReaderAt, table tableRecord) []Range {
+	header, err := readAt(r, table.offset, 4)
+	if err != nil {
+		return nil
+	}
+	numTables := uint32(binary.BigEndian.Uint16(header[2:]))
+	records, err := readAt(r, table.offset+4, 8*numTables)
+	if err != nil {
+		return nil
+	}
+	bestScore := 0
+	var bestOffset uint32
+	for i := uint32(0); i < numTables; i++ {
+		record := records[8*i:]
+		platform := binary.BigEndian.Uint16(record[0:])
+		encoding := binary.BigEndian.Uint16(record[2:])
+		score := 0
+		switch {
+		case platform == 3 && encoding == 10:
+			score = 4
+		case platform == 0 && (encoding == 4 || encoding == 6):
+			score = 3
+		case platform == 3 && encoding == 1:
+			score = 2
+		case platform == 0:
+			score = 1
+		}
+		if score > bestScore {
+			bestScore = score
+			bestOffset = table.offset + binary.BigEndian.Uint32(record[4:])
+		}
+	}
+	if bestScore == 0 {
+		return nil
+	}
+	header, err = readAt(r, bestOffset, 8)
+	if err != nil {
+		return nil
+	}
+	var ranges []Range
+	switch binary.BigEndian.Uint16(header) {
+	case 4:
+		length := uint32(binary.BigEndian.Uint16(header[2:]))
+		buf, err := readAt(r, bestOffset, length)
+		if err != nil || len(buf) < 14 {
+			return nil
+		}
+		segCount := int(binary.BigEndian.Uint16(buf[6:])) / 2
+		// endCode array, reserved padding and startCode array
+		if len(buf) < 16+4*segCount {
+			return nil
+		}
+		for i := 0; i < segCount; i++ {
+			end := rune(binary.BigEndian.Uint16(buf[14+2*i:]))
+			start := rune(binary.BigEndian.Uint16(buf[16+2*segCount+2*i:]))
+			if start == 0xFFFF || start > end {
+				continue
+			}
+			ranges = append(ranges, Range{First: start, Last: end})
+		}
+	case 12:
+		length := binary.BigEndian.Uint32(header[4:])
+		buf, err := readAt(r, bestOffset, common.Min(length, maxCmapSubtableSize))
+		if err != nil || len(buf) < 16 {
+			return nil
+		}
+		numGroups := int(binary.BigEndian.Uint32(buf[12:]))
+		for i := 0; i < numGroups && 16+12*i+12 <= len(buf); i++ {
+			group := buf[16+12*i:]
+			start := rune(binary.BigEndian.Uint32(group[0:]))
+			end := rune(binary.BigEndian.Uint32(group[4:]))
+			if start > end {
+				continue
+			}
+			ranges = append(ranges, Range{First: start, Last: end})
+		}
+	}
+	return mergeRanges(ranges)
+}
+
+func mergeRanges(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].First < ranges[j].First
+	})
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.First <= last.Last+1 {
+			last.Last = common.Max(last.Last, r.Last)
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
@@ -3,6 +3,7 @@ package fontkit
 import (
 	"fmt"
 	"image"
+	"math"
 
 	"github.com/hismailbulut/Neoray/pkg/common"
 )
@@ -33,9 +34,24 @@ func (face *Face) DrawUnicodeBoxGlyph(char rune, imgSize common.Vector2[int]) *i
 	case 0x2503: // heavy vertical line
 		drawRectLine(img, centerUp, centerDown, heavy, 1)
 
+	// Triple and quadruple dashed lines
+	// Every two of them are light and heavy, horizontal and vertical
 	case 0x2504, 0x2505, 0x2506, 0x2507,
-		0x2508, 0x2509, 0x250A, 0x250B: // NOTE: Dashes are not supported
-		return nil
+		0x2508, 0x2509, 0x250A, 0x250B:
+		n := char - 0x2504
+		thickness := light
+		if n%2 != 0 {
+			thickness = heavy
+		}
+		dashes := 3
+		if n >= 4 {
+			dashes = 4
+		}
+		if (n/2)%2 == 0 {
+			drawDashedLine(img, centerLeft, centerRight, thickness, dashes)
+		} else {
+			drawDashedLine(img, centerUp, centerDown, thickness, dashes)
+		}
 
 	// Two lines from center to two direction
 	// Every individual line is light or heavy
@@ -156,8 +172,15 @@ func (face *Face) DrawUnicodeBoxGlyph(char rune, imgSize common.Vector2[int]) *i
 			pointWithThickness{vector: centerLeft, thickness: leftThickness},
 		)
 
-	case 0x254C, 0x254D, 0x254E, 0x254F: // NOTE: Dashes are not supported
-		return nil
+	// Double dashed lines
+	case 0x254C: // light double dash horizontal
+		drawDashedLine(img, centerLeft, centerRight, light, 2)
+	case 0x254D: // heavy double dash horizontal
+		drawDashedLine(img, centerLeft, centerRight, heavy, 2)
+	case 0x254E: // light double dash vertical
+		drawDashedLine(img, centerUp, centerDown, light, 2)
+	case 0x254F: // heavy double dash vertical
+		drawDashedLine(img, centerUp, centerDown, heavy, 2)
 
 	case 0x2550: // double horizontal
 		drawDoubleLinesFromPoint(img, centerLeft,
@@ -323,31 +346,24 @@ func (face *Face) DrawUnicodeBoxGlyph(char rune, imgSize common.Vector2[int]) *i
 			pointDouble{vector: centerRight, thickness: light, double: true},
 		)
 
-	// NOTE: We draw arcs like corners
+	// Arcs are quarter circles joined to the edges with straight lines
 	case 0x256D: // light down to right arc
-		drawRectLinesFromPoint(img, 1, center,
-			pointWithThickness{vector: centerDown, thickness: light},
-			pointWithThickness{vector: centerRight, thickness: light},
-		)
+		drawArc(img, center, centerDown, centerRight, light)
 	case 0x256E: // light down to left arc
-		drawRectLinesFromPoint(img, 1, center,
-			pointWithThickness{vector: centerDown, thickness: light},
-			pointWithThickness{vector: centerLeft, thickness: light},
-		)
+		drawArc(img, center, centerDown, centerLeft, light)
 	case 0x256F: // light up to left arc
-		drawRectLinesFromPoint(img, 1, center,
-			pointWithThickness{vector: centerUp, thickness: light},
-			pointWithThickness{vector: centerLeft, thickness: light},
-		)
+		drawArc(img, center, centerUp, centerLeft, light)
 	case 0x2570: // light up to right arc
-		drawRectLinesFromPoint(img, 1, center,
-			pointWithThickness{vector: centerUp, thickness: light},
-			pointWithThickness{vector: centerRight, thickness: light},
-		)
+		drawArc(img, center, centerUp, centerRight, light)
 
-	case 0x2571, 0x2572, 0x2573: // NOTE: Diagonals are not supported
-		// (?) We can support diagonals by using vector library
-		return nil
+	// Diagonals are drawn from corner to corner
+	case 0x2571: // light diagonal upper right to lower left
+		drawLine(img, common.Vec2(w, 0), common.Vec2(0, h), light)
+	case 0x2572: // light diagonal upper left to lower right
+		drawLine(img, common.Vector2[float32]{}, common.Vec2(w, h), light)
+	case 0x2573: // light diagonal cross
+		drawLine(img, common.Vec2(w, 0), common.Vec2(0, h), light)
+		drawLine(img, common.Vector2[float32]{}, common.Vec2(w, h), light)
 
 	// One line from center to one direction
 	case 0x2574, 0x2575, 0x2576, 0x2577,
@@ -460,3 +476,66 @@ func (face *Face) DrawUnicodeBlockGlyph(char rune, imgSize common.Vector2[int])
 
 	return img
 }
+
+// Braille patterns are 2x4 dots, every bit of the char is a dot.
+// https://www.compart.com/en/unicode/block/U+2800
+func (face *Face) DrawBrailleGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	img := face.cachedImage(imgSize)
+	w := imgSize.Width()
+	h := imgSize.Height()
+	// Dots are squares and have the same size in every cell
+	size := common.Max(int(math.Round(float64(common.Min(float32(w)/2, float32(h)/4)/2))), 1)
+	// Bits of the dots in the order of columns and rows
+	bits := [4][2]int{
+		{0, 3},
+		{1, 4},
+		{2, 5},
+		{6, 7},
+	}
+	n := char - 0x2800
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 2; col++ {
+			if n&(1<<bits[row][col]) == 0 {
+				continue
+			}
+			cell := gridCell(w, h, 2, 4, col, row)
+			dot := image.Rect(0, 0, size, size).Add(image.Pt(
+				cell.Min.X+(cell.Dx()-size)/2,
+				cell.Min.Y+(cell.Dy()-size)/2,
+			))
+			fillPixels(img, dot)
+		}
+	}
+	return img
+}
+
+// Sextants are 2x3 blocks, every bit of the pattern is a block.
+// Patterns which are same with the block elements are not in this range.
+// https://www.compart.com/en/unicode/block/U+1FB00
+func (face *Face) DrawSextantGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	img := face.cachedImage(imgSize)
+	w := imgSize.Width()
+	h := imgSize.Height()
+	n := char - 0x1FB00 + 1
+	if n >= 21 { // left half
+		n++
+	}
+	if n >= 42 { // right half
+		n++
+	}
+	for i := 0; i < 6; i++ {
+		if n&(1<<i) != 0 {
+			fillPixels(img, gridCell(w, h, 2, 3, i%2, i/2))
+		}
+	}
+	return img
+}
+
+// Reports whether the char is drawn by the block drawing. These are the block
+// elements, braille patterns and sextants, they don't need a font containing
+// them.
+func IsBlockDrawingChar(char rune) bool {
+	return (char >= 0x2580 && char <= 0x259F) ||
+		(char >= 0x2800 && char <= 0x28FF) ||
+		(char >= 0x1FB00 && char <= 0x1FB3B)
+}
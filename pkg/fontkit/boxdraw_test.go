@@ -0,0 +1,291 @@
+package fontkit
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+)
+
+type edge int
+
+const (
+	edgeLeft edge = iota
+	edgeRight
+	edgeTop
+	edgeBottom
+)
+
+func (e edge) String() string {
+	return [...]string{"left", "right", "top", "bottom"}[e]
+}
+
+// Returns the alpha values of the pixels at the edge of the image.
+func edgeProfile(img *image.RGBA, e edge) string {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	var profile []byte
+	switch e {
+	case edgeLeft, edgeRight:
+		x := 0
+		if e == edgeRight {
+			x = w - 1
+		}
+		for y := 0; y < h; y++ {
+			profile = append(profile, img.RGBAAt(x, y).A)
+		}
+	case edgeTop, edgeBottom:
+		y := 0
+		if e == edgeBottom {
+			y = h - 1
+		}
+		for x := 0; x < w; x++ {
+			profile = append(profile, img.RGBAAt(x, y).A)
+		}
+	}
+	return string(profile)
+}
+
+func renderBoxGlyph(t *testing.T, face *Face, char rune) *image.RGBA {
+	img := face.DrawUnicodeBoxGlyph(char, face.ImageSize())
+	if img == nil {
+		t.Fatalf("box glyph %X is not drawn", char)
+	}
+	// Images are reused by the face
+	clone := image.NewRGBA(img.Rect)
+	copy(clone.Pix, img.Pix)
+	return clone
+}
+
+func TestBoxGlyphsEdgeContinuity(t *testing.T) {
+	for _, face := range testFaces(t, FaceParams{UseBoxDrawing: true}) {
+		t.Run(fmt.Sprint(face.ImageSize()), func(t *testing.T) {
+			// Every line reaching an edge must have the same pixels with one of
+			// the straight lines, otherwise it has a gap with the neighbour cell
+			empty := edgeProfile(face.cachedImage(face.ImageSize()), edgeLeft)
+			horizontal := []string{empty}
+			for _, char := range []rune{0x2500, 0x2501, 0x2550} {
+				horizontal = append(horizontal, edgeProfile(renderBoxGlyph(t, face, char), edgeLeft))
+			}
+			empty = edgeProfile(face.cachedImage(face.ImageSize()), edgeTop)
+			vertical := []string{empty}
+			for _, char := range []rune{0x2502, 0x2503, 0x2551} {
+				vertical = append(vertical, edgeProfile(renderBoxGlyph(t, face, char), edgeTop))
+			}
+			for char := rune(0x2500); char <= 0x257F; char++ {
+				if char >= 0x2571 && char <= 0x2573 {
+					// Diagonals are tested separately
+					continue
+				}
+				img := renderBoxGlyph(t, face, char)
+				for e := edgeLeft; e <= edgeBottom; e++ {
+					allowed := horizontal
+					if e == edgeTop || e == edgeBottom {
+						allowed = vertical
+					}
+					profile := edgeProfile(img, e)
+					found := false
+					for _, p := range allowed {
+						if profile == p {
+							found = true
+							break
+						}
+					}
+					if !found {
+						t.Errorf("%X (%c) has a discontinuous %s edge: %v", char, char, e, []byte(profile))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBoxGlyphsDiagonals(t *testing.T) {
+	for _, face := range testFaces(t, FaceParams{UseBoxDrawing: true}) {
+		size := face.ImageSize()
+		w, h := size.Width(), size.Height()
+		corners := map[rune][]image.Point{
+			0x2571: {{w - 1, 0}, {0, h - 1}},
+			0x2572: {{0, 0}, {w - 1, h - 1}},
+			0x2573: {{0, 0}, {w - 1, 0}, {0, h - 1}, {w - 1, h - 1}},
+		}
+		for char, points := range corners {
+			img := renderBoxGlyph(t, face, char)
+			for _, p := range points {
+				if img.RGBAAt(p.X, p.Y).A == 0 {
+					t.Errorf("%X (%c) in size %v doesn't reach the corner %v", char, char, size, p)
+				}
+			}
+		}
+		// Crossing lines must not clear each other
+		cross := renderBoxGlyph(t, face, 0x2573)
+		if cross.RGBAAt(w/2, h/2).A == 0 {
+			t.Errorf("center of the diagonal cross in size %v is empty", size)
+		}
+	}
+}
+
+func TestBoxGlyphsDashes(t *testing.T) {
+	dashes := map[rune]int{
+		0x2504: 3, 0x2505: 3, 0x2506: 3, 0x2507: 3,
+		0x2508: 4, 0x2509: 4, 0x250A: 4, 0x250B: 4,
+		0x254C: 2, 0x254D: 2, 0x254E: 2, 0x254F: 2,
+	}
+	for _, face := range testFaces(t, FaceParams{UseBoxDrawing: true}) {
+		size := face.ImageSize()
+		for char, count := range dashes {
+			img := renderBoxGlyph(t, face, char)
+			// Pixels along the line, two times to see the gap between the cells
+			var line []bool
+			horizontal := char == 0x2504 || char == 0x2505 || char == 0x2508 || char == 0x2509 || char == 0x254C || char == 0x254D
+			for i := 0; i < 2; i++ {
+				if horizontal {
+					for x := 0; x < size.Width(); x++ {
+						line = append(line, img.RGBAAt(x, size.Height()/2).A > 0)
+					}
+				} else {
+					for y := 0; y < size.Height(); y++ {
+						line = append(line, img.RGBAAt(size.Width()/2, y).A > 0)
+					}
+				}
+			}
+			var runs, gaps []int
+			for i := 0; i < len(line); {
+				j := i
+				for j < len(line) && line[j] == line[i] {
+					j++
+				}
+				if line[i] {
+					runs = append(runs, j-i)
+				} else if i > 0 && j < len(line) {
+					gaps = append(gaps, j-i)
+				}
+				i = j
+			}
+			if len(line)/2 < count*2 {
+				// Every dash needs one pixel and one pixel gap
+				continue
+			}
+			if len(runs) != count*2 {
+				t.Errorf("%X (%c) in size %v has %d dashes in two cells, want %d", char, char, size, len(runs), count*2)
+			}
+			if len(gaps) == 0 {
+				t.Errorf("%X (%c) in size %v has no gaps", char, char, size)
+				continue
+			}
+			// Gaps must be equal, rounding may change them one pixel
+			shortest, longest := gaps[0], gaps[0]
+			for _, gap := range gaps {
+				shortest = common.Min(shortest, gap)
+				longest = common.Max(longest, gap)
+			}
+			if longest-shortest > 1 {
+				t.Errorf("%X (%c) in size %v has different gaps %v", char, char, size, gaps)
+			}
+		}
+	}
+}
+
+func TestSextantGlyphsTile(t *testing.T) {
+	for _, face := range testFaces(t, FaceParams{UseBoxDrawing: true}) {
+		size := face.ImageSize()
+		// Every pixel of the cell must be filled by exactly one of the six
+		// single sextants, otherwise sextants have gaps or overlaps
+		coverage := make([]int, size.Width()*size.Height())
+		for _, char := range []rune{0x1FB00, 0x1FB01, 0x1FB03, 0x1FB07, 0x1FB0F, 0x1FB1E} {
+			img := face.DrawSextantGlyph(char, size)
+			for y := 0; y < size.Height(); y++ {
+				for x := 0; x < size.Width(); x++ {
+					if img.RGBAAt(x, y).A == 255 {
+						coverage[y*size.Width()+x]++
+					}
+				}
+			}
+		}
+		for i, c := range coverage {
+			if c != 1 {
+				t.Fatalf("pixel %d of the size %v is filled by %d sextants", i, size, c)
+			}
+		}
+	}
+}
+
+func TestBrailleGlyphsDots(t *testing.T) {
+	for _, face := range testFaces(t, FaceParams{UseBoxDrawing: true}) {
+		size := face.ImageSize()
+		full := brailleDots(face.DrawBrailleGlyph(0x28FF, size))
+		for char := rune(0x2800); char <= 0x28FF; char++ {
+			dots := brailleDots(face.DrawBrailleGlyph(char, size))
+			// Every bit is one dot and it has the same size with others
+			if want := bits.OnesCount(uint(char - 0x2800)); len(dots) != want {
+				t.Fatalf("%X in size %v has %d dots, want %d", char, size, len(dots), want)
+			}
+			for _, dot := range dots {
+				if dot.Size() != full[0].Size() {
+					t.Errorf("%X in size %v has a dot with size %v, want %v", char, size, dot.Size(), full[0].Size())
+				}
+			}
+		}
+	}
+}
+
+// Returns the bounds of the separate dots in the image.
+func brailleDots(img *image.RGBA) []image.Rectangle {
+	visited := make(map[image.Point]bool)
+	var dots []image.Rectangle
+	var fill func(p image.Point, dot *image.Rectangle)
+	fill = func(p image.Point, dot *image.Rectangle) {
+		if !p.In(img.Rect) || visited[p] || img.RGBAAt(p.X, p.Y).A == 0 {
+			return
+		}
+		visited[p] = true
+		*dot = dot.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+		fill(p.Add(image.Pt(1, 0)), dot)
+		fill(p.Add(image.Pt(-1, 0)), dot)
+		fill(p.Add(image.Pt(0, 1)), dot)
+		fill(p.Add(image.Pt(0, -1)), dot)
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			p := image.Pt(x, y)
+			if !visited[p] && img.RGBAAt(x, y).A != 0 {
+				var dot image.Rectangle
+				fill(p, &dot)
+				dots = append(dots, dot)
+			}
+		}
+	}
+	return dots
+}
+
+func TestDrawnGlyphsArePremultiplied(t *testing.T) {
+	params := FaceParams{UseBoxDrawing: true, UseBlockDrawing: true, UsePowerlineDrawing: true}
+	var chars []rune
+	for _, r := range [][2]rune{{0x2500, 0x259F}, {0x2800, 0x28FF}, {0x1FB00, 0x1FB3B}, {0xE0B0, 0xE0BF}} {
+		for char := r[0]; char <= r[1]; char++ {
+			chars = append(chars, char)
+		}
+	}
+	for _, face := range testFaces(t, params) {
+		size := face.ImageSize()
+		for _, char := range chars {
+			// Shaders use the colors as the coverage of every channel, they
+			// must be the same with the alpha, including the cleared pixels
+			img := face.RenderChar(char, size)
+			for i := 0; i < len(img.Pix); i += 4 {
+				p := img.Pix[i : i+4]
+				if p[0] != p[3] || p[1] != p[3] || p[2] != p[3] {
+					t.Errorf("%X in size %v has the pixel %v which is not premultiplied", char, size, p)
+					break
+				}
+			}
+		}
+		// Shades must not be drawn as full blocks
+		for char, want := range map[rune]uint8{0x2591: 63, 0x2592: 127, 0x2593: 191} {
+			if a := face.RenderChar(char, size).Pix[3]; a != want {
+				t.Errorf("%X in size %v has the alpha %d, want %d", char, size, a, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package fontkit
+
+import (
+	"image"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Grapheme clusters made of multiple code points (combining accents, flags,
+// emoji sequences etc.) are interned and represented by a rune after the
+// unicode range. These runes can be used everywhere a character is used,
+// the fonts render them as a shaped cluster. Runes are stored in the grid
+// cells and shared by all atlases, so the interned clusters are never
+// removed. Their number is limited instead, clusters after the limit are
+// represented by their first code point.
+
+const (
+	// First rune after the unicode range
+	clusterBase rune = 0x110000
+	// Maximum number of the interned clusters
+	maxClusters = 1 << 16
+)
+
+var (
+	clusterGuard sync.RWMutex
+	clusterIDs   = make(map[string]rune)
+	clusters     [][]rune
+)
+
+// Returns the rune represents the grapheme cluster. Clusters which are a
+// single code point after the normalization (NFC) are returned as the code
+// point, others are interned. Cluster must not be empty.
+func ClusterRune(cluster string) rune {
+	char, size := utf8.DecodeRuneInString(cluster)
+	if size == len(cluster) {
+		return char
+	}
+	cluster = norm.NFC.String(cluster)
+	char, size = utf8.DecodeRuneInString(cluster)
+	if size == len(cluster) {
+		return char
+	}
+	clusterGuard.Lock()
+	defer clusterGuard.Unlock()
+	id, ok := clusterIDs[cluster]
+	if !ok {
+		if len(clusters) >= maxClusters {
+			return char
+		}
+		id = clusterBase + rune(len(clusters))
+		clusterIDs[cluster] = id
+		clusters = append(clusters, []rune(cluster))
+	}
+	return id
+}
+
+// Reports whether the rune is an interned grapheme cluster.
+func IsCluster(char rune) bool {
+	clusterGuard.RLock()
+	defer clusterGuard.RUnlock()
+	return char >= clusterBase && int(char-clusterBase) < len(clusters)
+}
+
+// Returns the code points of the interned cluster, nil if the rune is not a
+// cluster.
+func ClusterRunes(char rune) []rune {
+	clusterGuard.RLock()
+	defer clusterGuard.RUnlock()
+	if char < clusterBase || int(char-clusterBase) >= len(clusters) {
+		return nil
+	}
+	return clusters[char-clusterBase]
+}
+
+// Renders the glyphs of the cluster one after another. Glyph positioning
+// (GPOS) is not supported, combining marks are centered over the base glyph
+// and stacked when they overlap, like the fallback positioning of the other
+// shapers. Characters the font doesn't have are skipped. Returns nil if the
+// font has none of them.
+func (face *Face) renderCluster(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	f := face.font
+	runes := ClusterRunes(char)
+	type placedGlyph struct {
+		segments sfnt.Segments
+		origin   fixed.Point26_6
+	}
+	var placed []placedGlyph
+	pen := face.glyphShift
+	gap := face.ppem / 16
+	// Bounds of the base glyph and the marks stacked on it
+	var base fixed.Rectangle26_6
+	for _, glyph := range f.shapeBuffer(runes) {
+		if glyph.glyph == 0 {
+			continue
+		}
+		segments, ok := face.loadHintedSegments(glyph.glyph)
+		if !ok {
+			continue
+		}
+		// Segments are reused by the next glyph load, they must be copied
+		segments = append(sfnt.Segments(nil), segments...)
+		bounds := segments.Bounds()
+		origin := fixed.Point26_6{X: pen}
+		if unicode.In(runes[glyph.cluster], unicode.Mn, unicode.Me) && len(placed) > 0 {
+			origin.X = (base.Min.X+base.Max.X)/2 - (bounds.Min.X+bounds.Max.X)/2
+			if bounds.Max.Y <= 0 {
+				// Above the baseline, must be above the base
+				if y := base.Min.Y - gap - bounds.Max.Y; y < 0 {
+					origin.Y = y
+				}
+			} else if bounds.Min.Y >= 0 {
+				// Below the baseline, must be below the base
+				if y := base.Max.Y + gap - bounds.Min.Y; y > 0 {
+					origin.Y = y
+				}
+			}
+			// Marks must stay in the cell
+			if top := fixed.I(-face.baseline) - bounds.Min.Y; origin.Y < top {
+				origin.Y = top
+			}
+			if bottom := fixed.I(imgSize.Height()-face.baseline) - bounds.Max.Y; origin.Y > bottom {
+				origin.Y = bottom
+			}
+			base = base.Union(bounds.Add(origin))
+		} else {
+			base = bounds.Add(origin)
+			advance, err := f.handle.GlyphAdvance(&f.buffer, glyph.glyph, face.ppem, font.HintingNone)
+			if err == nil {
+				pen += advance
+			}
+		}
+		placed = append(placed, placedGlyph{segments: segments, origin: origin})
+	}
+	if len(placed) == 0 {
+		return nil
+	}
+	// Wide clusters (flags, emoji sequences) use two cells
+	width := imgSize.Width()
+	height := imgSize.Height()
+	if pen.Round() > width*3/2 {
+		width *= 2
+	}
+	return face.rasterize(common.Vec2(width, height), func(r *vector.Rasterizer, scale float32) {
+		for _, glyph := range placed {
+			origin := common.Vec2(float32(glyph.origin.X)/64, float32(face.baseline)+float32(glyph.origin.Y)/64)
+			face.rastOutline(r, glyph.segments, origin, scale)
+		}
+	})
+}
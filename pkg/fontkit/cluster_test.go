@@ -0,0 +1,80 @@
+package fontkit
+
+import (
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestClusterRuneRoundTrip(t *testing.T) {
+	// Single code points after the normalization are not interned
+	if char := ClusterRune("a"); char != 'a' {
+		t.Errorf("a is %U", char)
+	}
+	if char := ClusterRune("e\u0301"); char != 'é' {
+		t.Errorf("e with the combining acute is %U, expected the precomposed é", char)
+	}
+	for _, cluster := range []string{"a\u0301\u0302", "\U0001F1F9\U0001F1F7", "\U0001F469\u200D\U0001F4BB"} {
+		char := ClusterRune(cluster)
+		if !IsCluster(char) {
+			t.Errorf("%q is not interned: %U", cluster, char)
+			continue
+		}
+		if again := ClusterRune(cluster); again != char {
+			t.Errorf("%q is interned twice: %U and %U", cluster, char, again)
+		}
+		if runes := ClusterRunes(char); string(runes) != norm.NFC.String(cluster) {
+			t.Errorf("runes of %q are %q", cluster, string(runes))
+		}
+	}
+	if ClusterRune("a\u0301\u0302") == ClusterRune("o\u0301\u0302") {
+		t.Error("different clusters have the same rune")
+	}
+	if IsCluster('a') || ClusterRunes('a') != nil {
+		t.Error("a is a cluster")
+	}
+}
+
+func TestClusterRuneLimit(t *testing.T) {
+	clusterGuard.Lock()
+	savedIDs, saved := clusterIDs, clusters
+	clusterIDs, clusters = make(map[string]rune), make([][]rune, maxClusters)
+	clusterGuard.Unlock()
+	defer func() {
+		clusterGuard.Lock()
+		clusterIDs, clusters = savedIDs, saved
+		clusterGuard.Unlock()
+	}()
+	if char := ClusterRune("o\u0301\u0302"); char != 'ó' {
+		t.Errorf("cluster after the limit is %U, expected its first code point", char)
+	}
+}
+
+// Returns the first row which has a visible pixel, -1 if the image is empty.
+func firstInkRow(pix []uint8, stride int) int {
+	for i := 3; i < len(pix); i += 4 {
+		if pix[i] != 0 {
+			return i / stride
+		}
+	}
+	return -1
+}
+
+func TestRenderClusterAsOneGlyph(t *testing.T) {
+	face := testRenderingFace(t, Rendering{})
+	size := face.ImageSize()
+	plain := face.RenderChar('a', size)
+	// Images may be reused by the next render
+	plainSize, plainTop := plain.Rect.Size(), firstInkRow(plain.Pix, plain.Stride)
+	img := face.RenderChar(ClusterRune("a\u0301\u0302"), size)
+	if img == nil {
+		t.Fatal("cluster is not rendered")
+	}
+	if img.Rect.Size() != plainSize {
+		t.Fatalf("cluster image size is %v, expected the cell size %v", img.Rect.Size(), plainSize)
+	}
+	// Marks are drawn above the base glyph in the same image
+	if top := firstInkRow(img.Pix, img.Stride); top < 0 || top >= plainTop {
+		t.Errorf("marks are not above the base, first rows are %d and %d", top, plainTop)
+	}
+}
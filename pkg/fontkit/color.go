@@ -0,0 +1,398 @@
+package fontkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/vector"
+)
+
+// Color glyphs of the emoji fonts. Colored bitmaps (CBDT/CBLC and sbix with
+// PNG images) and the layered outlines of COLR version 0 are supported.
+// Color glyphs are rendered as premultiplied RGBA images and they are not
+// tinted with the foreground color.
+// https://docs.microsoft.com/en-us/typography/opentype/spec/cbdt
+// https://docs.microsoft.com/en-us/typography/opentype/spec/sbix
+// https://docs.microsoft.com/en-us/typography/opentype/spec/colr
+
+// Palette index of the COLR layers which are drawn with the text color, we
+// draw them white because the color glyphs are not tinted.
+const foregroundPaletteIndex = 0xFFFF
+
+type colorTables struct {
+	cblc, cbdt []byte
+	sbix       []byte
+	numGlyphs  int
+	colr       []byte
+	palette    []color.RGBA // First palette of CPAL
+}
+
+// A strike of the bitmap tables, glyph images for a size.
+type bitmapStrike struct {
+	ppem   int
+	offset int // Offset of the strike in its table
+}
+
+func parseColorTables(handle *sfnt.Font, tables map[string][]byte) *colorTables {
+	ct := &colorTables{
+		cblc:      tables["CBLC"],
+		cbdt:      tables["CBDT"],
+		sbix:      tables["sbix"],
+		numGlyphs: handle.NumGlyphs(),
+		colr:      tables["COLR"],
+	}
+	if ct.cblc == nil || ct.cbdt == nil {
+		ct.cblc, ct.cbdt = nil, nil
+	}
+	if ct.colr != nil {
+		ct.palette = parseCpal(tables["CPAL"])
+	}
+	if ct.cblc == nil && ct.sbix == nil && ct.colr == nil {
+		return nil
+	}
+	return ct
+}
+
+func parseCpal(cpal []byte) []color.RGBA {
+	if len(cpal) < 14 {
+		return nil
+	}
+	numEntries := int(binary.BigEndian.Uint16(cpal[2:]))
+	recordsOffset := int(binary.BigEndian.Uint32(cpal[8:]))
+	firstIndex := int(binary.BigEndian.Uint16(cpal[12:]))
+	start := recordsOffset + 4*firstIndex
+	if start+4*numEntries > len(cpal) {
+		return nil
+	}
+	palette := make([]color.RGBA, numEntries)
+	for i := range palette {
+		record := cpal[start+4*i:]
+		// Records are BGRA and not premultiplied
+		c := color.NRGBA{R: record[2], G: record[1], B: record[0], A: record[3]}
+		palette[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	return palette
+}
+
+// Returns the strikes of the CBLC table.
+func (ct *colorTables) cblcStrikes() []bitmapStrike {
+	if len(ct.cblc) < 8 {
+		return nil
+	}
+	numSizes := int(binary.BigEndian.Uint32(ct.cblc[4:]))
+	strikes := make([]bitmapStrike, 0, numSizes)
+	for i := 0; i < numSizes && 8+48*i+48 <= len(ct.cblc); i++ {
+		offset := 8 + 48*i
+		strikes = append(strikes, bitmapStrike{ppem: int(ct.cblc[offset+45]), offset: offset})
+	}
+	return strikes
+}
+
+// Returns the strikes of the sbix table.
+func (ct *colorTables) sbixStrikes() []bitmapStrike {
+	if len(ct.sbix) < 8 {
+		return nil
+	}
+	numStrikes := int(binary.BigEndian.Uint32(ct.sbix[4:]))
+	strikes := make([]bitmapStrike, 0, numStrikes)
+	for i := 0; i < numStrikes && 8+4*i+4 <= len(ct.sbix); i++ {
+		offset := int(binary.BigEndian.Uint32(ct.sbix[8+4*i:]))
+		if offset+4 > len(ct.sbix) {
+			continue
+		}
+		strikes = append(strikes, bitmapStrike{ppem: int(binary.BigEndian.Uint16(ct.sbix[offset:])), offset: offset})
+	}
+	return strikes
+}
+
+// Returns the smallest strike which is not smaller than the ppem, or the
+// biggest strike if all of them are smaller. Bitmaps are scaled down better.
+func bestStrike(strikes []bitmapStrike, ppem int) (bitmapStrike, bool) {
+	if len(strikes) == 0 {
+		return bitmapStrike{}, false
+	}
+	sort.Slice(strikes, func(i, j int) bool { return strikes[i].ppem < strikes[j].ppem })
+	for _, strike := range strikes {
+		if strike.ppem >= ppem {
+			return strike, true
+		}
+	}
+	return strikes[len(strikes)-1], true
+}
+
+// Returns the PNG data of the glyph in the CBDT table.
+func (ct *colorTables) cbdtImage(strike bitmapStrike, glyph sfnt.GlyphIndex) []byte {
+	size := ct.cblc[strike.offset:]
+	arrayOffset := int(binary.BigEndian.Uint32(size))
+	numSubtables := int(binary.BigEndian.Uint32(size[8:]))
+	g := int(glyph)
+	for i := 0; i < numSubtables; i++ {
+		record := arrayOffset + 8*i
+		if record+8 > len(ct.cblc) {
+			return nil
+		}
+		first := int(binary.BigEndian.Uint16(ct.cblc[record:]))
+		last := int(binary.BigEndian.Uint16(ct.cblc[record+2:]))
+		if g < first || g > last {
+			continue
+		}
+		subtable := arrayOffset + int(binary.BigEndian.Uint32(ct.cblc[record+4:]))
+		if subtable+8 > len(ct.cblc) {
+			return nil
+		}
+		data, ok := ct.cblcGlyphData(ct.cblc[subtable:], g-first, g)
+		if !ok {
+			return nil
+		}
+		return ct.cbdtPNG(data, int(binary.BigEndian.Uint16(ct.cblc[subtable+2:])))
+	}
+	return nil
+}
+
+// Returns the glyph data in the CBDT table by the index subtable.
+func (ct *colorTables) cblcGlyphData(subtable []byte, index, glyph int) ([]byte, bool) {
+	indexFormat := binary.BigEndian.Uint16(subtable)
+	imageOffset := int(binary.BigEndian.Uint32(subtable[4:]))
+	var start, end int
+	switch indexFormat {
+	case 1:
+		if 8+4*index+8 > len(subtable) {
+			return nil, false
+		}
+		start = int(binary.BigEndian.Uint32(subtable[8+4*index:]))
+		end = int(binary.BigEndian.Uint32(subtable[12+4*index:]))
+	case 3:
+		if 8+2*index+4 > len(subtable) {
+			return nil, false
+		}
+		start = int(binary.BigEndian.Uint16(subtable[8+2*index:]))
+		end = int(binary.BigEndian.Uint16(subtable[10+2*index:]))
+	case 2:
+		if len(subtable) < 12 {
+			return nil, false
+		}
+		imageSize := int(binary.BigEndian.Uint32(subtable[8:]))
+		start, end = imageSize*index, imageSize*(index+1)
+	case 4, 5:
+		// Sparse glyph ids
+		var found bool
+		if indexFormat == 4 {
+			if len(subtable) < 12 {
+				return nil, false
+			}
+			numGlyphs := int(binary.BigEndian.Uint32(subtable[8:]))
+			for i := 0; i < numGlyphs && 12+4*i+8 <= len(subtable); i++ {
+				if int(binary.BigEndian.Uint16(subtable[12+4*i:])) == glyph {
+					start = int(binary.BigEndian.Uint16(subtable[14+4*i:]))
+					end = int(binary.BigEndian.Uint16(subtable[18+4*i:]))
+					found = true
+					break
+				}
+			}
+		} else {
+			if len(subtable) < 24 {
+				return nil, false
+			}
+			imageSize := int(binary.BigEndian.Uint32(subtable[8:]))
+			numGlyphs := int(binary.BigEndian.Uint32(subtable[20:]))
+			for i := 0; i < numGlyphs && 24+2*i+2 <= len(subtable); i++ {
+				if int(binary.BigEndian.Uint16(subtable[24+2*i:])) == glyph {
+					start, end = imageSize*i, imageSize*(i+1)
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	start += imageOffset
+	end += imageOffset
+	if start < 0 || start >= end || end > len(ct.cbdt) {
+		return nil, false
+	}
+	return ct.cbdt[start:end], true
+}
+
+// Returns the PNG data of the CBDT glyph data. Metrics are ignored, images
+// are fitted to the cells.
+func (ct *colorTables) cbdtPNG(data []byte, imageFormat int) []byte {
+	var offset int
+	switch imageFormat {
+	case 17:
+		offset = 5 // Small metrics
+	case 18:
+		offset = 8 // Big metrics
+	case 19:
+		offset = 0 // Metrics are in the index subtable
+	default:
+		return nil
+	}
+	if offset+4 > len(data) {
+		return nil
+	}
+	length := int(binary.BigEndian.Uint32(data[offset:]))
+	if offset+4+length > len(data) {
+		return nil
+	}
+	return data[offset+4 : offset+4+length]
+}
+
+// Returns the PNG data of the glyph in the sbix table.
+func (ct *colorTables) sbixImage(strike bitmapStrike, glyph sfnt.GlyphIndex) []byte {
+	// Dupe records point to other glyphs, limit the depth
+	for depth := 0; depth < 4; depth++ {
+		g := int(glyph)
+		if g >= ct.numGlyphs || strike.offset+4+4*g+8 > len(ct.sbix) {
+			return nil
+		}
+		offsets := ct.sbix[strike.offset+4:]
+		start := strike.offset + int(binary.BigEndian.Uint32(offsets[4*g:]))
+		end := strike.offset + int(binary.BigEndian.Uint32(offsets[4*g+4:]))
+		if end-start < 8 || end > len(ct.sbix) {
+			return nil
+		}
+		data := ct.sbix[start:end]
+		switch string(data[4:8]) {
+		case "png ":
+			return data[8:]
+		case "dupe":
+			if len(data) < 10 {
+				return nil
+			}
+			glyph = sfnt.GlyphIndex(binary.BigEndian.Uint16(data[8:]))
+		default:
+			// JPEG and TIFF images are not supported
+			return nil
+		}
+	}
+	return nil
+}
+
+// Returns the layers of the glyph in COLR, nil if the glyph has no layers.
+func (ct *colorTables) colrLayers(glyph sfnt.GlyphIndex) [][2]int {
+	if len(ct.colr) < 14 {
+		return nil
+	}
+	numBase := int(binary.BigEndian.Uint16(ct.colr[2:]))
+	baseOffset := int(binary.BigEndian.Uint32(ct.colr[4:]))
+	layerOffset := int(binary.BigEndian.Uint32(ct.colr[8:]))
+	numLayers := int(binary.BigEndian.Uint16(ct.colr[12:]))
+	if baseOffset+6*numBase > len(ct.colr) || layerOffset+4*numLayers > len(ct.colr) {
+		return nil
+	}
+	g := int(glyph)
+	i := sort.Search(numBase, func(i int) bool {
+		return int(binary.BigEndian.Uint16(ct.colr[baseOffset+6*i:])) >= g
+	})
+	if i >= numBase || int(binary.BigEndian.Uint16(ct.colr[baseOffset+6*i:])) != g {
+		return nil
+	}
+	first := int(binary.BigEndian.Uint16(ct.colr[baseOffset+6*i+2:]))
+	count := int(binary.BigEndian.Uint16(ct.colr[baseOffset+6*i+4:]))
+	if first+count > numLayers {
+		return nil
+	}
+	layers := make([][2]int, count)
+	for j := range layers {
+		record := ct.colr[layerOffset+4*(first+j):]
+		layers[j] = [2]int{int(binary.BigEndian.Uint16(record)), int(binary.BigEndian.Uint16(record[2:]))}
+	}
+	return layers
+}
+
+// Returns the glyph which is drawn for the character, grapheme clusters are
+// shaped because the emoji fonts form their sequences with ligatures.
+func (font *Font) colorGlyphIndex(char rune) sfnt.GlyphIndex {
+	if runes := ClusterRunes(char); runes != nil {
+		for _, glyph := range font.shapeGlyphs(runes) {
+			if glyph != 0 {
+				return glyph
+			}
+		}
+		return 0
+	}
+	return font.GlyphIndex(char)
+}
+
+// Renders the color glyph of the character and returns a premultiplied
+// RGBA image. Bitmaps are scaled to fit in the image size and centered.
+// Returns nil if the font has no color glyph for the character.
+func (face *Face) RenderColorGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	ct := face.font.color
+	if ct == nil {
+		return nil
+	}
+	glyph := face.font.colorGlyphIndex(char)
+	if glyph == 0 {
+		return nil
+	}
+	if layers := ct.colrLayers(glyph); layers != nil {
+		return face.renderColrGlyph(layers, imgSize)
+	}
+	ppem := int(face.ppem >> 6)
+	var data []byte
+	if strike, ok := bestStrike(ct.cblcStrikes(), ppem); ok {
+		data = ct.cbdtImage(strike, glyph)
+	}
+	if data == nil {
+		if strike, ok := bestStrike(ct.sbixStrikes(), ppem); ok {
+			data = ct.sbixImage(strike, glyph)
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	bitmap, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return face.fitBitmap(bitmap, imgSize)
+}
+
+// Scales the bitmap to fit in the image by keeping its aspect ratio.
+func (face *Face) fitBitmap(bitmap image.Image, imgSize common.Vector2[int]) *image.RGBA {
+	bounds := bitmap.Bounds()
+	if bounds.Empty() {
+		return nil
+	}
+	width, height := imgSize.Width(), imgSize.Height()
+	scale := common.Min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	w := common.Max(int(float64(bounds.Dx())*scale), 1)
+	h := common.Max(int(float64(bounds.Dy())*scale), 1)
+	dst := image.Rect(0, 0, w, h).Add(image.Pt((width-w)/2, (height-h)/2))
+	img := face.cachedImage(imgSize)
+	draw.CatmullRom.Scale(img, dst, bitmap, bounds, draw.Over, nil)
+	return img
+}
+
+// Rasterizes the outlines of the layers with their palette colors.
+func (face *Face) renderColrGlyph(layers [][2]int, imgSize common.Vector2[int]) *image.RGBA {
+	width, height := imgSize.Width(), imgSize.Height()
+	origin := common.Vec2(float32(face.glyphShift)/64, float32(face.baseline))
+	img := face.cachedImage(imgSize)
+	for _, layer := range layers {
+		segments, ok := face.loadSegments(sfnt.GlyphIndex(layer[0]))
+		if !ok {
+			continue
+		}
+		c := color.RGBA{255, 255, 255, 255}
+		if layer[1] != foregroundPaletteIndex && layer[1] < len(face.font.color.palette) {
+			c = face.font.color.palette[layer[1]]
+		}
+		r := vector.NewRasterizer(width, height)
+		face.rastOutline(r, segments, origin, 1)
+		r.Draw(img, img.Rect, image.NewUniform(c), image.Point{})
+	}
+	return img
+}
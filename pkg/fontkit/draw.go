@@ -3,6 +3,8 @@ package fontkit
 import (
 	"image"
 	"image/color"
+	"image/draw"
+	"math"
 
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"golang.org/x/image/vector"
@@ -10,12 +12,14 @@ import (
 
 // RECTANGLE OPERATIONS USED BY BOXDRAWING
 
+// Replaces the pixels in the rect with white of the alpha. Colors are
+// premultiplied like the rasterized glyphs, shaders use them as the coverage.
 func drawRect(img *image.RGBA, rect common.Rectangle[float32], alpha float32) {
 	a := uint8(common.Clamp(alpha, 0, 1) * 255)
 	r := rect.ToInt()
 	for x := r.X; x <= r.X+r.W; x++ {
 		for y := r.Y; y <= r.Y+r.H; y++ {
-			img.SetRGBA(x, y, color.RGBA{255, 255, 255, a})
+			img.SetRGBA(x, y, color.RGBA{a, a, a, a})
 		}
 	}
 }
@@ -209,3 +213,113 @@ func rastLine(r *vector.Rasterizer, begin, end common.Vector2[float32], thicknes
 	r.LineTo(begin.X, begin.Y)
 	r.ClosePath()
 }
+
+// Adds closed polygon operation to r
+func rastPolygon(r *vector.Rasterizer, points ...common.Vector2[float32]) {
+	r.MoveTo(points[0].X, points[0].Y)
+	for _, p := range points[1:] {
+		r.LineTo(p.X, p.Y)
+	}
+	r.ClosePath()
+}
+
+// Adds half ellipse from the top to the bottom of the ellipse to r, the pen
+// must be at the start point. Dir is 1 for the right half and -1 for the left
+// half. Reverse draws from the bottom to the top.
+func rastHalfEllipse(r *vector.Rasterizer, center, radius common.Vector2[float32], dir float32, reverse bool) {
+	// Cubic bezier approximation of the quarter circle
+	const k = 0.5522848
+	rx := radius.X * dir
+	ry := radius.Y
+	top := common.Vec2(center.X, center.Y-ry)
+	mid := common.Vec2(center.X+rx, center.Y)
+	bottom := common.Vec2(center.X, center.Y+ry)
+	if reverse {
+		r.CubeTo(bottom.X+k*rx, bottom.Y, mid.X, mid.Y+k*ry, mid.X, mid.Y)
+		r.CubeTo(mid.X, mid.Y-k*ry, top.X+k*rx, top.Y, top.X, top.Y)
+	} else {
+		r.CubeTo(top.X+k*rx, top.Y, mid.X, mid.Y-k*ry, mid.X, mid.Y)
+		r.CubeTo(mid.X, mid.Y+k*ry, bottom.X+k*rx, bottom.Y, bottom.X, bottom.Y)
+	}
+}
+
+// LINE OPERATIONS USED BY BOXDRAWING
+
+// Returns the center and the width of the pixels filled by drawRectLine for a
+// line at the center with the thickness. Antialiased lines use this to join
+// the rectangle lines in the neighbour cells without any difference.
+func snapLine(center, thickness float32) (float32, float32) {
+	begin := float32(math.Floor(float64(center - thickness/2)))
+	width := float32(math.Floor(float64(thickness))) + 1
+	return begin + width/2, width
+}
+
+// Draws a line which is split into equal dashes. The gap is shared between the
+// both sides of every dash, so the dashes in the neighbour cells have the same
+// distance between them.
+func drawDashedLine(img *image.RGBA, begin, end common.Vector2[float32], thickness float32, dashes int) {
+	vec := end.Sub(begin)
+	length := vec.X + vec.Y
+	dir := vec.DivS(length)
+	segment := length / float32(dashes)
+	gap := common.Max(int(math.Round(float64(segment/3))), 1)
+	gapBefore := gap / 2
+	gapAfter := gap - gapBefore
+	for i := 0; i < dashes; i++ {
+		// Pixels of the dash are in range [first, last]
+		first := int(math.Round(float64(float32(i)*segment))) + gapBefore
+		last := int(math.Round(float64(float32(i+1)*segment))) - gapAfter - 1
+		last = common.Max(last, first)
+		drawRectLine(img, begin.Add(dir.MulS(float32(first))), begin.Add(dir.MulS(float32(last))), thickness, 1)
+	}
+}
+
+// Draws a quarter circle from the center of the vertical edge to the center of
+// the horizontal edge. Arc ends with straight lines at the edges which have the
+// same pixels with the lines of drawRectLine.
+func drawArc(img *image.RGBA, center, vertical, horizontal common.Vector2[float32], thickness float32) {
+	x, width := snapLine(center.X, thickness)
+	y, _ := snapLine(center.Y, thickness)
+	dirX, dirY := float32(1), float32(1)
+	if horizontal.X < x {
+		dirX = -1
+	}
+	if vertical.Y < y {
+		dirY = -1
+	}
+	// Leave at least one pixel of straight line at the edges
+	radius := common.Min(common.Abs(horizontal.X-x), common.Abs(vertical.Y-y)) - 1
+	begin := common.Vec2(x, float32(math.Round(float64(y+dirY*radius))))
+	end := common.Vec2(float32(math.Round(float64(x+dirX*radius))), y)
+	r := vector.NewRasterizer(img.Rect.Dx(), img.Rect.Dy())
+	rastLine(r, common.Vec2(x, vertical.Y), begin, width)
+	rastCurve(r, width, begin, end, common.Vec2(x, y))
+	rastLine(r, end, common.Vec2(horizontal.X, y), width)
+	r.Draw(img, img.Rect, image.White, image.Point{})
+}
+
+// Draws an antialiased line. Every line has its own rasterizer because the
+// lines crossing each other must not clear the intersection.
+func drawLine(img *image.RGBA, begin, end common.Vector2[float32], thickness float32) {
+	r := vector.NewRasterizer(img.Rect.Dx(), img.Rect.Dy())
+	rastLine(r, begin, end, thickness)
+	r.Draw(img, img.Rect, image.White, image.Point{})
+}
+
+// PIXEL OPERATIONS USED BY BLOCK DRAWING
+
+// Fills every pixel in the rect, max point is not included.
+func fillPixels(img *image.RGBA, rect image.Rectangle) {
+	draw.Draw(img, rect, image.White, image.Point{}, draw.Src)
+}
+
+// Returns the pixels of the cell in the grid which divides the image into
+// columns and rows. Cells of the grid don't overlap and don't leave any gap.
+func gridCell(width, height, cols, rows, col, row int) image.Rectangle {
+	return image.Rect(
+		int(math.Round(float64(width*col)/float64(cols))),
+		int(math.Round(float64(height*row)/float64(rows))),
+		int(math.Round(float64(width*(col+1))/float64(cols))),
+		int(math.Round(float64(height*(row+1))/float64(rows))),
+	)
+}
@@ -3,33 +3,82 @@ package fontkit
 import (
 	"errors"
 	"image"
-	"image/draw"
 	"math"
 
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
-	"golang.org/x/image/vector"
 )
 
 type FaceParams struct {
 	Size, DPI                      float64
 	UseBoxDrawing, UseBlockDrawing bool
+	UsePowerlineDrawing            bool
+	Adjust                         CellAdjust
+	// Glyphs are emboldened or obliqued by us, used when the font family
+	// doesn't have these styles
+	SyntheticBold, SyntheticItalic bool
+	// Axis values of the variable fonts, ignored by other fonts
+	Variation Variation
+	// Hinting, antialiasing and coverage curve of the glyphs
+	Rendering Rendering
+}
+
+// Adjustments of the cell size and glyph position. Zero value means no
+// adjustment.
+type CellAdjust struct {
+	LineSpace      int     // Extra vertical pixels, glyph will be centered vertically
+	Width          float64 // Fixed cell width in points, zero means font advance
+	WidthScale     float64 // Scales cell width, zero means 1
+	HeightScale    float64 // Scales cell height (before line space), zero means 1
+	BaselineOffset int     // Moves glyphs up in pixels, negative values moves down
+}
+
+// Scales of the undercurl metrics calculated from the font. Zero value means
+// no adjustment.
+type UndercurlAdjust struct {
+	AmplitudeScale  float64 // Scales the height of the wave, zero means 1
+	WavelengthScale float64 // Scales the length of one wave, zero means 1
+	ThicknessScale  float64 // Scales the line thickness, zero means 1
+}
+
+// Metrics of the decorations drawn by the shaders. Positions are the centers
+// of the lines from the top of the cell, all values are in pixels.
+type DecorationMetrics struct {
+	Underline     float32
+	Strikethrough float32
+	Thickness     float32
+	Undercurl     float32 // Center of the wave
+	Amplitude     float32
+	Wavelength    float32
+	CurlThickness float32
 }
 
 type Face struct {
 	handle font.Face
+	font   *Font
 	// config
 	useBoxDrawing   bool
 	useBlockDrawing bool
+	usePowerline    bool
+	hinting         Hinting
+	antialias       Antialias
+	curve           *[256]uint8 // coverage curve of the gamma and contrast, nil if linear
+	syntheticBold   bool
+	syntheticItalic bool
+	ppem            fixed.Int26_6 // pixels per em, used for synthetic glyphs
+	coords          []float64     // normalized variation coordinates, nil for default instance
 	// metrics
-	advance int
+	advance fixed.Int26_6 // only rounded by the full hinting
 	ascent  int
 	descent int
 	height  int
 	// calculated
-	thickness float32
+	thickness  float32
+	cellSize   common.Vector2[int] // adjusted image size
+	baseline   int                 // baseline position from the top of the cell
+	glyphShift fixed.Int26_6       // horizontal offset of the glyph for centering
 	// cache
 	imgCache map[common.Vector2[int]]*image.RGBA
 }
@@ -47,11 +96,42 @@ func (f *Font) CreateFace(params FaceParams) (*Face, error) {
 		face = new(Face)
 		face.useBoxDrawing = params.UseBoxDrawing
 		face.useBlockDrawing = params.UseBlockDrawing
+		face.usePowerline = params.UsePowerlineDrawing
+		face.font = f
+		face.hinting = f.hinting
+		if face.hinting == HintingDefault {
+			face.hinting = params.Rendering.Hinting
+		}
+		if face.hinting == HintingDefault {
+			face.hinting = HintingFull
+		}
+		face.antialias = f.antialias
+		if face.antialias == AntialiasDefault {
+			face.antialias = params.Rendering.Antialias
+		}
+		if face.antialias == AntialiasDefault {
+			face.antialias = AntialiasGray
+		}
+		face.curve = coverageCurve(params.Rendering.Gamma, params.Rendering.Contrast)
+		face.syntheticBold = params.SyntheticBold
+		face.syntheticItalic = params.SyntheticItalic
+		face.ppem = fixed.Int26_6(math.Round(params.Size * params.DPI / 72 * 64))
+		if f.variable != nil {
+			face.coords = f.variable.normalize(params.Variation)
+		}
+		// Metrics are rounded by the opentype face, outlines are fitted by us
+		hinting := font.HintingFull
+		switch face.hinting {
+		case HintingVertical:
+			hinting = font.HintingVertical
+		case HintingNone:
+			hinting = font.HintingNone
+		}
 		var err error
 		face.handle, err = opentype.NewFace(f.handle, &opentype.FaceOptions{
 			Size:    params.Size,
 			DPI:     params.DPI,
-			Hinting: font.HintingFull,
+			Hinting: hinting,
 		})
 		if err != nil {
 			return nil, err
@@ -61,7 +141,7 @@ func (f *Font) CreateFace(params FaceParams) (*Face, error) {
 		if !ok {
 			return nil, errors.New("Failed to get font advance!")
 		}
-		face.advance = advance.Floor()
+		face.advance = advance
 
 		metrics := face.handle.Metrics()
 		face.ascent = metrics.Ascent.Ceil()
@@ -69,6 +149,7 @@ func (f *Font) CreateFace(params FaceParams) (*Face, error) {
 		face.height = metrics.Height.Floor()
 
 		face.thickness = common.Max(float32(math.Ceil(4*(float64(face.height)/12))/4), 1)
+		face.calculateCellSize(params.Adjust, params.DPI)
 		face.imgCache = make(map[common.Vector2[int]]*image.RGBA)
 
 		f.faceCache[params] = face
@@ -76,30 +157,35 @@ func (f *Font) CreateFace(params FaceParams) (*Face, error) {
 	}
 }
 
-func (face *Face) ImageSize() common.Vector2[int] {
-	return common.Vec2(face.advance, face.height)
+func (face *Face) calculateCellSize(adjust CellAdjust, dpi float64) {
+	advance := face.advance.Floor()
+	if adjust.Width > 0 {
+		advance = int(math.Round(adjust.Width * dpi / 72))
+	}
+	widthScale := adjust.WidthScale
+	if widthScale <= 0 {
+		widthScale = 1
+	}
+	heightScale := adjust.HeightScale
+	if heightScale <= 0 {
+		heightScale = 1
+	}
+	width := int(math.Round(float64(advance) * widthScale))
+	height := int(math.Round(float64(face.height)*heightScale)) + adjust.LineSpace
+	face.cellSize = common.Vec2(common.Max(width, 1), common.Max(height, 1))
+	// Extra space is equally shared between top and bottom
+	extra := face.cellSize.Height() - face.height
+	face.baseline = extra/2 + face.height - face.descent - adjust.BaselineOffset
+	// Glyphs are positioned in subpixels unless the outlines are fitted to the
+	// pixels horizontally
+	face.glyphShift = (fixed.I(face.cellSize.Width()) - face.advance) / 2
+	if face.hinting == HintingFull {
+		face.glyphShift = fixed.I(face.glyphShift.Floor())
+	}
 }
 
-// This function renders an undercurl to an empty image and returns it.
-// The undercurl drawing job is done in the shaders.
-func (face *Face) RenderUndercurl(imgSize common.Vector2[int]) *image.RGBA {
-	w := float32(imgSize.Width())
-	h := float32(imgSize.Height())
-	y := h - float32(face.descent)/2
-	r := vector.NewRasterizer(imgSize.Width(), imgSize.Height())
-	rastCurve(r, face.thickness,
-		common.Vec2(0, y),
-		common.Vec2(w/2, y),
-		common.Vec2(w/4, y+h/8),
-	)
-	rastCurve(r, face.thickness,
-		common.Vec2(w/2, y),
-		common.Vec2(w, y),
-		common.Vec2(w/4*3, y-h/8),
-	)
-	img := face.cachedImage(imgSize)
-	r.Draw(img, img.Rect, image.White, image.Point{})
-	return img
+func (face *Face) ImageSize() common.Vector2[int] {
+	return face.cellSize
 }
 
 // This function reduces image allocations by reusing existing sized images
@@ -122,59 +208,81 @@ func (face *Face) cachedImage(imgSize common.Vector2[int]) *image.RGBA {
 // Renders given rune and returns rendered RGBA image.
 // Width of the image is always equal to cellWidth or cellWidth*2
 func (face *Face) RenderGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
-	height := imgSize.Height()
-	dot := fixed.P(0, height-face.descent)
-	dr, mask, maskp, _, ok := face.handle.Glyph(dot, char)
-	if ok {
-		width := imgSize.Width()
-		if mask.Bounds().Dx() > width {
-			width *= 2
-		}
-		if mask.Bounds().Dy() > height {
-			// Center image if the image height is taller than our cell height.
-			maskp = image.Pt(0, (height-mask.Bounds().Dy())/2)
-		}
-		img := face.cachedImage(common.Vec2(width, height))
-		draw.DrawMask(img, dr, image.White, image.Point{}, mask, maskp, draw.Over)
-		return img
+	if IsCluster(char) {
+		return face.renderCluster(char, imgSize)
 	}
-	return nil
+	return face.renderOutlineGlyph(char, imgSize)
 }
 
-// Renders given char to an RGBA image and returns.
-// Also renders underline and strikethrough if specified.
-func (face *Face) RenderChar(char rune, underline, strikethrough bool, imgSize common.Vector2[int]) *image.RGBA {
+// Renders given char to an RGBA image and returns. Decorations are not part
+// of the image, see DecorationMetrics.
+func (face *Face) RenderChar(char rune, imgSize common.Vector2[int]) *image.RGBA {
 	if face.useBoxDrawing && char >= 0x2500 && char <= 0x257F {
 		// Unicode box drawing characters
 		// https://www.compart.com/en/unicode/block/U+2500
-		img := face.DrawUnicodeBoxGlyph(char, imgSize)
-		if img != nil {
-			return img
-		}
+		return face.DrawUnicodeBoxGlyph(char, imgSize)
 	}
 	if face.useBlockDrawing && char >= 0x2580 && char <= 0x259F {
 		// Unicode block characters
 		// https://www.compart.com/en/unicode/block/U+2580
 		return face.DrawUnicodeBlockGlyph(char, imgSize)
 	}
-	// Render glyph
-	img := face.RenderGlyph(char, imgSize)
-	if img == nil {
-		return nil
+	if face.useBlockDrawing && char >= 0x2800 && char <= 0x28FF {
+		// Braille patterns
+		return face.DrawBrailleGlyph(char, imgSize)
+	}
+	if face.useBlockDrawing && char >= 0x1FB00 && char <= 0x1FB3B {
+		// Sextants of the symbols for legacy computing
+		return face.DrawSextantGlyph(char, imgSize)
+	}
+	if face.usePowerline && IsPowerlineChar(char) {
+		// Powerline separators
+		return face.DrawPowerlineGlyph(char, imgSize)
 	}
-	// Draw underline or strikethrough to glyph
-	if underline || strikethrough {
-		w := float32(img.Rect.Dx())
-		r := vector.NewRasterizer(img.Rect.Dx(), img.Rect.Dy())
-		if underline {
-			y := float32(imgSize.Height()-face.descent) + 1
-			rastLine(r, common.Vec2(0, y), common.Vec2(w, y), face.thickness)
+	// Render glyph
+	return face.RenderGlyph(char, imgSize)
+}
+
+// Returns the metrics of the decorations. Decorations are drawn by the
+// shaders over the whole width of the cells, so they don't multiply the
+// glyphs in the atlas and use the special color. The undercurl is a sine wave
+// one cell long by default, shaders calculate it from the window position, so
+// it continues across the cells.
+func (face *Face) DecorationMetrics(adjust UndercurlAdjust) DecorationMetrics {
+	scale := func(v float32, s float64) float32 {
+		if s > 0 {
+			return v * float32(s)
 		}
-		if strikethrough {
-			y := float32(imgSize.Height()) / 2
-			rastLine(r, common.Vec2(0, y), common.Vec2(w, y), face.thickness)
+		return v
+	}
+	metrics := DecorationMetrics{
+		Underline: float32(face.baseline) + 1,
+		// Middle of the font height, same with the cell middle if there is no adjustment
+		Strikethrough: float32(face.baseline+face.descent) - float32(face.height)/2,
+		Thickness:     face.thickness,
+		Undercurl:     float32(face.baseline) + float32(face.descent)/2,
+		Amplitude:     scale(float32(face.height)/16, adjust.AmplitudeScale),
+		Wavelength:    scale(float32(face.cellSize.Width()), adjust.WavelengthScale),
+		CurlThickness: scale(face.thickness, adjust.ThicknessScale),
+	}
+	// Move the wave up if it doesn't fit to the cell, cells below would clip it
+	bottom := float32(face.cellSize.Height()) - metrics.Amplitude - metrics.CurlThickness/2
+	metrics.Undercurl = common.Min(metrics.Undercurl, bottom)
+	return metrics
+}
+
+// Removes smoothing of the glyph, every pixel is either fully opaque or
+// transparent.
+func aliasImage(img *image.RGBA, rect image.Rectangle) {
+	rect = rect.Intersect(img.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			var v uint8
+			if img.Pix[i+3] >= 128 {
+				v = 255
+			}
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, v
 		}
-		r.Draw(img, img.Rect, image.White, image.Point{})
 	}
-	return img
 }
@@ -0,0 +1,29 @@
+package fontkit
+
+import (
+	"testing"
+)
+
+func TestDecorationMetricsInCell(t *testing.T) {
+	for _, face := range testFaces(t, FaceParams{}) {
+		size := face.ImageSize()
+		height := float32(size.Height())
+		m := face.DecorationMetrics(UndercurlAdjust{})
+		for name, span := range map[string][2]float32{
+			"underline":     {m.Underline - m.Thickness/2, m.Underline + m.Thickness/2},
+			"strikethrough": {m.Strikethrough - m.Thickness/2, m.Strikethrough + m.Thickness/2},
+			"undercurl":     {m.Undercurl - m.Amplitude - m.CurlThickness/2, m.Undercurl + m.Amplitude + m.CurlThickness/2},
+		} {
+			if span[0] < 0 || span[1] > height {
+				t.Errorf("%s in size %v is out of the cell: %v", name, size, span)
+			}
+		}
+		if m.Wavelength != float32(size.Width()) {
+			t.Errorf("undercurl wavelength in size %v is %f, want the cell width", size, m.Wavelength)
+		}
+		scaled := face.DecorationMetrics(UndercurlAdjust{AmplitudeScale: 2, WavelengthScale: 0.5, ThicknessScale: 3})
+		if scaled.Amplitude != m.Amplitude*2 || scaled.Wavelength != m.Wavelength/2 || scaled.CurlThickness != m.CurlThickness*3 {
+			t.Errorf("undercurl in size %v is not scaled: %+v", size, scaled)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package fontkit
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hismailbulut/Neoray/pkg/fontfinder"
+	"github.com/hismailbulut/Neoray/pkg/logger"
+)
+
+// System fallback is used for the glyphs that none of the configured fonts
+// have. Fonts are searched using the character coverage in the font index,
+// and the font file is loaded only when the font has a missing glyph. Found
+// fonts are cached for every rune, also runes that no font has are cached.
+
+var (
+	fallbackFonts = make(map[fontfinder.FontFile]*Font)
+	fallbackCache = make(map[rune]*Font)
+)
+
+// Returns a system font which contains the glyph, nil if there is no font
+// has it.
+func FallbackFont(char rune) *Font {
+	font, ok := fallbackCache[char]
+	if ok {
+		return font
+	}
+	for _, info := range fallbackCandidates(char) {
+		file := info.File()
+		candidate, ok := fallbackFonts[file]
+		if !ok {
+			var err error
+			candidate, err = CreateFontFromFileIndex(file.Path, file.Index)
+			if err != nil {
+				logger.Log(logger.WARN, "Failed to load fallback font:", err)
+			}
+			// Failed fonts are also cached as nil
+			fallbackFonts[file] = candidate
+		}
+		// Coverage may contain characters that have no glyph
+		if candidate != nil && candidate.ContainsGlyph(char) {
+			font = candidate
+			logger.LogF(logger.DEBUG, "Fallback font for U+%04X is %s", char, file.Path)
+			break
+		}
+	}
+	fallbackCache[char] = font
+	return font
+}
+
+// Returns the system fonts which cover the character. Upright and regular
+// fonts are first, glyphs will be used for all styles.
+func fallbackCandidates(char rune) []*fontfinder.FontInfo {
+	candidates := []*fontfinder.FontInfo{}
+	for _, info := range fontfinder.List() {
+		if info.HasGlyph(char) {
+			candidates = append(candidates, info)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return !isStyledFont(candidates[i]) && isStyledFont(candidates[j])
+	})
+	return candidates
+}
+
+func isStyledFont(info *fontfinder.FontInfo) bool {
+	style := strings.ToLower(info.Style)
+	return info.Italic || info.Weight > 500 || strings.Contains(style, "bold")
+}
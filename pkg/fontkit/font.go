@@ -12,15 +12,29 @@ type Font struct {
 	handle    *sfnt.Font
 	buffer    sfnt.Buffer
 	filePath  string
+	index     int // Index of the font in the collection
 	faceCache map[FaceParams]*Face
+	variable  *variableFont // Variation tables, nil if the font is not variable
+	gsub      *gsubTable    // Substitutions for the ligatures, nil if the font has no GSUB
+	color     *colorTables  // Color glyphs, nil if the font has no color tables
+	// options, must be set before creating faces
+	hinting   Hinting
+	antialias Antialias
+	weight    int // Requested weight of the kit, used for the variable fonts
 }
 
 func CreateFontFromFile(pathToFile string) (*Font, error) {
+	return CreateFontFromFileIndex(pathToFile, 0)
+}
+
+// Loads the font at the index of the collection file (.ttc, .otc). Index
+// must be zero for single font files.
+func CreateFontFromFileIndex(pathToFile string, index int) (*Font, error) {
 	fileData, err := os.ReadFile(pathToFile)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read file: %s\n", err)
 	}
-	font, err := CreateFontFromMem(fileData)
+	font, err := CreateFontFromMemIndex(fileData, index)
 	if err != nil {
 		return nil, err
 	}
@@ -29,12 +43,27 @@ func CreateFontFromFile(pathToFile string) (*Font, error) {
 }
 
 func CreateFontFromMem(data []byte) (*Font, error) {
+	return CreateFontFromMemIndex(data, 0)
+}
+
+func CreateFontFromMemIndex(data []byte, index int) (*Font, error) {
 	font := new(Font)
-	var err error
-	font.handle, err = opentype.Parse(data)
+	// Collection parser also parses single fonts as a collection with one font
+	collection, err := opentype.ParseCollection(data)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse font data: %s\n", err)
 	}
+	font.handle, err = collection.Font(index)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse font %d in collection: %s\n", index, err)
+	}
+	font.index = index
+	font.variable = parseVariableFont(font.handle, data, index)
+	tables := fontTables(data, index)
+	if tables["GSUB"] != nil {
+		font.gsub = parseGsub(tables["GSUB"], tables["GDEF"])
+	}
+	font.color = parseColorTables(font.handle, tables)
 	font.faceCache = make(map[FaceParams]*Face)
 	return font, nil
 }
@@ -43,6 +72,11 @@ func (font *Font) FilePath() string {
 	return font.filePath
 }
 
+// Index of the font in the collection file, zero for single font files
+func (font *Font) Index() int {
+	return font.index
+}
+
 func (font *Font) FamilyName() (string, error) {
 	name, err := font.handle.Name(&font.buffer, sfnt.NameIDFamily)
 	if err != nil {
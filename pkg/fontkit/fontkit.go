@@ -2,6 +2,7 @@ package fontkit
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hismailbulut/Neoray/pkg/fontfinder"
 )
@@ -20,39 +21,126 @@ type FontKit struct {
 	boldItalic *Font
 }
 
+// Glyph hinting of the fonts, see hint.go
+type Hinting uint8
+
+const (
+	HintingDefault  Hinting = iota // Rendering option of the face, full if not set
+	HintingFull                    // Both horizontal and vertical edges are fitted
+	HintingVertical                // Only the horizontal edges are fitted
+	HintingNone
+)
+
+// Parses the hinting names used by the guifont flags and the options.
+func ParseHinting(name string) (Hinting, error) {
+	switch strings.ToLower(name) {
+	case "full", "normal":
+		return HintingFull, nil
+	case "vertical", "slight", "light":
+		return HintingVertical, nil
+	case "none":
+		return HintingNone, nil
+	}
+	return HintingDefault, fmt.Errorf("unknown hinting %s", name)
+}
+
+// Antialiasing of the rendered glyphs
+type Antialias uint8
+
+const (
+	AntialiasDefault     Antialias = iota // Rendering option of the face, gray if not set
+	AntialiasGray                         // Grayscale coverage
+	AntialiasNone                         // Glyphs are rendered without smoothing
+	AntialiasSubpixelRGB                  // LCD antialiasing, channels are the horizontal subpixels
+	AntialiasSubpixelBGR                  // LCD antialiasing for the panels with reversed subpixels
+)
+
+// Parses the antialiasing names used by the guifont flags and the options.
+func ParseAntialias(name string) (Antialias, error) {
+	switch strings.ToLower(name) {
+	case "antialias", "gray", "grayscale":
+		return AntialiasGray, nil
+	case "subpixelantialias", "subpixel", "rgb":
+		return AntialiasSubpixelRGB, nil
+	case "bgr":
+		return AntialiasSubpixelBGR, nil
+	case "alias", "none":
+		return AntialiasNone, nil
+	}
+	return AntialiasDefault, fmt.Errorf("unknown antialias %s", name)
+}
+
+// Rasterization options of the glyphs. Hinting and antialiasing of the fonts
+// override them if the fonts have their own. Zero value means defaults.
+type Rendering struct {
+	Hinting   Hinting
+	Antialias Antialias
+	Gamma     float64 // Coverage is raised to the power of 1/Gamma, zero means 1
+	Contrast  float64 // Coverage is multiplied by 1+Contrast, thin glyphs become darker
+}
+
+// Options used when finding and loading the fonts of the kit. Zero value
+// means default options.
+type KitOptions struct {
+	Weight    int       // Weight of the regular style in range [100, 900], zero means regular
+	Italic    bool      // Use italic styles as regular
+	Hinting   Hinting   // Overrides the hinting of the faces if set
+	Antialias Antialias // Overrides the antialiasing of the faces if set
+}
+
 func CreateKit(fontname string) (*FontKit, error) {
-	info := fontfinder.Find(fontname)
-	if info.Regular == "" && info.Bold == "" && info.Italic == "" && info.BoldItalic == "" {
+	return CreateKitWithOptions(fontname, KitOptions{})
+}
+
+func CreateKitWithOptions(fontname string, options KitOptions) (*FontKit, error) {
+	var info fontfinder.FontPathInfo
+	if options.Weight == 0 && !options.Italic {
+		info = fontfinder.Find(fontname)
+	} else {
+		weight := options.Weight
+		if weight == 0 {
+			weight = 400
+		}
+		info = fontfinder.FindStyle(fontname, weight, options.Italic)
+	}
+	if info.Regular.Path == "" && info.Bold.Path == "" && info.Italic.Path == "" && info.BoldItalic.Path == "" {
 		// This means we could not find any font file with this name
 		return nil, fmt.Errorf("Couldn't find font %s", fontname)
 	}
 	fontkit := new(FontKit)
 	// Load fonts
 	var err error
-	if info.Regular != "" {
-		fontkit.regular, err = CreateFontFromFile(info.Regular)
+	if info.Regular.Path != "" {
+		fontkit.regular, err = CreateFontFromFileIndex(info.Regular.Path, info.Regular.Index)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if info.Bold != "" {
-		fontkit.bold, err = CreateFontFromFile(info.Bold)
+	if info.Bold.Path != "" {
+		fontkit.bold, err = CreateFontFromFileIndex(info.Bold.Path, info.Bold.Index)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if info.Italic != "" {
-		fontkit.italic, err = CreateFontFromFile(info.Italic)
+	if info.Italic.Path != "" {
+		fontkit.italic, err = CreateFontFromFileIndex(info.Italic.Path, info.Italic.Index)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if info.BoldItalic != "" {
-		fontkit.boldItalic, err = CreateFontFromFile(info.BoldItalic)
+	if info.BoldItalic.Path != "" {
+		fontkit.boldItalic, err = CreateFontFromFileIndex(info.BoldItalic.Path, info.BoldItalic.Index)
 		if err != nil {
 			return nil, err
 		}
 	}
+	for _, font := range []*Font{fontkit.regular, fontkit.bold, fontkit.italic, fontkit.boldItalic} {
+		if font != nil {
+			font.hinting = options.Hinting
+			font.antialias = options.Antialias
+			font.weight = options.Weight
+		}
+	}
 	return fontkit, nil
 }
 
@@ -125,3 +213,11 @@ func (fontkit *FontKit) SuitableFont(bold, italic bool) *Font {
 	}
 	return fontkit.DefaultFont()
 }
+
+// Returns which of the requested styles the font doesn't have. Font must be
+// one of the fonts in the kit.
+func (fontkit *FontKit) MissingStyles(font *Font, bold, italic bool) (bool, bool) {
+	missingBold := bold && font != fontkit.bold && font != fontkit.boldItalic
+	missingItalic := italic && font != fontkit.italic && font != fontkit.boldItalic
+	return missingBold, missingItalic
+}
@@ -0,0 +1,33 @@
+package fontkit
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// Creates Go Mono faces in different sizes and cell adjustments. Size, DPI
+// and adjustment of the base parameters are replaced, others are used for
+// all faces.
+func testFaces(t *testing.T, base FaceParams) []*Face {
+	font, err := CreateFontFromMem(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var faces []*Face
+	for _, variant := range []FaceParams{
+		{Size: 9, DPI: 96},
+		{Size: 11, DPI: 96, Adjust: CellAdjust{LineSpace: 3}},
+		{Size: 14, DPI: 144},
+		{Size: 23, DPI: 96, Adjust: CellAdjust{WidthScale: 1.3}},
+	} {
+		params := base
+		params.Size, params.DPI, params.Adjust = variant.Size, variant.DPI, variant.Adjust
+		face, err := font.CreateFace(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		faces = append(faces, face)
+	}
+	return faces
+}
@@ -0,0 +1,54 @@
+package fontkit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FontRange sends the characters in the range [First, Last] to a specific
+// font kit. The kit is only used if it contains the glyph.
+type FontRange struct {
+	First, Last rune
+	Kit         *FontKit
+}
+
+func (r FontRange) Contains(char rune) bool {
+	return char >= r.First && char <= r.Last
+}
+
+// Parses a unicode range in form of 'U+E000-U+F8FF' or a single code point
+// like 'U+E0A0'. 'U+' prefix is optional and case insensitive.
+func ParseRange(str string) (rune, rune, error) {
+	parts := strings.Split(str, "-")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("Invalid unicode range %s", str)
+	}
+	first, err := parseCodePoint(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	last := first
+	if len(parts) == 2 {
+		last, err = parseCodePoint(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if last < first {
+		return 0, 0, fmt.Errorf("Invalid unicode range %s", str)
+	}
+	return first, last, nil
+}
+
+func parseCodePoint(str string) (rune, error) {
+	str = strings.TrimSpace(str)
+	if len(str) > 2 && (str[:2] == "U+" || str[:2] == "u+") {
+		str = str[2:]
+	}
+	value, err := strconv.ParseUint(str, 16, 32)
+	if err != nil || value > 0x10FFFF {
+		return 0, fmt.Errorf("Invalid code point %s", str)
+	}
+	return rune(value), nil
+}
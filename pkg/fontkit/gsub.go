@@ -0,0 +1,753 @@
+package fontkit
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/font/sfnt"
+)
+
+// A minimal OpenType shaper for programming ligatures. Only the glyph
+// substitution lookups of the 'ccmp', 'calt' and 'liga' features of the default
+// script are applied, glyph positions are not changed. This is enough for
+// the monospaced fonts, their ligatures are made of the glyphs with the same
+// advance. Reverse chaining substitutions are not supported.
+// https://docs.microsoft.com/en-us/typography/opentype/spec/gsub
+
+// Shaped glyph of the cells which are a part of a ligature, but the
+// ligature glyph is drawn by a previous cell.
+const NoGlyph sfnt.GlyphIndex = 0xFFFF
+
+const (
+	// Maximum number of nested contextual lookups
+	maxLookupDepth = 8
+	// Maximum number of lookups applied to a glyph run, prevents infinite
+	// loops with broken fonts
+	maxLookupOperations = 1 << 14
+)
+
+const (
+	gsubSingle          = 1
+	gsubMultiple        = 2
+	gsubLigature        = 4
+	gsubContext         = 5
+	gsubChainingContext = 6
+	gsubExtension       = 7
+)
+
+// Lookup flags
+const (
+	lookupIgnoreBaseGlyphs = 0x0002
+	lookupIgnoreLigatures  = 0x0004
+	lookupIgnoreMarks      = 0x0008
+)
+
+// Glyph classes in GDEF
+const (
+	glyphClassBase     = 1
+	glyphClassLigature = 2
+	glyphClassMark     = 3
+)
+
+var shapingFeatures = map[string]bool{
+	"ccmp": true,
+	"calt": true,
+	"liga": true,
+}
+
+type gsubLookup struct {
+	typ       uint16
+	flag      uint16
+	subtables [][]byte // Extension subtables are resolved
+}
+
+type gsubTable struct {
+	lookups     []gsubLookup // All lookups in the font, used by contextual lookups
+	features    []int        // Lookups of the shaping features in order
+	glyphClass  []byte       // Glyph class definition of GDEF, may be nil
+	hasFeatures bool
+}
+
+type shapedGlyph struct {
+	glyph   sfnt.GlyphIndex
+	cluster int // Index of the first character of the glyph
+}
+
+// Runtime state of the shaping
+type shaper struct {
+	table      *gsubTable
+	buffer     []shapedGlyph
+	operations int
+}
+
+func u16(data []byte, offset int) (int, bool) {
+	if offset < 0 || offset+2 > len(data) {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint16(data[offset:])), true
+}
+
+// Returns the subtable at the offset of the parent table
+func subtable(data []byte, offset int) []byte {
+	if offset <= 0 || offset >= len(data) {
+		return nil
+	}
+	return data[offset:]
+}
+
+func parseGsub(gsub, gdef []byte) *gsubTable {
+	if len(gsub) < 10 {
+		return nil
+	}
+	table := new(gsubTable)
+	scriptList := subtable(gsub, int(binary.BigEndian.Uint16(gsub[4:])))
+	featureList := subtable(gsub, int(binary.BigEndian.Uint16(gsub[6:])))
+	lookupList := subtable(gsub, int(binary.BigEndian.Uint16(gsub[8:])))
+	if scriptList == nil || featureList == nil || lookupList == nil {
+		return nil
+	}
+	// Lookups
+	lookupCount, _ := u16(lookupList, 0)
+	table.lookups = make([]gsubLookup, lookupCount)
+	for i := range table.lookups {
+		offset, ok := u16(lookupList, 2+2*i)
+		if !ok {
+			return nil
+		}
+		table.lookups[i] = parseLookup(subtable(lookupList, offset))
+	}
+	// Features of the default language system of the default script
+	langSys := defaultLangSys(scriptList)
+	if langSys == nil {
+		return table
+	}
+	featureCount, _ := u16(featureList, 0)
+	required, _ := u16(langSys, 2)
+	indexCount, _ := u16(langSys, 4)
+	indices := make([]int, 0, indexCount+1)
+	if required != 0xFFFF {
+		indices = append(indices, required)
+	}
+	for i := 0; i < indexCount; i++ {
+		index, ok := u16(langSys, 6+2*i)
+		if !ok {
+			break
+		}
+		indices = append(indices, index)
+	}
+	lookups := make(map[int]bool)
+	for _, index := range indices {
+		if index >= featureCount || 2+6*index+6 > len(featureList) {
+			continue
+		}
+		record := featureList[2+6*index:]
+		if !shapingFeatures[string(record[:4])] {
+			continue
+		}
+		feature := subtable(featureList, int(binary.BigEndian.Uint16(record[4:])))
+		count, _ := u16(feature, 2)
+		for j := 0; j < count; j++ {
+			lookup, ok := u16(feature, 4+2*j)
+			if ok && lookup < len(table.lookups) {
+				lookups[lookup] = true
+			}
+		}
+	}
+	for lookup := range lookups {
+		table.features = append(table.features, lookup)
+	}
+	// Lookups are applied in the order of the lookup list
+	sort.Ints(table.features)
+	table.hasFeatures = len(table.features) > 0
+	// Glyph classes for the lookup flags
+	if len(gdef) >= 12 {
+		table.glyphClass = subtable(gdef, int(binary.BigEndian.Uint16(gdef[4:])))
+	}
+	return table
+}
+
+// Returns the default language system of the DFLT or latn script.
+func defaultLangSys(scriptList []byte) []byte {
+	count, _ := u16(scriptList, 0)
+	var script []byte
+	for i := 0; i < count && 2+6*i+6 <= len(scriptList); i++ {
+		record := scriptList[2+6*i:]
+		tag := string(record[:4])
+		if tag == "DFLT" || (tag == "latn" && script == nil) {
+			script = subtable(scriptList, int(binary.BigEndian.Uint16(record[4:])))
+		}
+	}
+	if script == nil {
+		return nil
+	}
+	offset, _ := u16(script, 0)
+	return subtable(script, offset)
+}
+
+func parseLookup(data []byte) gsubLookup {
+	var lookup gsubLookup
+	if len(data) < 6 {
+		return lookup
+	}
+	lookup.typ = binary.BigEndian.Uint16(data)
+	lookup.flag = binary.BigEndian.Uint16(data[2:])
+	count := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < count; i++ {
+		offset, ok := u16(data, 6+2*i)
+		if !ok {
+			break
+		}
+		sub := subtable(data, offset)
+		if sub == nil {
+			continue
+		}
+		if lookup.typ == gsubExtension {
+			if len(sub) < 8 {
+				continue
+			}
+			// All extension subtables of a lookup have the same type
+			lookup.typ = binary.BigEndian.Uint16(sub[2:])
+			sub = subtable(sub, int(binary.BigEndian.Uint32(sub[4:])))
+			if sub == nil {
+				continue
+			}
+		}
+		lookup.subtables = append(lookup.subtables, sub)
+	}
+	return lookup
+}
+
+// Returns the coverage index of the glyph, -1 if the glyph is not covered.
+func coverageIndex(coverage []byte, glyph sfnt.GlyphIndex) int {
+	if len(coverage) < 4 {
+		return -1
+	}
+	g := int(glyph)
+	format := binary.BigEndian.Uint16(coverage)
+	count := int(binary.BigEndian.Uint16(coverage[2:]))
+	switch format {
+	case 1:
+		if 4+2*count > len(coverage) {
+			return -1
+		}
+		i := sort.Search(count, func(i int) bool {
+			return int(binary.BigEndian.Uint16(coverage[4+2*i:])) >= g
+		})
+		if i < count && int(binary.BigEndian.Uint16(coverage[4+2*i:])) == g {
+			return i
+		}
+	case 2:
+		if 4+6*count > len(coverage) {
+			return -1
+		}
+		i := sort.Search(count, func(i int) bool {
+			return int(binary.BigEndian.Uint16(coverage[4+6*i+2:])) >= g
+		})
+		if i < count {
+			record := coverage[4+6*i:]
+			start := int(binary.BigEndian.Uint16(record))
+			if g >= start {
+				return int(binary.BigEndian.Uint16(record[4:])) + g - start
+			}
+		}
+	}
+	return -1
+}
+
+// Returns the class of the glyph, zero if the glyph is not in any class.
+func glyphClass(classDef []byte, glyph sfnt.GlyphIndex) int {
+	if len(classDef) < 4 {
+		return 0
+	}
+	g := int(glyph)
+	switch binary.BigEndian.Uint16(classDef) {
+	case 1:
+		if len(classDef) < 6 {
+			return 0
+		}
+		start := int(binary.BigEndian.Uint16(classDef[2:]))
+		count := int(binary.BigEndian.Uint16(classDef[4:]))
+		if g >= start && g < start+count && 6+2*(g-start)+2 <= len(classDef) {
+			return int(binary.BigEndian.Uint16(classDef[6+2*(g-start):]))
+		}
+	case 2:
+		count := int(binary.BigEndian.Uint16(classDef[2:]))
+		if 4+6*count > len(classDef) {
+			return 0
+		}
+		i := sort.Search(count, func(i int) bool {
+			return int(binary.BigEndian.Uint16(classDef[4+6*i+2:])) >= g
+		})
+		if i < count {
+			record := classDef[4+6*i:]
+			if g >= int(binary.BigEndian.Uint16(record)) {
+				return int(binary.BigEndian.Uint16(record[4:]))
+			}
+		}
+	}
+	return 0
+}
+
+// Reports whether the glyph is skipped by the lookup
+func (table *gsubTable) ignored(flag uint16, glyph sfnt.GlyphIndex) bool {
+	if table.glyphClass == nil || flag&(lookupIgnoreBaseGlyphs|lookupIgnoreLigatures|lookupIgnoreMarks) == 0 {
+		return false
+	}
+	switch glyphClass(table.glyphClass, glyph) {
+	case glyphClassBase:
+		return flag&lookupIgnoreBaseGlyphs != 0
+	case glyphClassLigature:
+		return flag&lookupIgnoreLigatures != 0
+	case glyphClassMark:
+		return flag&lookupIgnoreMarks != 0
+	}
+	return false
+}
+
+// Returns the index of the next glyph which is not ignored by the lookup,
+// -1 if there is no glyph. Direction is 1 for forward and -1 for backward.
+func (s *shaper) next(flag uint16, i, direction int) int {
+	for i += direction; i >= 0 && i < len(s.buffer); i += direction {
+		if !s.table.ignored(flag, s.buffer[i].glyph) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Applies the shaping features to the buffer.
+func (s *shaper) shape() {
+	for _, index := range s.table.features {
+		lookup := s.table.lookups[index]
+		for i := 0; i < len(s.buffer); {
+			if s.table.ignored(lookup.flag, s.buffer[i].glyph) {
+				i++
+				continue
+			}
+			i = s.applyLookup(lookup, i, 0)
+		}
+	}
+}
+
+// Applies the lookup at the position and returns the next position.
+func (s *shaper) applyLookup(lookup gsubLookup, i, depth int) int {
+	s.operations++
+	if s.operations > maxLookupOperations || depth > maxLookupDepth {
+		return len(s.buffer)
+	}
+	for _, sub := range lookup.subtables {
+		next, ok := s.applySubtable(lookup, sub, i, depth)
+		if ok {
+			return next
+		}
+	}
+	return i + 1
+}
+
+func (s *shaper) applySubtable(lookup gsubLookup, sub []byte, i, depth int) (int, bool) {
+	if len(sub) < 4 {
+		return 0, false
+	}
+	format := binary.BigEndian.Uint16(sub)
+	glyph := s.buffer[i].glyph
+	switch lookup.typ {
+	case gsubSingle:
+		coverage := coverageIndex(subtable(sub, int(binary.BigEndian.Uint16(sub[2:]))), glyph)
+		if coverage < 0 {
+			return 0, false
+		}
+		switch format {
+		case 1:
+			delta, ok := u16(sub, 4)
+			if !ok {
+				return 0, false
+			}
+			// Delta is added modulo 65536
+			s.buffer[i].glyph = sfnt.GlyphIndex(uint16(int(glyph) + delta))
+		case 2:
+			substitute, ok := u16(sub, 6+2*coverage)
+			if !ok {
+				return 0, false
+			}
+			s.buffer[i].glyph = sfnt.GlyphIndex(substitute)
+		default:
+			return 0, false
+		}
+		return i + 1, true
+	case gsubMultiple:
+		coverage := coverageIndex(subtable(sub, int(binary.BigEndian.Uint16(sub[2:]))), glyph)
+		offset, ok := u16(sub, 6+2*coverage)
+		if coverage < 0 || !ok {
+			return 0, false
+		}
+		sequence := subtable(sub, offset)
+		count, _ := u16(sequence, 0)
+		if count == 0 || 2+2*count > len(sequence) {
+			return 0, false
+		}
+		glyphs := make([]shapedGlyph, count)
+		for j := range glyphs {
+			glyphs[j] = shapedGlyph{
+				glyph:   sfnt.GlyphIndex(binary.BigEndian.Uint16(sequence[2+2*j:])),
+				cluster: s.buffer[i].cluster,
+			}
+		}
+		s.buffer = append(s.buffer[:i], append(glyphs, s.buffer[i+1:]...)...)
+		return i + count, true
+	case gsubLigature:
+		return s.applyLigature(lookup, sub, i)
+	case gsubContext:
+		return s.applyContext(lookup, sub, i, depth)
+	case gsubChainingContext:
+		return s.applyChainingContext(lookup, sub, i, depth)
+	}
+	return 0, false
+}
+
+func (s *shaper) applyLigature(lookup gsubLookup, sub []byte, i int) (int, bool) {
+	coverage := coverageIndex(subtable(sub, int(binary.BigEndian.Uint16(sub[2:]))), s.buffer[i].glyph)
+	offset, ok := u16(sub, 6+2*coverage)
+	if coverage < 0 || !ok {
+		return 0, false
+	}
+	ligatureSet := subtable(sub, offset)
+	count, _ := u16(ligatureSet, 0)
+	for j := 0; j < count; j++ {
+		offset, _ := u16(ligatureSet, 2+2*j)
+		ligature := subtable(ligatureSet, offset)
+		glyph, ok1 := u16(ligature, 0)
+		components, ok2 := u16(ligature, 2)
+		if !ok1 || !ok2 || components == 0 || 4+2*(components-1) > len(ligature) {
+			continue
+		}
+		// Find the positions of the components
+		positions := []int{i}
+		for k := 1; k < components; k++ {
+			p := s.next(lookup.flag, positions[len(positions)-1], 1)
+			if p < 0 || int(s.buffer[p].glyph) != int(binary.BigEndian.Uint16(ligature[4+2*(k-1):])) {
+				positions = nil
+				break
+			}
+			positions = append(positions, p)
+		}
+		if positions == nil {
+			continue
+		}
+		s.buffer[i].glyph = sfnt.GlyphIndex(glyph)
+		// Remove other components, backwards for keeping the positions valid
+		for k := len(positions) - 1; k > 0; k-- {
+			p := positions[k]
+			s.buffer = append(s.buffer[:p], s.buffer[p+1:]...)
+		}
+		return i + 1, true
+	}
+	return 0, false
+}
+
+// Glyph matcher of the contextual subtables. Value is a glyph id, class or
+// coverage offset depending on the format.
+type matchFunc func(value int, glyph sfnt.GlyphIndex) bool
+
+// Matches the sequence starting from the glyph after the position. Returns
+// the positions of the matched glyphs.
+func (s *shaper) matchSequence(flag uint16, i, direction int, values []byte, count int, match matchFunc) ([]int, bool) {
+	positions := make([]int, 0, count)
+	p := i
+	for k := 0; k < count; k++ {
+		p = s.next(flag, p, direction)
+		value, ok := u16(values, 2*k)
+		if p < 0 || !ok || !match(value, s.buffer[p].glyph) {
+			return nil, false
+		}
+		positions = append(positions, p)
+	}
+	return positions, true
+}
+
+// Applies the nested lookups to the matched input positions.
+func (s *shaper) applyNested(records []byte, count int, positions []int, depth int) int {
+	end := positions[len(positions)-1] + 1
+	for k := 0; k < count && 4*k+4 <= len(records); k++ {
+		sequenceIndex := int(binary.BigEndian.Uint16(records[4*k:]))
+		lookupIndex := int(binary.BigEndian.Uint16(records[4*k+2:]))
+		if sequenceIndex >= len(positions) || lookupIndex >= len(s.table.lookups) {
+			continue
+		}
+		before := len(s.buffer)
+		position := positions[sequenceIndex]
+		if position >= len(s.buffer) {
+			continue
+		}
+		s.applyLookup(s.table.lookups[lookupIndex], position, depth+1)
+		// Ligatures and multiple substitutions change the positions
+		change := len(s.buffer) - before
+		if change != 0 {
+			for m := range positions {
+				if positions[m] > position {
+					positions[m] += change
+				}
+			}
+			end += change
+		}
+	}
+	return common.Max(end, positions[0]+1)
+}
+
+func (s *shaper) matchGlyphID(value int, glyph sfnt.GlyphIndex) bool {
+	return value == int(glyph)
+}
+
+func classMatcher(classDef []byte) matchFunc {
+	return func(value int, glyph sfnt.GlyphIndex) bool {
+		return value == glyphClass(classDef, glyph)
+	}
+}
+
+func coverageMatcher(parent []byte) matchFunc {
+	return func(value int, glyph sfnt.GlyphIndex) bool {
+		return coverageIndex(subtable(parent, value), glyph) >= 0
+	}
+}
+
+// Reads the rule sets of the format 1 and 2 contextual subtables. Returns
+// the rule set for the glyph, nil if there is no.
+func ruleSet(sub []byte, setIndex int, countOffset int) []byte {
+	count, _ := u16(sub, countOffset)
+	if setIndex < 0 || setIndex >= count {
+		return nil
+	}
+	offset, _ := u16(sub, countOffset+2+2*setIndex)
+	return subtable(sub, offset)
+}
+
+func (s *shaper) applyContext(lookup gsubLookup, sub []byte, i, depth int) (int, bool) {
+	format := binary.BigEndian.Uint16(sub)
+	glyph := s.buffer[i].glyph
+	switch format {
+	case 1, 2:
+		coverage := coverageIndex(subtable(sub, int(binary.BigEndian.Uint16(sub[2:]))), glyph)
+		if coverage < 0 {
+			return 0, false
+		}
+		match := s.matchGlyphID
+		setIndex := coverage
+		countOffset := 4
+		if format == 2 {
+			classDef := subtable(sub, int(binary.BigEndian.Uint16(sub[4:])))
+			match = classMatcher(classDef)
+			setIndex = glyphClass(classDef, glyph)
+			countOffset = 6
+		}
+		set := ruleSet(sub, setIndex, countOffset)
+		count, _ := u16(set, 0)
+		for r := 0; r < count; r++ {
+			offset, _ := u16(set, 2+2*r)
+			rule := subtable(set, offset)
+			glyphCount, ok1 := u16(rule, 0)
+			lookupCount, ok2 := u16(rule, 2)
+			if !ok1 || !ok2 || glyphCount == 0 {
+				continue
+			}
+			input := subtable(rule, 4)
+			positions, ok := s.matchSequence(lookup.flag, i, 1, input, glyphCount-1, match)
+			if !ok {
+				continue
+			}
+			positions = append([]int{i}, positions...)
+			return s.applyNested(subtable(rule, 4+2*(glyphCount-1)), lookupCount, positions, depth), true
+		}
+	case 3:
+		glyphCount, ok1 := u16(sub, 2)
+		lookupCount, ok2 := u16(sub, 4)
+		if !ok1 || !ok2 || glyphCount == 0 {
+			return 0, false
+		}
+		first, _ := u16(sub, 6)
+		if coverageIndex(subtable(sub, first), glyph) < 0 {
+			return 0, false
+		}
+		positions, ok := s.matchSequence(lookup.flag, i, 1, subtable(sub, 8), glyphCount-1, coverageMatcher(sub))
+		if !ok {
+			return 0, false
+		}
+		positions = append([]int{i}, positions...)
+		return s.applyNested(subtable(sub, 6+2*glyphCount), lookupCount, positions, depth), true
+	}
+	return 0, false
+}
+
+func (s *shaper) applyChainingContext(lookup gsubLookup, sub []byte, i, depth int) (int, bool) {
+	format := binary.BigEndian.Uint16(sub)
+	glyph := s.buffer[i].glyph
+	switch format {
+	case 1, 2:
+		coverage := coverageIndex(subtable(sub, int(binary.BigEndian.Uint16(sub[2:]))), glyph)
+		if coverage < 0 {
+			return 0, false
+		}
+		backtrackMatch, inputMatch, lookaheadMatch := s.matchGlyphID, s.matchGlyphID, s.matchGlyphID
+		setIndex := coverage
+		countOffset := 4
+		if format == 2 {
+			if len(sub) < 12 {
+				return 0, false
+			}
+			backtrackClass := subtable(sub, int(binary.BigEndian.Uint16(sub[4:])))
+			inputClass := subtable(sub, int(binary.BigEndian.Uint16(sub[6:])))
+			lookaheadClass := subtable(sub, int(binary.BigEndian.Uint16(sub[8:])))
+			backtrackMatch = classMatcher(backtrackClass)
+			inputMatch = classMatcher(inputClass)
+			lookaheadMatch = classMatcher(lookaheadClass)
+			setIndex = glyphClass(inputClass, glyph)
+			countOffset = 10
+		}
+		set := ruleSet(sub, setIndex, countOffset)
+		count, _ := u16(set, 0)
+		for r := 0; r < count; r++ {
+			offset, _ := u16(set, 2+2*r)
+			rule := subtable(set, offset)
+			if rule == nil {
+				continue
+			}
+			// Backtrack, input, lookahead and lookup records follow each other
+			backtrackCount, _ := u16(rule, 0)
+			backtrack := subtable(rule, 2)
+			pos := 2 + 2*backtrackCount
+			inputCount, ok := u16(rule, pos)
+			if !ok || inputCount == 0 {
+				continue
+			}
+			input := subtable(rule, pos+2)
+			pos += 2 + 2*(inputCount-1)
+			lookaheadCount, ok := u16(rule, pos)
+			if !ok {
+				continue
+			}
+			lookahead := subtable(rule, pos+2)
+			pos += 2 + 2*lookaheadCount
+			lookupCount, ok := u16(rule, pos)
+			if !ok {
+				continue
+			}
+			next, ok := s.matchChain(lookup.flag, i,
+				backtrack, backtrackCount, backtrackMatch,
+				input, inputCount-1, inputMatch,
+				lookahead, lookaheadCount, lookaheadMatch,
+				subtable(rule, pos+2), lookupCount, depth)
+			if ok {
+				return next, true
+			}
+		}
+	case 3:
+		backtrackCount, _ := u16(sub, 2)
+		pos := 4 + 2*backtrackCount
+		inputCount, ok := u16(sub, pos)
+		if !ok || inputCount == 0 {
+			return 0, false
+		}
+		first, _ := u16(sub, pos+2)
+		if coverageIndex(subtable(sub, first), glyph) < 0 {
+			return 0, false
+		}
+		input := subtable(sub, pos+4)
+		pos += 2 + 2*inputCount
+		lookaheadCount, ok := u16(sub, pos)
+		if !ok {
+			return 0, false
+		}
+		lookahead := subtable(sub, pos+2)
+		pos += 2 + 2*lookaheadCount
+		lookupCount, ok := u16(sub, pos)
+		if !ok {
+			return 0, false
+		}
+		match := coverageMatcher(sub)
+		return s.matchChain(lookup.flag, i,
+			subtable(sub, 4), backtrackCount, match,
+			input, inputCount-1, match,
+			lookahead, lookaheadCount, match,
+			subtable(sub, pos+2), lookupCount, depth)
+	}
+	return 0, false
+}
+
+// Matches the backtrack, input (without the first glyph) and lookahead
+// sequences and applies the nested lookups.
+func (s *shaper) matchChain(flag uint16, i int,
+	backtrack []byte, backtrackCount int, backtrackMatch matchFunc,
+	input []byte, inputCount int, inputMatch matchFunc,
+	lookahead []byte, lookaheadCount int, lookaheadMatch matchFunc,
+	records []byte, lookupCount, depth int) (int, bool) {
+	if backtrackCount > 0 && backtrack == nil || inputCount > 0 && input == nil || lookaheadCount > 0 && lookahead == nil {
+		return 0, false
+	}
+	if _, ok := s.matchSequence(flag, i, -1, backtrack, backtrackCount, backtrackMatch); !ok {
+		return 0, false
+	}
+	positions, ok := s.matchSequence(flag, i, 1, input, inputCount, inputMatch)
+	if !ok {
+		return 0, false
+	}
+	positions = append([]int{i}, positions...)
+	if _, ok := s.matchSequence(flag, positions[len(positions)-1], 1, lookahead, lookaheadCount, lookaheadMatch); !ok {
+		return 0, false
+	}
+	return s.applyNested(records, lookupCount, positions, depth), true
+}
+
+// Reports whether the font has contextual alternates or ligatures.
+func (font *Font) HasLigatures() bool {
+	return font.gsub != nil && font.gsub.hasFeatures
+}
+
+// Returns the glyph of the character without shaping.
+func (font *Font) GlyphIndex(char rune) sfnt.GlyphIndex {
+	index, err := font.handle.GlyphIndex(&font.buffer, char)
+	if err != nil {
+		return 0
+	}
+	return index
+}
+
+// Shapes the characters and returns the glyph buffer.
+func (font *Font) shapeBuffer(chars []rune) []shapedGlyph {
+	buffer := make([]shapedGlyph, len(chars))
+	for i, char := range chars {
+		buffer[i] = shapedGlyph{glyph: font.GlyphIndex(char), cluster: i}
+	}
+	if !font.HasLigatures() {
+		return buffer
+	}
+	s := shaper{table: font.gsub, buffer: buffer}
+	s.shape()
+	return s.buffer
+}
+
+// Returns all glyphs of the shaped characters in order, number of the glyphs
+// may be different than the characters.
+func (font *Font) shapeGlyphs(chars []rune) []sfnt.GlyphIndex {
+	buffer := font.shapeBuffer(chars)
+	glyphs := make([]sfnt.GlyphIndex, len(buffer))
+	for i, glyph := range buffer {
+		glyphs[i] = glyph.glyph
+	}
+	return glyphs
+}
+
+// Shapes the characters with the contextual alternates and ligatures of the
+// font. Returns a glyph for every character, characters which are a part of
+// a ligature drawn by a previous character have NoGlyph. If a glyph is
+// replaced by multiple glyphs, only the first one is returned.
+func (font *Font) Shape(chars []rune) []sfnt.GlyphIndex {
+	glyphs := make([]sfnt.GlyphIndex, len(chars))
+	for i := range glyphs {
+		glyphs[i] = NoGlyph
+	}
+	buffer := font.shapeBuffer(chars)
+	for j := len(buffer) - 1; j >= 0; j-- {
+		// First glyph of the cluster wins
+		glyphs[buffer[j].cluster] = buffer[j].glyph
+	}
+	return glyphs
+}
@@ -0,0 +1,138 @@
+package fontkit
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/sfnt"
+)
+
+func be16(values ...int) []byte {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[2*i:], uint16(v))
+	}
+	return data
+}
+
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+// Format 1 coverage of one glyph
+func coverageOf(glyph sfnt.GlyphIndex) []byte {
+	return be16(1, 1, int(glyph))
+}
+
+// Lookup with one subtable
+func lookupOf(typ int, sub []byte) []byte {
+	return concat(be16(typ, 0, 1, 8), sub)
+}
+
+// Returns a GSUB table for the glyphs of the font. 'calt' replaces '=' with
+// 'B' if another '=' follows it, 'liga' replaces "->" with 'A' and 'smcp',
+// which is not a shaping feature, replaces 'x' with the next glyph.
+func testGsubTable(font *Font) []byte {
+	g := func(char rune) int { return int(font.GlyphIndex(char)) }
+	eq := font.GlyphIndex('=')
+	lookups := [][]byte{
+		// 0: chaining context format 3, input '=' and lookahead '='
+		lookupOf(gsubChainingContext, concat(be16(3, 0, 1, 18, 1, 24, 1, 0, 1), coverageOf(eq), coverageOf(eq))),
+		// 1: single substitution format 2 used by the lookup 0
+		lookupOf(gsubSingle, concat(be16(2, 8, 1, g('B')), coverageOf(eq))),
+		// 2: ligature "->"
+		lookupOf(gsubLigature, concat(be16(1, 8, 1, 14), coverageOf(font.GlyphIndex('-')), be16(1, 4), be16(g('A'), 2, g('>')))),
+		// 3: single substitution format 1
+		lookupOf(gsubSingle, concat(be16(1, 6, 1), coverageOf(font.GlyphIndex('x')))),
+	}
+	lookupList := be16(len(lookups))
+	offset := 2 + 2*len(lookups)
+	for _, lookup := range lookups {
+		lookupList = append(lookupList, be16(offset)...)
+		offset += len(lookup)
+	}
+	lookupList = concat(append([][]byte{lookupList}, lookups...)...)
+	featureList := concat(be16(3),
+		[]byte("calt"), be16(20),
+		[]byte("liga"), be16(26),
+		[]byte("smcp"), be16(32),
+		be16(0, 1, 0), be16(0, 1, 2), be16(0, 1, 3))
+	scriptList := concat(be16(1), []byte("DFLT"), be16(8), be16(4, 0), be16(0, 0xFFFF, 3, 0, 1, 2))
+	header := be16(1, 0, 10, 10+len(scriptList), 10+len(scriptList)+len(featureList))
+	return concat(header, scriptList, featureList, lookupList)
+}
+
+func testLigatureFont(t *testing.T) *Font {
+	font, err := CreateFontFromMem(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font.HasLigatures() {
+		t.Fatal("Go Mono has ligatures")
+	}
+	font.gsub = parseGsub(testGsubTable(font), nil)
+	return font
+}
+
+func TestParseGsubShapingFeatures(t *testing.T) {
+	font := testLigatureFont(t)
+	if !font.HasLigatures() {
+		t.Fatal("font has no ligatures")
+	}
+	// Nested lookup and the lookup of 'smcp' are not applied directly
+	features := font.gsub.features
+	if len(features) != 2 || features[0] != 0 || features[1] != 2 {
+		t.Errorf("lookups of the shaping features are %v, expected [0 2]", features)
+	}
+	if len(font.gsub.lookups) != 4 {
+		t.Errorf("table has %d lookups, expected 4", len(font.gsub.lookups))
+	}
+}
+
+func TestShapeLigatures(t *testing.T) {
+	font := testLigatureFont(t)
+	g := font.GlyphIndex
+	for _, test := range []struct {
+		text string
+		want []sfnt.GlyphIndex
+	}{
+		{"->", []sfnt.GlyphIndex{g('A'), NoGlyph}},
+		{"a->b", []sfnt.GlyphIndex{g('a'), g('A'), NoGlyph, g('b')}},
+		{"-->", []sfnt.GlyphIndex{g('-'), g('A'), NoGlyph}},
+		{"-=>", []sfnt.GlyphIndex{g('-'), g('='), g('>')}},
+		{"==", []sfnt.GlyphIndex{g('B'), g('=')}},
+		{"===", []sfnt.GlyphIndex{g('B'), g('B'), g('=')}},
+		{"=", []sfnt.GlyphIndex{g('=')}},
+		{"x", []sfnt.GlyphIndex{g('x')}},
+	} {
+		got := font.Shape([]rune(test.text))
+		if len(got) != len(test.want) {
+			t.Errorf("%q is shaped to %d glyphs, expected %d", test.text, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%q is shaped to %v, expected %v", test.text, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestShapeTruncatedGsub(t *testing.T) {
+	font := testLigatureFont(t)
+	gsub := testGsubTable(font)
+	// Broken tables must not crash the shaping
+	for i := range gsub {
+		font.gsub = parseGsub(gsub[:i], nil)
+		if font.gsub == nil {
+			continue
+		}
+		font.Shape([]rune("a->b===x"))
+	}
+}
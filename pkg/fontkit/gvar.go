@@ -0,0 +1,742 @@
+package fontkit
+
+import (
+	"encoding/binary"
+	"math"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// TrueType glyph outlines and their variations. Outlines are loaded in font
+// units, deltas of the glyph variations are applied and then converted to
+// sfnt segments for rasterizing.
+// https://docs.microsoft.com/en-us/typography/opentype/spec/glyf
+// https://docs.microsoft.com/en-us/typography/opentype/spec/gvar
+
+const (
+	// Composite glyphs can't be nested deeper than this
+	maxCompositeDepth = 8
+	// Number of phantom points added to the glyph points in gvar
+	phantomPoints = 4
+)
+
+// Simple glyph flags
+const (
+	glyfOnCurve    = 0x01
+	glyfXShort     = 0x02
+	glyfYShort     = 0x04
+	glyfRepeat     = 0x08
+	glyfXSameOrPos = 0x10
+	glyfYSameOrPos = 0x20
+)
+
+// Composite glyph flags
+const (
+	glyfArgsAreWords   = 0x0001
+	glyfArgsAreXY      = 0x0002
+	glyfHaveScale      = 0x0008
+	glyfMoreComponents = 0x0020
+	glyfHaveXYScale    = 0x0040
+	glyfHaveTwoByTwo   = 0x0080
+)
+
+// Tuple variation flags
+const (
+	tupleSharedPoints  = 0x8000
+	tupleCountMask     = 0x0FFF
+	tupleEmbeddedPeak  = 0x8000
+	tupleIntermediate  = 0x4000
+	tuplePrivatePoints = 0x2000
+	tupleIndexMask     = 0x0FFF
+	deltasAreZero      = 0x80
+	deltasAreWords     = 0x40
+	deltaRunCountMask  = 0x3F
+	pointsAreWords     = 0x80
+	pointRunCountMask  = 0x7F
+)
+
+type glyfTable struct {
+	glyf       []byte
+	offsets    []uint32 // From loca, one more than the number of glyphs
+	unitsPerEm float64
+}
+
+type gvarTable struct {
+	axisCount    int
+	sharedTuples [][]float64
+	data         []byte   // Glyph variation data array
+	offsets      []uint32 // One more than the number of glyphs
+}
+
+type glyphPoint struct {
+	x, y    float64
+	onCurve bool
+}
+
+// A glyph outline in font units, y increases up
+type glyphOutline struct {
+	points    []glyphPoint
+	endPoints []int // Last point index of every contour
+}
+
+type glyphComponent struct {
+	index  sfnt.GlyphIndex
+	dx, dy float64
+	xx, xy float64 // Transform matrix, x' = xx*x + yx*y + dx
+	yx, yy float64
+}
+
+func parseGlyf(glyf, loca, head []byte) *glyfTable {
+	if len(head) < 54 || loca == nil {
+		return nil
+	}
+	table := &glyfTable{
+		glyf:       glyf,
+		unitsPerEm: float64(binary.BigEndian.Uint16(head[18:])),
+	}
+	if binary.BigEndian.Uint16(head[50:]) == 0 {
+		table.offsets = make([]uint32, len(loca)/2)
+		for i := range table.offsets {
+			table.offsets[i] = 2 * uint32(binary.BigEndian.Uint16(loca[2*i:]))
+		}
+	} else {
+		table.offsets = make([]uint32, len(loca)/4)
+		for i := range table.offsets {
+			table.offsets[i] = binary.BigEndian.Uint32(loca[4*i:])
+		}
+	}
+	if len(table.offsets) < 2 || table.unitsPerEm == 0 {
+		return nil
+	}
+	return table
+}
+
+func parseGvar(gvar []byte, axisCount int) *gvarTable {
+	if len(gvar) < 20 || int(binary.BigEndian.Uint16(gvar[4:])) != axisCount {
+		return nil
+	}
+	table := &gvarTable{axisCount: axisCount}
+	sharedCount := int(binary.BigEndian.Uint16(gvar[6:]))
+	sharedOffset := int(binary.BigEndian.Uint32(gvar[8:]))
+	glyphCount := int(binary.BigEndian.Uint16(gvar[12:]))
+	flags := binary.BigEndian.Uint16(gvar[14:])
+	dataOffset := int(binary.BigEndian.Uint32(gvar[16:]))
+	if sharedOffset+2*axisCount*sharedCount > len(gvar) || dataOffset > len(gvar) {
+		return nil
+	}
+	for i := 0; i < sharedCount; i++ {
+		tuple := make([]float64, axisCount)
+		for j := range tuple {
+			tuple[j] = f2dot14ToFloat(binary.BigEndian.Uint16(gvar[sharedOffset+2*(i*axisCount+j):]))
+		}
+		table.sharedTuples = append(table.sharedTuples, tuple)
+	}
+	table.offsets = make([]uint32, glyphCount+1)
+	if flags&1 == 0 {
+		if 20+2*len(table.offsets) > len(gvar) {
+			return nil
+		}
+		for i := range table.offsets {
+			table.offsets[i] = 2 * uint32(binary.BigEndian.Uint16(gvar[20+2*i:]))
+		}
+	} else {
+		if 20+4*len(table.offsets) > len(gvar) {
+			return nil
+		}
+		for i := range table.offsets {
+			table.offsets[i] = binary.BigEndian.Uint32(gvar[20+4*i:])
+		}
+	}
+	table.data = gvar[dataOffset:]
+	return table
+}
+
+func (table *glyfTable) glyphData(index sfnt.GlyphIndex) []byte {
+	i := int(index)
+	if i+1 >= len(table.offsets) {
+		return nil
+	}
+	start, end := table.offsets[i], table.offsets[i+1]
+	if start >= end || uint64(end) > uint64(len(table.glyf)) {
+		return nil
+	}
+	return table.glyf[start:end]
+}
+
+// Parses a simple glyph. Returns false if the data is invalid.
+func parseSimpleGlyph(data []byte, numContours int) (glyphOutline, bool) {
+	var outline glyphOutline
+	offset := 10
+	if offset+2*numContours+2 > len(data) {
+		return outline, false
+	}
+	outline.endPoints = make([]int, numContours)
+	for i := range outline.endPoints {
+		outline.endPoints[i] = int(binary.BigEndian.Uint16(data[offset+2*i:]))
+	}
+	offset += 2 * numContours
+	numPoints := outline.endPoints[numContours-1] + 1
+	instructionLength := int(binary.BigEndian.Uint16(data[offset:]))
+	offset += 2 + instructionLength
+	// Flags
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints {
+		if offset >= len(data) {
+			return outline, false
+		}
+		flag := data[offset]
+		offset++
+		flags = append(flags, flag)
+		if flag&glyfRepeat != 0 {
+			if offset >= len(data) {
+				return outline, false
+			}
+			count := int(data[offset])
+			offset++
+			for j := 0; j < count && len(flags) < numPoints; j++ {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	outline.points = make([]glyphPoint, numPoints)
+	// Coordinates are relative to the previous point
+	readCoords := func(short, sameOrPos byte, set func(i int, v float64)) bool {
+		value := 0
+		for i, flag := range flags {
+			if flag&short != 0 {
+				if offset >= len(data) {
+					return false
+				}
+				d := int(data[offset])
+				offset++
+				if flag&sameOrPos == 0 {
+					d = -d
+				}
+				value += d
+			} else if flag&sameOrPos == 0 {
+				if offset+2 > len(data) {
+					return false
+				}
+				value += int(int16(binary.BigEndian.Uint16(data[offset:])))
+				offset += 2
+			}
+			set(i, float64(value))
+		}
+		return true
+	}
+	ok := readCoords(glyfXShort, glyfXSameOrPos, func(i int, v float64) { outline.points[i].x = v })
+	if !ok {
+		return outline, false
+	}
+	ok = readCoords(glyfYShort, glyfYSameOrPos, func(i int, v float64) { outline.points[i].y = v })
+	if !ok {
+		return outline, false
+	}
+	for i, flag := range flags {
+		outline.points[i].onCurve = flag&glyfOnCurve != 0
+	}
+	return outline, true
+}
+
+func parseCompositeGlyph(data []byte) ([]glyphComponent, bool) {
+	components := []glyphComponent{}
+	offset := 10
+	for {
+		if offset+4 > len(data) {
+			return nil, false
+		}
+		flags := binary.BigEndian.Uint16(data[offset:])
+		c := glyphComponent{
+			index: sfnt.GlyphIndex(binary.BigEndian.Uint16(data[offset+2:])),
+			xx:    1,
+			yy:    1,
+		}
+		offset += 4
+		if flags&glyfArgsAreWords != 0 {
+			if offset+4 > len(data) {
+				return nil, false
+			}
+			c.dx = float64(int16(binary.BigEndian.Uint16(data[offset:])))
+			c.dy = float64(int16(binary.BigEndian.Uint16(data[offset+2:])))
+			offset += 4
+		} else {
+			if offset+2 > len(data) {
+				return nil, false
+			}
+			c.dx = float64(int8(data[offset]))
+			c.dy = float64(int8(data[offset+1]))
+			offset += 2
+		}
+		if flags&glyfArgsAreXY == 0 {
+			// Point matching is not supported
+			c.dx, c.dy = 0, 0
+		}
+		readScale := func() float64 {
+			if offset+2 > len(data) {
+				return 1
+			}
+			v := f2dot14ToFloat(binary.BigEndian.Uint16(data[offset:]))
+			offset += 2
+			return v
+		}
+		switch {
+		case flags&glyfHaveScale != 0:
+			c.xx = readScale()
+			c.yy = c.xx
+		case flags&glyfHaveXYScale != 0:
+			c.xx = readScale()
+			c.yy = readScale()
+		case flags&glyfHaveTwoByTwo != 0:
+			c.xx = readScale()
+			c.xy = readScale()
+			c.yx = readScale()
+			c.yy = readScale()
+		}
+		components = append(components, c)
+		if flags&glyfMoreComponents == 0 {
+			return components, true
+		}
+	}
+}
+
+// Loads the glyph outline with the variation applied. Coordinates are
+// normalized axis coordinates.
+func (vf *variableFont) loadOutline(index sfnt.GlyphIndex, coords []float64, depth int) (glyphOutline, bool) {
+	var outline glyphOutline
+	data := vf.glyphs.glyphData(index)
+	if data == nil {
+		// Empty glyph, like space
+		return outline, true
+	}
+	if len(data) < 10 || depth > maxCompositeDepth {
+		return outline, false
+	}
+	numContours := int(int16(binary.BigEndian.Uint16(data)))
+	if numContours == 0 {
+		return outline, true
+	}
+	if numContours > 0 {
+		outline, ok := parseSimpleGlyph(data, numContours)
+		if !ok {
+			return outline, false
+		}
+		deltas := vf.gvar.glyphDeltas(index, coords, len(outline.points)+phantomPoints, outline)
+		for i := range outline.points {
+			outline.points[i].x += deltas[i].x
+			outline.points[i].y += deltas[i].y
+		}
+		return outline, true
+	}
+	components, ok := parseCompositeGlyph(data)
+	if !ok {
+		return outline, false
+	}
+	// Every component has a point for it's offset
+	deltas := vf.gvar.glyphDeltas(index, coords, len(components)+phantomPoints, glyphOutline{})
+	for i, c := range components {
+		child, ok := vf.loadOutline(c.index, coords, depth+1)
+		if !ok {
+			return outline, false
+		}
+		dx := c.dx + deltas[i].x
+		dy := c.dy + deltas[i].y
+		start := len(outline.points)
+		for _, p := range child.points {
+			outline.points = append(outline.points, glyphPoint{
+				x:       c.xx*p.x + c.yx*p.y + dx,
+				y:       c.xy*p.x + c.yy*p.y + dy,
+				onCurve: p.onCurve,
+			})
+		}
+		for _, end := range child.endPoints {
+			outline.endPoints = append(outline.endPoints, start+end)
+		}
+	}
+	return outline, true
+}
+
+type pointDelta struct {
+	x, y float64
+}
+
+// Returns the scalar of the tuple for the coordinates.
+func tupleScalar(peak, start, end, coords []float64) float64 {
+	scalar := 1.0
+	for i, p := range peak {
+		if p == 0 {
+			continue
+		}
+		v := coords[i]
+		if v == 0 {
+			return 0
+		}
+		if start != nil {
+			if v < start[i] || v > end[i] {
+				return 0
+			}
+			if v < p && p != start[i] {
+				scalar *= (v - start[i]) / (p - start[i])
+			} else if v > p && p != end[i] {
+				scalar *= (end[i] - v) / (end[i] - p)
+			}
+		} else {
+			if v < math.Min(0, p) || v > math.Max(0, p) {
+				return 0
+			}
+			if v != p {
+				scalar *= v / p
+			}
+		}
+	}
+	return scalar
+}
+
+// Reads packed point numbers. Returns nil for all points.
+func readPackedPoints(data []byte, offset *int) ([]int, bool) {
+	if *offset >= len(data) {
+		return nil, false
+	}
+	count := int(data[*offset])
+	*offset++
+	if count == 0 {
+		return nil, true
+	}
+	if count&pointsAreWords != 0 {
+		if *offset >= len(data) {
+			return nil, false
+		}
+		count = (count&pointRunCountMask)<<8 | int(data[*offset])
+		*offset++
+	}
+	points := make([]int, 0, count)
+	point := 0
+	for len(points) < count {
+		if *offset >= len(data) {
+			return nil, false
+		}
+		control := data[*offset]
+		*offset++
+		run := int(control&pointRunCountMask) + 1
+		for j := 0; j < run && len(points) < count; j++ {
+			if control&pointsAreWords != 0 {
+				if *offset+2 > len(data) {
+					return nil, false
+				}
+				point += int(binary.BigEndian.Uint16(data[*offset:]))
+				*offset += 2
+			} else {
+				if *offset >= len(data) {
+					return nil, false
+				}
+				point += int(data[*offset])
+				*offset++
+			}
+			points = append(points, point)
+		}
+	}
+	return points, true
+}
+
+func readPackedDeltas(data []byte, offset *int, count int) ([]float64, bool) {
+	deltas := make([]float64, 0, count)
+	for len(deltas) < count {
+		if *offset >= len(data) {
+			return nil, false
+		}
+		control := data[*offset]
+		*offset++
+		run := int(control&deltaRunCountMask) + 1
+		for j := 0; j < run && len(deltas) < count; j++ {
+			switch {
+			case control&deltasAreZero != 0:
+				deltas = append(deltas, 0)
+			case control&deltasAreWords != 0:
+				if *offset+2 > len(data) {
+					return nil, false
+				}
+				deltas = append(deltas, float64(int16(binary.BigEndian.Uint16(data[*offset:]))))
+				*offset += 2
+			default:
+				if *offset >= len(data) {
+					return nil, false
+				}
+				deltas = append(deltas, float64(int8(data[*offset])))
+				*offset++
+			}
+		}
+	}
+	return deltas, true
+}
+
+// Returns the total deltas of the glyph points for the coordinates. Outline
+// is used for interpolating the untouched points, empty for composite
+// glyphs. Invalid variation data is ignored.
+func (table *gvarTable) glyphDeltas(index sfnt.GlyphIndex, coords []float64, numPoints int, outline glyphOutline) []pointDelta {
+	total := make([]pointDelta, numPoints)
+	i := int(index)
+	if i+1 >= len(table.offsets) {
+		return total
+	}
+	start, end := table.offsets[i], table.offsets[i+1]
+	if start >= end || uint64(end) > uint64(len(table.data)) {
+		return total
+	}
+	data := table.data[start:end]
+	if len(data) < 4 {
+		return total
+	}
+	tupleCount := int(binary.BigEndian.Uint16(data))
+	serialized := int(binary.BigEndian.Uint16(data[2:]))
+	var sharedPoints []int
+	if tupleCount&tupleSharedPoints != 0 {
+		var ok bool
+		sharedPoints, ok = readPackedPoints(data, &serialized)
+		if !ok {
+			return total
+		}
+	}
+	header := 4
+	axisCount := table.axisCount
+	for t := 0; t < tupleCount&tupleCountMask; t++ {
+		if header+4 > len(data) {
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[header:]))
+		tupleIndex := binary.BigEndian.Uint16(data[header+2:])
+		header += 4
+		var peak, startTuple, endTuple []float64
+		readTuple := func() []float64 {
+			if header+2*axisCount > len(data) {
+				return nil
+			}
+			tuple := make([]float64, axisCount)
+			for j := range tuple {
+				tuple[j] = f2dot14ToFloat(binary.BigEndian.Uint16(data[header+2*j:]))
+			}
+			header += 2 * axisCount
+			return tuple
+		}
+		if tupleIndex&tupleEmbeddedPeak != 0 {
+			peak = readTuple()
+		} else if int(tupleIndex&tupleIndexMask) < len(table.sharedTuples) {
+			peak = table.sharedTuples[tupleIndex&tupleIndexMask]
+		}
+		if tupleIndex&tupleIntermediate != 0 {
+			startTuple = readTuple()
+			endTuple = readTuple()
+			// Header is truncated
+			if startTuple == nil || endTuple == nil {
+				break
+			}
+		}
+		tupleData := serialized
+		serialized += size
+		if peak == nil || serialized > len(data) {
+			break
+		}
+		scalar := tupleScalar(peak, startTuple, endTuple, coords)
+		if scalar == 0 {
+			continue
+		}
+		points := sharedPoints
+		offset := tupleData
+		if tupleIndex&tuplePrivatePoints != 0 {
+			var ok bool
+			points, ok = readPackedPoints(data[:serialized], &offset)
+			if !ok {
+				continue
+			}
+		}
+		count := numPoints
+		if points != nil {
+			count = len(points)
+		}
+		xDeltas, ok := readPackedDeltas(data[:serialized], &offset, count)
+		if !ok {
+			continue
+		}
+		yDeltas, ok := readPackedDeltas(data[:serialized], &offset, count)
+		if !ok {
+			continue
+		}
+		if points == nil {
+			for j := range total {
+				total[j].x += scalar * xDeltas[j]
+				total[j].y += scalar * yDeltas[j]
+			}
+			continue
+		}
+		deltas := make([]pointDelta, numPoints)
+		touched := make([]bool, numPoints)
+		for j, p := range points {
+			if p < numPoints {
+				deltas[p] = pointDelta{xDeltas[j], yDeltas[j]}
+				touched[p] = true
+			}
+		}
+		if len(outline.points) > 0 {
+			interpolateUntouched(outline, deltas, touched)
+		}
+		for j := range total {
+			total[j].x += scalar * deltas[j].x
+			total[j].y += scalar * deltas[j].y
+		}
+	}
+	return total
+}
+
+// Infers the deltas of the untouched points from the touched points of the
+// same contour (IUP).
+func interpolateUntouched(outline glyphOutline, deltas []pointDelta, touched []bool) {
+	start := 0
+	for _, end := range outline.endPoints {
+		if end >= len(outline.points) {
+			break
+		}
+		touchedPoints := []int{}
+		for i := start; i <= end; i++ {
+			if touched[i] {
+				touchedPoints = append(touchedPoints, i)
+			}
+		}
+		switch len(touchedPoints) {
+		case 0:
+		case 1:
+			// Whole contour is shifted
+			d := deltas[touchedPoints[0]]
+			for i := start; i <= end; i++ {
+				deltas[i] = d
+			}
+		default:
+			for k, p1 := range touchedPoints {
+				p2 := touchedPoints[(k+1)%len(touchedPoints)]
+				// Untouched points between p1 and p2, wrapping around the contour
+				for i := p1 + 1; ; i++ {
+					if i > end {
+						i = start
+					}
+					if i == p2 {
+						break
+					}
+					deltas[i].x = interpolateDelta(outline.points[i].x, outline.points[p1].x, outline.points[p2].x, deltas[p1].x, deltas[p2].x)
+					deltas[i].y = interpolateDelta(outline.points[i].y, outline.points[p1].y, outline.points[p2].y, deltas[p1].y, deltas[p2].y)
+				}
+			}
+		}
+		start = end + 1
+	}
+}
+
+func interpolateDelta(v, v1, v2, d1, d2 float64) float64 {
+	if v1 == v2 {
+		if d1 == d2 {
+			return d1
+		}
+		return 0
+	}
+	if v1 > v2 {
+		v1, v2 = v2, v1
+		d1, d2 = d2, d1
+	}
+	switch {
+	case v <= v1:
+		return d1
+	case v >= v2:
+		return d2
+	default:
+		return d1 + (d2-d1)*(v-v1)/(v2-v1)
+	}
+}
+
+// Converts the outline to the sfnt segments in pixels, y increases down like
+// the segments of the sfnt.
+func (outline glyphOutline) segments(scale float64) sfnt.Segments {
+	segments := sfnt.Segments{}
+	point := func(p glyphPoint) fixed.Point26_6 {
+		return fixed.Point26_6{
+			X: fixed.Int26_6(math.Round(p.x * scale * 64)),
+			Y: fixed.Int26_6(math.Round(-p.y * scale * 64)),
+		}
+	}
+	midpoint := func(a, b glyphPoint) glyphPoint {
+		return glyphPoint{x: (a.x + b.x) / 2, y: (a.y + b.y) / 2, onCurve: true}
+	}
+	start := 0
+	for _, end := range outline.endPoints {
+		if end >= len(outline.points) || end < start {
+			break
+		}
+		contour := outline.points[start : end+1]
+		start = end + 1
+		// Contour must start with an on curve point
+		var first glyphPoint
+		rest := contour
+		switch {
+		case contour[0].onCurve:
+			first = contour[0]
+			rest = contour[1:]
+		case contour[len(contour)-1].onCurve:
+			first = contour[len(contour)-1]
+			rest = contour[:len(contour)-1]
+		default:
+			first = midpoint(contour[len(contour)-1], contour[0])
+		}
+		segments = append(segments, sfnt.Segment{
+			Op:   sfnt.SegmentOpMoveTo,
+			Args: [3]fixed.Point26_6{point(first)},
+		})
+		// Two consecutive off curve points have an implicit on curve point
+		// between them
+		var control *glyphPoint
+		for _, p := range rest {
+			if p.onCurve {
+				if control != nil {
+					segments = append(segments, sfnt.Segment{
+						Op:   sfnt.SegmentOpQuadTo,
+						Args: [3]fixed.Point26_6{point(*control), point(p)},
+					})
+					control = nil
+				} else {
+					segments = append(segments, sfnt.Segment{
+						Op:   sfnt.SegmentOpLineTo,
+						Args: [3]fixed.Point26_6{point(p)},
+					})
+				}
+				continue
+			}
+			if control != nil {
+				segments = append(segments, sfnt.Segment{
+					Op:   sfnt.SegmentOpQuadTo,
+					Args: [3]fixed.Point26_6{point(*control), point(midpoint(*control, p))},
+				})
+			}
+			c := p
+			control = &c
+		}
+		// Close the contour
+		if control != nil {
+			segments = append(segments, sfnt.Segment{
+				Op:   sfnt.SegmentOpQuadTo,
+				Args: [3]fixed.Point26_6{point(*control), point(first)},
+			})
+		} else {
+			segments = append(segments, sfnt.Segment{
+				Op:   sfnt.SegmentOpLineTo,
+				Args: [3]fixed.Point26_6{point(first)},
+			})
+		}
+	}
+	return segments
+}
+
+// Loads the glyph segments of the variation in pixels. Returns false if the
+// glyph can't be loaded.
+func (vf *variableFont) loadSegments(index sfnt.GlyphIndex, coords []float64, ppem fixed.Int26_6) (sfnt.Segments, bool) {
+	outline, ok := vf.loadOutline(index, coords, 0)
+	if !ok {
+		return nil, false
+	}
+	scale := float64(ppem) / 64 / vf.glyphs.unitsPerEm
+	return outline.segments(scale), true
+}
@@ -0,0 +1,27 @@
+package fontkit
+
+import (
+	"testing"
+)
+
+func TestGlyphDeltasTruncatedIntermediateTuple(t *testing.T) {
+	// Header of the only tuple has an embedded peak and an intermediate
+	// region, the data ends after the start tuple
+	data := []byte("00\x00\x00\x00\x01\xff000\xff0")
+	table := &gvarTable{
+		axisCount: 1,
+		data:      data,
+		offsets:   []uint32{0, uint32(len(data))},
+	}
+	for _, coord := range []float64{-1, -0.5, 0.5, 0.75, 1} {
+		deltas := table.glyphDeltas(0, []float64{coord}, 4, glyphOutline{})
+		if len(deltas) != 4 {
+			t.Fatalf("glyph has %d deltas, expected 4", len(deltas))
+		}
+		for i, delta := range deltas {
+			if delta != (pointDelta{}) {
+				t.Errorf("point %d has delta %v from the invalid data", i, delta)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package fontkit
+
+import (
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// The opentype package doesn't hint the glyph outlines, it only rounds the
+// metrics. Glyphs are grid fitted here before the rasterization. Edges of the
+// outline, the straight lines and the curve extremes parallel to an axis, are
+// moved to the pixel boundaries and the other points are interpolated between
+// them. Edges keep their order and separate edges stay at least one pixel
+// apart, so thin stems don't disappear. Vertical hinting only fits the
+// horizontal edges (baseline, x-height, bars), full hinting fits the stems
+// too.
+
+// Minimum distance of the edges in 26.6 to be fitted as separate edges
+const hintMinEdgeDistance = 16
+
+// Returns the grid fitted copy of the segments, or the segments itself if
+// the hinting doesn't change them. Segments must be relative to a pixel
+// aligned origin.
+func hintSegments(segments sfnt.Segments, hinting Hinting) sfnt.Segments {
+	if hinting != HintingVertical && hinting != HintingFull {
+		return segments
+	}
+	hinted := make(sfnt.Segments, len(segments))
+	copy(hinted, segments)
+	fitAxis(hinted, pointY)
+	if hinting == HintingFull {
+		fitAxis(hinted, pointX)
+	}
+	return hinted
+}
+
+func pointX(p *fixed.Point26_6) *fixed.Int26_6 { return &p.X }
+func pointY(p *fixed.Point26_6) *fixed.Int26_6 { return &p.Y }
+
+// Fits the coordinates of the axis returned by coord.
+func fitAxis(segments sfnt.Segments, coord func(*fixed.Point26_6) *fixed.Int26_6) {
+	edges := findEdges(segments, coord)
+	if len(edges) == 0 {
+		return
+	}
+	fitted := make([]fixed.Int26_6, len(edges))
+	for i, edge := range edges {
+		fitted[i] = fixed.I((edge + 32).Floor())
+		if i == 0 || fitted[i] > fitted[i-1] {
+			continue
+		}
+		if edge-edges[i-1] < hintMinEdgeDistance {
+			// Close edges may share a pixel, but the previous one may be
+			// moved after this one
+			fitted[i] = fitted[i-1]
+			continue
+		}
+		// Separate edges are rounded to the same pixel, move the one closer
+		// to the other pixel. Moving the previous one must not make it
+		// collide with its previous edge.
+		next := fitted[i-1] + 64
+		prev := fitted[i-1] - 64
+		if (i == 1 || prev > fitted[i-2]) && absFixed(edges[i-1]-prev) < absFixed(edge-next) {
+			fitted[i-1] = prev
+		} else {
+			fitted[i] = next
+		}
+	}
+	for i := range segments {
+		for j := range segments[i].Args {
+			v := coord(&segments[i].Args[j])
+			*v = interpolateEdges(*v, edges, fitted)
+		}
+	}
+}
+
+// Returns the sorted unique coordinates of the edges parallel to the other
+// axis. The other axis is the one coord doesn't return.
+func findEdges(segments sfnt.Segments, coord func(*fixed.Point26_6) *fixed.Int26_6) []fixed.Int26_6 {
+	found := make(map[fixed.Int26_6]bool)
+	var pen, start fixed.Point26_6
+	closeContour := func() {
+		if *coord(&pen) == *coord(&start) {
+			found[*coord(&pen)] = true
+		}
+	}
+	for i, seg := range segments {
+		args := seg.Args
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				closeContour()
+			}
+			start = args[0]
+			pen = args[0]
+			continue
+		case sfnt.SegmentOpLineTo:
+			if *coord(&args[0]) == *coord(&pen) {
+				found[*coord(&pen)] = true
+			}
+			pen = args[0]
+		case sfnt.SegmentOpQuadTo:
+			// Tangents at the ends are parallel to the control lines
+			if *coord(&args[0]) == *coord(&pen) {
+				found[*coord(&pen)] = true
+			}
+			if *coord(&args[0]) == *coord(&args[1]) {
+				found[*coord(&args[1])] = true
+			}
+			pen = args[1]
+		case sfnt.SegmentOpCubeTo:
+			if *coord(&args[0]) == *coord(&pen) {
+				found[*coord(&pen)] = true
+			}
+			if *coord(&args[1]) == *coord(&args[2]) {
+				found[*coord(&args[2])] = true
+			}
+			pen = args[2]
+		}
+	}
+	if len(segments) > 0 {
+		closeContour()
+	}
+	edges := make([]fixed.Int26_6, 0, len(found))
+	for edge := range found {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i] < edges[j] })
+	return edges
+}
+
+// Moves the coordinate with the edges. Points outside the edges move with
+// the nearest edge, others are interpolated between the edges around them.
+func interpolateEdges(v fixed.Int26_6, edges, fitted []fixed.Int26_6) fixed.Int26_6 {
+	i := sort.Search(len(edges), func(i int) bool { return edges[i] >= v })
+	switch {
+	case i == len(edges):
+		return v + fitted[i-1] - edges[i-1]
+	case edges[i] == v:
+		return fitted[i]
+	case i == 0:
+		return v + fitted[0] - edges[0]
+	}
+	lo, hi := edges[i-1], edges[i]
+	return fitted[i-1] + fixed.Int26_6(int64(v-lo)*int64(fitted[i]-fitted[i-1])/int64(hi-lo))
+}
+
+func absFixed(v fixed.Int26_6) fixed.Int26_6 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package fontkit
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/vector"
+)
+
+// Draws the Powerline separators to the whole cell, they don't leave any gap
+// between the cells even if the line space is used.
+// https://github.com/ryanoasis/powerline-extra-symbols
+func (face *Face) DrawPowerlineGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	thickness := face.thickness
+
+	img := face.cachedImage(imgSize)
+	w := float32(imgSize.Width())
+	h := float32(imgSize.Height())
+
+	var (
+		topLeft     = common.Vector2[float32]{}
+		topRight    = common.Vec2(w, 0)
+		bottomLeft  = common.Vec2(0, h)
+		bottomRight = common.Vec2(w, h)
+		centerLeft  = common.Vec2(0, h/2)
+		centerRight = common.Vec2(w, h/2)
+	)
+
+	r := vector.NewRasterizer(imgSize.Width(), imgSize.Height())
+
+	switch char {
+	case 0xE0B0: // right pointing triangle
+		rastPolygon(r, topLeft, centerRight, bottomLeft)
+	case 0xE0B1: // right pointing angle
+		rastLine(r, topLeft, centerRight, thickness)
+		rastLine(r, centerRight, bottomLeft, thickness)
+	case 0xE0B2: // left pointing triangle
+		rastPolygon(r, topRight, bottomRight, centerLeft)
+	case 0xE0B3: // left pointing angle
+		rastLine(r, topRight, centerLeft, thickness)
+		rastLine(r, centerLeft, bottomRight, thickness)
+
+	case 0xE0B4: // right half circle
+		r.MoveTo(topLeft.X, topLeft.Y)
+		rastHalfEllipse(r, centerLeft, common.Vec2(w, h/2), 1, false)
+		r.ClosePath()
+	case 0xE0B5: // right half circle outline
+		r.MoveTo(topLeft.X, topLeft.Y)
+		rastHalfEllipse(r, centerLeft, common.Vec2(w, h/2), 1, false)
+		r.LineTo(bottomLeft.X, bottomLeft.Y-thickness)
+		rastHalfEllipse(r, centerLeft, common.Vec2(w-thickness, h/2-thickness), 1, true)
+		r.ClosePath()
+	case 0xE0B6: // left half circle
+		r.MoveTo(topRight.X, topRight.Y)
+		rastHalfEllipse(r, centerRight, common.Vec2(w, h/2), -1, false)
+		r.ClosePath()
+	case 0xE0B7: // left half circle outline
+		r.MoveTo(topRight.X, topRight.Y)
+		rastHalfEllipse(r, centerRight, common.Vec2(w, h/2), -1, false)
+		r.LineTo(bottomRight.X, bottomRight.Y-thickness)
+		rastHalfEllipse(r, centerRight, common.Vec2(w-thickness, h/2-thickness), -1, true)
+		r.ClosePath()
+
+	// Slants, thin ones are the hypotenuses of the triangles
+	case 0xE0B8: // lower left triangle
+		rastPolygon(r, topLeft, bottomRight, bottomLeft)
+	case 0xE0B9, 0xE0BF: // backslash separator
+		rastLine(r, topLeft, bottomRight, thickness)
+	case 0xE0BA: // lower right triangle
+		rastPolygon(r, topRight, bottomRight, bottomLeft)
+	case 0xE0BB, 0xE0BD: // forward slash separator
+		rastLine(r, topRight, bottomLeft, thickness)
+	case 0xE0BC: // upper left triangle
+		rastPolygon(r, topLeft, topRight, bottomLeft)
+	case 0xE0BE: // upper right triangle
+		rastPolygon(r, topLeft, topRight, bottomRight)
+
+	default:
+		panic(fmt.Errorf("missing powerline glyph %d (%s)", char, string(char)))
+	}
+
+	r.Draw(img, img.Bounds(), image.White, image.Point{})
+	return img
+}
+
+// Reports whether the char is one of the Powerline separators we can draw.
+// These chars don't need a font containing them.
+func IsPowerlineChar(char rune) bool {
+	return char >= 0xE0B0 && char <= 0xE0BF
+}
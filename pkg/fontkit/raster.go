@@ -0,0 +1,98 @@
+package fontkit
+
+import (
+	"image"
+	"math"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/vector"
+)
+
+// Weights of the five tap filter which spreads every subpixel sample to the
+// neighbour channels, it reduces the color fringes. Same with the default LCD
+// filter of FreeType, sum of them is 256.
+var lcdFilter = [5]int{8, 77, 86, 77, 8}
+
+// Rasterizes the outlines added by rast to an image of the size with the
+// antialiasing of the face and applies the coverage curve. rast must
+// multiply the x coordinates with the scale, subpixel antialiasing
+// rasterizes three samples for every pixel.
+func (face *Face) rasterize(size common.Vector2[int], rast func(r *vector.Rasterizer, scale float32)) *image.RGBA {
+	img := face.cachedImage(size)
+	switch face.antialias {
+	case AntialiasSubpixelRGB, AntialiasSubpixelBGR:
+		r := vector.NewRasterizer(size.Width()*3, size.Height())
+		rast(r, 3)
+		samples := image.NewAlpha(image.Rect(0, 0, size.Width()*3, size.Height()))
+		r.Draw(samples, samples.Rect, image.Opaque, image.Point{})
+		filterSubpixels(img, samples, face.antialias == AntialiasSubpixelBGR)
+	default:
+		r := vector.NewRasterizer(size.Width(), size.Height())
+		rast(r, 1)
+		r.Draw(img, img.Rect, image.White, image.Point{})
+		if face.antialias == AntialiasNone {
+			aliasImage(img, img.Rect)
+		}
+	}
+	if face.curve != nil {
+		applyCurve(img, face.curve)
+	}
+	return img
+}
+
+// Filters the samples of the subpixels to the color channels of the image.
+// Samples are three times wider than the image. Every channel is the coverage
+// of its subpixel and the alpha is the maximum of them, shaders blend the
+// channels separately.
+func filterSubpixels(img *image.RGBA, samples *image.Alpha, bgr bool) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < height; y++ {
+		row := samples.Pix[y*samples.Stride : y*samples.Stride+width*3]
+		for x := 0; x < width; x++ {
+			var channels [3]uint8
+			for c := range channels {
+				sum := 0
+				for k, weight := range lcdFilter {
+					if i := x*3 + c + k - 2; i >= 0 && i < len(row) {
+						sum += weight * int(row[i])
+					}
+				}
+				channels[c] = uint8(sum >> 8)
+			}
+			if bgr {
+				// Leftmost subpixel is blue
+				channels[0], channels[2] = channels[2], channels[0]
+			}
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = channels[0]
+			img.Pix[i+1] = channels[1]
+			img.Pix[i+2] = channels[2]
+			img.Pix[i+3] = common.Max(channels[0], common.Max(channels[1], channels[2]))
+		}
+	}
+}
+
+// Returns the lookup table of the coverage values for the gamma and the
+// contrast, nil if the table doesn't change them.
+func coverageCurve(gamma, contrast float64) *[256]uint8 {
+	if gamma <= 0 {
+		gamma = 1
+	}
+	if gamma == 1 && contrast == 0 {
+		return nil
+	}
+	curve := new([256]uint8)
+	for i := range curve {
+		v := math.Pow(float64(i)/255, 1/gamma) * (1 + contrast)
+		curve[i] = uint8(math.Round(common.Clamp(v, 0, 1) * 255))
+	}
+	return curve
+}
+
+// Maps every channel of the image with the curve. Curve is monotonic, so
+// the premultiplied channels stay below the alpha.
+func applyCurve(img *image.RGBA, curve *[256]uint8) {
+	for i, v := range img.Pix {
+		img.Pix[i] = curve[v]
+	}
+}
@@ -0,0 +1,189 @@
+package fontkit
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Run the tests with -update to write the golden images again after an
+// intended change of the rendering. Check the changed images before commit.
+var updateGolden = flag.Bool("update", false, "update the golden images")
+
+const goldenText = "Hamg&@=5"
+
+func testRenderingFace(t *testing.T, rendering Rendering) *Face {
+	font, err := CreateFontFromMem(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := font.CreateFace(FaceParams{Size: 11, DPI: 96, Rendering: rendering})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return face
+}
+
+// Renders the text to one image, every char is in its own cell.
+func renderText(t *testing.T, face *Face, text string) *image.RGBA {
+	size := face.ImageSize()
+	runes := []rune(text)
+	img := image.NewRGBA(image.Rect(0, 0, size.Width()*len(runes), size.Height()))
+	for i, char := range runes {
+		glyph := face.RenderChar(char, size)
+		if glyph == nil {
+			t.Fatalf("%c is not rendered", char)
+		}
+		// Only the first cell of the double width glyphs
+		cell := image.Rect(i*size.Width(), 0, (i+1)*size.Width(), size.Height())
+		draw.Draw(img, cell, glyph, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestRenderingGolden(t *testing.T) {
+	for name, rendering := range map[string]Rendering{
+		"hinting_full":     {Hinting: HintingFull},
+		"hinting_vertical": {Hinting: HintingVertical},
+		"hinting_none":     {Hinting: HintingNone},
+		"antialias_none":   {Antialias: AntialiasNone},
+		"antialias_rgb":    {Hinting: HintingVertical, Antialias: AntialiasSubpixelRGB},
+		"antialias_bgr":    {Hinting: HintingVertical, Antialias: AntialiasSubpixelBGR},
+		"gamma":            {Gamma: 1.8},
+		"contrast":         {Contrast: 0.4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			img := renderText(t, testRenderingFace(t, rendering), goldenText)
+			// Pixels are stored without conversion, the conversion of the
+			// premultiplied colors loses the precision
+			raw := &image.NRGBA{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect}
+			var encoded bytes.Buffer
+			if err := png.Encode(&encoded, raw); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", "golden", name+".png")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, encoded.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			golden, err := png.Decode(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if golden.Bounds() != img.Rect {
+				t.Fatalf("image size is %v, golden is %v", img.Rect, golden.Bounds())
+			}
+			goldenPix := golden.(*image.NRGBA).Pix
+			for i := 0; i < len(img.Pix); i += 4 {
+				if !bytes.Equal(img.Pix[i:i+4], goldenPix[i:i+4]) {
+					x, y := i/4%img.Rect.Dx(), i/4/img.Rect.Dx()
+					t.Fatalf("pixel (%d, %d) is %v, golden is %v", x, y, img.Pix[i:i+4], goldenPix[i:i+4])
+				}
+			}
+		})
+	}
+}
+
+func TestSubpixelOrderIsMirrored(t *testing.T) {
+	rgb := renderText(t, testRenderingFace(t, Rendering{Antialias: AntialiasSubpixelRGB}), goldenText)
+	bgr := renderText(t, testRenderingFace(t, Rendering{Antialias: AntialiasSubpixelBGR}), goldenText)
+	colored := false
+	for i := 0; i < len(rgb.Pix); i += 4 {
+		if rgb.Pix[i] != bgr.Pix[i+2] || rgb.Pix[i+1] != bgr.Pix[i+1] || rgb.Pix[i+2] != bgr.Pix[i] || rgb.Pix[i+3] != bgr.Pix[i+3] {
+			t.Fatalf("pixel %d of the bgr image %v is not the mirror of the rgb %v", i/4, bgr.Pix[i:i+4], rgb.Pix[i:i+4])
+		}
+		if rgb.Pix[i] != rgb.Pix[i+2] {
+			colored = true
+		}
+	}
+	if !colored {
+		t.Error("subpixel antialiasing has the same coverage in all channels")
+	}
+}
+
+func TestHintingFitsHorizontalEdges(t *testing.T) {
+	for _, hinting := range []Hinting{HintingVertical, HintingFull} {
+		face := testRenderingFace(t, Rendering{Hinting: hinting})
+		size := face.ImageSize()
+		// Middle columns of these chars only cross horizontal bars, they
+		// must be either empty or full
+		for _, char := range "H=" {
+			img := face.RenderChar(char, size)
+			x := size.Width() / 2
+			for y := 0; y < size.Height(); y++ {
+				if a := img.RGBAAt(x, y).A; a != 0 && a != 255 {
+					t.Errorf("%c with hinting %d has a partial pixel %d at (%d, %d)", char, hinting, a, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestHintingKeepsEdgeOrder(t *testing.T) {
+	// Horizontal lines at the close edges, the second one is moved to the
+	// next pixel and the third one must not stay behind it
+	var segments sfnt.Segments
+	for _, y := range []fixed.Int26_6{0, 20, 28} {
+		segments = append(segments,
+			sfnt.Segment{Op: sfnt.SegmentOpMoveTo, Args: [3]fixed.Point26_6{{X: 0, Y: y}}},
+			sfnt.Segment{Op: sfnt.SegmentOpLineTo, Args: [3]fixed.Point26_6{{X: 64, Y: y}}},
+		)
+	}
+	fitAxis(segments, pointY)
+	for i := 2; i < len(segments); i += 2 {
+		prev, y := segments[i-2].Args[0].Y, segments[i].Args[0].Y
+		if y < prev {
+			t.Errorf("edge %d is fitted to %d, before the previous edge %d", i/2, y, prev)
+		}
+		if y%64 != 0 {
+			t.Errorf("edge %d is fitted to %d, not to a pixel", i/2, y)
+		}
+	}
+}
+
+func TestSubpixelPositioning(t *testing.T) {
+	// Advance of Go Mono is not a whole pixel in this size
+	for _, hinting := range []Hinting{HintingNone, HintingVertical} {
+		if shift := testRenderingFace(t, Rendering{Hinting: hinting}).glyphShift; shift%64 == 0 {
+			t.Errorf("glyphs with hinting %d are positioned to the pixel %d", hinting, shift)
+		}
+	}
+	if shift := testRenderingFace(t, Rendering{Hinting: HintingFull}).glyphShift; shift%64 != 0 {
+		t.Errorf("glyphs with full hinting are positioned to the subpixel %d", shift)
+	}
+}
+
+func TestCoverageCurve(t *testing.T) {
+	if coverageCurve(1, 0) != nil || coverageCurve(0, 0) != nil {
+		t.Error("linear curve is not nil")
+	}
+	for _, curve := range []*[256]uint8{coverageCurve(1.8, 0), coverageCurve(1, 0.4), coverageCurve(0.7, 0.2)} {
+		if curve[0] != 0 || curve[255] != 255 {
+			t.Errorf("curve doesn't keep the ends: %d %d", curve[0], curve[255])
+		}
+		for i := 1; i < len(curve); i++ {
+			if curve[i] < curve[i-1] {
+				t.Fatalf("curve is not monotonic at %d", i)
+			}
+		}
+	}
+}
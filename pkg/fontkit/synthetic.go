@@ -0,0 +1,157 @@
+package fontkit
+
+import (
+	"image"
+	"math"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Glyph outlines are loaded, hinted and rasterized by us instead of the
+// opentype face. Synthetic styles are used when the font family doesn't have
+// the bold or italic font. Glyphs of the variable font instances are also
+// rendered here.
+
+const (
+	// Horizontal shear of the synthetic italic, tan(12 degrees)
+	SYNTHETIC_ITALIC_SHEAR = 0.2126
+	// Emboldening strength relative to the pixels per em
+	SYNTHETIC_BOLD_STRENGTH = 1.0 / 24
+)
+
+// Transforms the outline point which is relative to the glyph origin.
+// Y axis increases down in the sfnt segments. X is multiplied with the
+// scale after the transformation.
+func (face *Face) syntheticPoint(p fixed.Point26_6, origin common.Vector2[float32], dx, scale float32) (float32, float32) {
+	x := float32(p.X) / 64
+	y := float32(p.Y) / 64
+	if face.syntheticItalic {
+		// Shear around the middle of the ascent to keep the glyph centered
+		x += SYNTHETIC_ITALIC_SHEAR * (-y - float32(face.ascent)/2)
+	}
+	return (origin.X + x + dx) * scale, origin.Y + y
+}
+
+func (face *Face) rastSegments(r *vector.Rasterizer, segments sfnt.Segments, origin common.Vector2[float32], dx, scale float32) {
+	started := false
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if started {
+				r.ClosePath()
+			}
+			r.MoveTo(face.syntheticPoint(seg.Args[0], origin, dx, scale))
+			started = true
+		case sfnt.SegmentOpLineTo:
+			r.LineTo(face.syntheticPoint(seg.Args[0], origin, dx, scale))
+		case sfnt.SegmentOpQuadTo:
+			bx, by := face.syntheticPoint(seg.Args[0], origin, dx, scale)
+			cx, cy := face.syntheticPoint(seg.Args[1], origin, dx, scale)
+			r.QuadTo(bx, by, cx, cy)
+		case sfnt.SegmentOpCubeTo:
+			bx, by := face.syntheticPoint(seg.Args[0], origin, dx, scale)
+			cx, cy := face.syntheticPoint(seg.Args[1], origin, dx, scale)
+			dx2, dy2 := face.syntheticPoint(seg.Args[2], origin, dx, scale)
+			r.CubeTo(bx, by, cx, cy, dx2, dy2)
+		}
+	}
+	if started {
+		r.ClosePath()
+	}
+}
+
+// Loads the glyph outline of the face, with the variation if the face has
+// one.
+func (face *Face) loadSegments(index sfnt.GlyphIndex) (sfnt.Segments, bool) {
+	font := face.font
+	if face.coords != nil {
+		return font.variable.loadSegments(index, face.coords, face.ppem)
+	}
+	segments, err := font.handle.LoadGlyph(&font.buffer, index, face.ppem, nil)
+	return segments, err == nil
+}
+
+// Loads the glyph outline and fits it to the pixels with the hinting of the
+// face. Returned segments are not reused by the next load if they are hinted.
+func (face *Face) loadHintedSegments(index sfnt.GlyphIndex) (sfnt.Segments, bool) {
+	segments, ok := face.loadSegments(index)
+	if !ok {
+		return nil, false
+	}
+	return hintSegments(segments, face.hinting), true
+}
+
+// Rasterizes the outline at the origin with the synthetic styles. X
+// coordinates are multiplied with the scale, see rasterize.
+func (face *Face) rastOutline(r *vector.Rasterizer, segments sfnt.Segments, origin common.Vector2[float32], scale float32) {
+	// Emboldening dilates the outline horizontally by rasterizing it multiple
+	// times with small offsets, the rasterizer merges them.
+	offsets := []float32{0}
+	if face.syntheticBold {
+		strength := float32(face.ppem) / 64 * SYNTHETIC_BOLD_STRENGTH
+		strength = common.Max(strength, 0.5)
+		steps := int(math.Ceil(float64(strength / 0.25)))
+		offsets = make([]float32, steps+1)
+		for i := range offsets {
+			offsets[i] = strength*float32(i)/float32(steps) - strength/2
+		}
+	}
+	for _, dx := range offsets {
+		face.rastSegments(r, segments, origin, dx, scale)
+	}
+}
+
+// Renders the glyph with synthetic styles and variations. Returns nil if
+// the font doesn't have the glyph.
+func (face *Face) renderOutlineGlyph(char rune, imgSize common.Vector2[int]) *image.RGBA {
+	font := face.font
+	index, err := font.handle.GlyphIndex(&font.buffer, char)
+	if err != nil || index == 0 {
+		return nil
+	}
+	segments, ok := face.loadSegments(index)
+	if !ok {
+		return nil
+	}
+	// Double width glyphs, hinting may move the edges a bit out of the cell
+	width := imgSize.Width()
+	height := imgSize.Height()
+	if bounds := segments.Bounds(); (bounds.Max.X - bounds.Min.X).Ceil() > width {
+		width *= 2
+	}
+	segments = hintSegments(segments, face.hinting)
+	bounds := segments.Bounds()
+	origin := common.Vec2(float32(face.glyphShift)/64, float32(face.baseline))
+	if glyphHeight := (bounds.Max.Y - bounds.Min.Y).Ceil(); glyphHeight > height {
+		// Center the glyph if it is taller than the cell
+		origin.Y = float32(height-glyphHeight)/2 - float32(bounds.Min.Y)/64
+	}
+	return face.rasterize(common.Vec2(width, height), func(r *vector.Rasterizer, scale float32) {
+		face.rastOutline(r, segments, origin, scale)
+	})
+}
+
+// Renders the shaped glyphs of the consecutive cells to one image. Every
+// glyph is drawn at the origin of its own cell, glyphs may extend to the
+// other cells of the image. NoGlyph is skipped. Width of the image is the
+// cell width times the number of the glyphs.
+func (face *Face) RenderGlyphs(glyphs []sfnt.GlyphIndex, imgSize common.Vector2[int]) *image.RGBA {
+	width := imgSize.Width() * len(glyphs)
+	height := imgSize.Height()
+	return face.rasterize(common.Vec2(width, height), func(r *vector.Rasterizer, scale float32) {
+		for i, index := range glyphs {
+			if index == NoGlyph || index == 0 {
+				continue
+			}
+			segments, ok := face.loadHintedSegments(index)
+			if !ok {
+				continue
+			}
+			origin := common.Vec2(float32(i*imgSize.Width())+float32(face.glyphShift)/64, float32(face.baseline))
+			face.rastOutline(r, segments, origin, scale)
+		}
+	})
+}
@@ -0,0 +1,396 @@
+package fontkit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"golang.org/x/image/font/sfnt"
+)
+
+// Variable fonts have axes like weight (wght) and width (wdth), and a glyph
+// outline for every point in the design space. Only TrueType variable fonts
+// are supported (fvar, avar and gvar tables), CFF2 fonts are rendered with
+// their default instance. sfnt package doesn't support variations, glyphs of
+// the non default instances are loaded and rasterized by us, without hinting.
+// https://docs.microsoft.com/en-us/typography/opentype/spec/otvaroverview
+
+// Maximum number of axes can be set in a Variation
+const MAX_VARIATION_AXES = 8
+
+// Value of a variation axis in user coordinates, like wght=700
+type AxisValue struct {
+	Tag   string
+	Value float64
+}
+
+// Axis values of a variable font instance. This is an array because it is
+// used in FaceParams which must be comparable. Unused entries have empty
+// tags, zero value means the default instance.
+type Variation [MAX_VARIATION_AXES]AxisValue
+
+// Returns the value of the axis and true if the variation has it.
+func (v Variation) Get(tag string) (float64, bool) {
+	for _, axis := range v {
+		if axis.Tag == tag {
+			return axis.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Returns a copy of the variation with the axis value set. The value is not
+// set if all entries are used.
+func (v Variation) Set(tag string, value float64) Variation {
+	for i, axis := range v {
+		if axis.Tag == tag || axis.Tag == "" {
+			v[i] = AxisValue{Tag: tag, Value: value}
+			break
+		}
+	}
+	return v
+}
+
+func (v Variation) String() string {
+	parts := []string{}
+	for _, axis := range v {
+		if axis.Tag != "" {
+			parts = append(parts, axis.Tag+"="+strconv.FormatFloat(axis.Value, 'g', -1, 64))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parses axis values in form of 'wght=450,wdth=87.5'. Axis tags are four
+// characters and case sensitive.
+func ParseVariation(str string) (Variation, error) {
+	var v Variation
+	count := 0
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pair := strings.Split(part, "=")
+		if len(pair) != 2 {
+			return v, fmt.Errorf("Invalid axis value %s", part)
+		}
+		tag := strings.TrimSpace(pair[0])
+		if len(tag) != 4 {
+			return v, fmt.Errorf("Invalid axis tag %s", tag)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(pair[1]), 64)
+		if err != nil {
+			return v, fmt.Errorf("Invalid axis value %s", part)
+		}
+		if _, ok := v.Get(tag); !ok {
+			count++
+		}
+		if count > MAX_VARIATION_AXES {
+			return v, fmt.Errorf("Too many axes, maximum is %d", MAX_VARIATION_AXES)
+		}
+		v = v.Set(tag, value)
+	}
+	return v, nil
+}
+
+// User settings of the variable fonts. Weights are only used for the fonts
+// which have a weight axis. Zero values means defaults.
+type VariationSettings struct {
+	Weight       int    // Weight of the regular text, zero means the kit weight or the font default
+	BoldWeight   int    // Weight of the bold text, zero means bold weight of the regular weight
+	Instance     string // Named instance used for the regular text instead of the weight
+	BoldInstance string // Named instance used for the bold text instead of the bold weight
+	Axes         Variation
+}
+
+type Axis struct {
+	Tag               string
+	Min, Default, Max float64
+}
+
+type NamedInstance struct {
+	Name   string // Subfamily name, like "SemiBold"
+	Coords []float64
+}
+
+type axisSegment struct {
+	from, to float64
+}
+
+// Tables of a variable font
+type variableFont struct {
+	axes      []Axis
+	instances []NamedInstance
+	avar      [][]axisSegment // Segment maps of the axes, may be nil
+	glyphs    *glyfTable
+	gvar      *gvarTable
+}
+
+// Reads the table directory of the font at the offset.
+func parseTableDirectory(data []byte, offset uint32) map[string][]byte {
+	if uint64(offset)+12 > uint64(len(data)) {
+		return nil
+	}
+	numTables := uint32(binary.BigEndian.Uint16(data[offset+4:]))
+	if uint64(offset)+12+16*uint64(numTables) > uint64(len(data)) {
+		return nil
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := uint32(0); i < numTables; i++ {
+		record := data[offset+12+16*i:]
+		tableOffset := binary.BigEndian.Uint32(record[8:])
+		length := binary.BigEndian.Uint32(record[12:])
+		if uint64(tableOffset)+uint64(length) > uint64(len(data)) {
+			continue
+		}
+		tables[string(record[:4])] = data[tableOffset : tableOffset+length]
+	}
+	return tables
+}
+
+// Returns the tables of the font at the index of the collection.
+func fontTables(data []byte, index int) map[string][]byte {
+	if len(data) < 12 {
+		return nil
+	}
+	if string(data[:4]) != "ttcf" {
+		return parseTableDirectory(data, 0)
+	}
+	numFonts := int(binary.BigEndian.Uint32(data[8:]))
+	if index >= numFonts || len(data) < 12+4*numFonts {
+		return nil
+	}
+	return parseTableDirectory(data, binary.BigEndian.Uint32(data[12+4*index:]))
+}
+
+func fixedToFloat(v uint32) float64 {
+	return float64(int32(v)) / 65536
+}
+
+func f2dot14ToFloat(v uint16) float64 {
+	return float64(int16(v)) / 16384
+}
+
+// Returns nil if the font is not a supported variable font.
+func parseVariableFont(handle *sfnt.Font, data []byte, index int) *variableFont {
+	tables := fontTables(data, index)
+	fvar, gvar := tables["fvar"], tables["gvar"]
+	if fvar == nil || gvar == nil || tables["glyf"] == nil {
+		return nil
+	}
+	vf := new(variableFont)
+	if !vf.parseFvar(handle, fvar) {
+		return nil
+	}
+	vf.parseAvar(tables["avar"])
+	vf.glyphs = parseGlyf(tables["glyf"], tables["loca"], tables["head"])
+	vf.gvar = parseGvar(gvar, len(vf.axes))
+	if vf.glyphs == nil || vf.gvar == nil {
+		return nil
+	}
+	return vf
+}
+
+func (vf *variableFont) parseFvar(handle *sfnt.Font, fvar []byte) bool {
+	if len(fvar) < 16 {
+		return false
+	}
+	axesOffset := int(binary.BigEndian.Uint16(fvar[4:]))
+	axisCount := int(binary.BigEndian.Uint16(fvar[8:]))
+	axisSize := int(binary.BigEndian.Uint16(fvar[10:]))
+	instanceCount := int(binary.BigEndian.Uint16(fvar[12:]))
+	instanceSize := int(binary.BigEndian.Uint16(fvar[14:]))
+	if axisCount == 0 || axisSize < 20 || instanceSize < 4+4*axisCount {
+		return false
+	}
+	if axesOffset+axisCount*axisSize+instanceCount*instanceSize > len(fvar) {
+		return false
+	}
+	vf.axes = make([]Axis, axisCount)
+	for i := range vf.axes {
+		record := fvar[axesOffset+i*axisSize:]
+		vf.axes[i] = Axis{
+			Tag:     string(record[:4]),
+			Min:     fixedToFloat(binary.BigEndian.Uint32(record[4:])),
+			Default: fixedToFloat(binary.BigEndian.Uint32(record[8:])),
+			Max:     fixedToFloat(binary.BigEndian.Uint32(record[12:])),
+		}
+	}
+	var buffer sfnt.Buffer
+	instancesOffset := axesOffset + axisCount*axisSize
+	for i := 0; i < instanceCount; i++ {
+		record := fvar[instancesOffset+i*instanceSize:]
+		nameID := sfnt.NameID(binary.BigEndian.Uint16(record))
+		name, err := handle.Name(&buffer, nameID)
+		if err != nil {
+			continue
+		}
+		coords := make([]float64, axisCount)
+		for j := range coords {
+			coords[j] = fixedToFloat(binary.BigEndian.Uint32(record[4+4*j:]))
+		}
+		vf.instances = append(vf.instances, NamedInstance{Name: name, Coords: coords})
+	}
+	return true
+}
+
+func (vf *variableFont) parseAvar(avar []byte) {
+	if len(avar) < 8 || int(binary.BigEndian.Uint16(avar[6:])) != len(vf.axes) {
+		return
+	}
+	vf.avar = make([][]axisSegment, len(vf.axes))
+	offset := 8
+	for i := range vf.avar {
+		if offset+2 > len(avar) {
+			vf.avar = nil
+			return
+		}
+		count := int(binary.BigEndian.Uint16(avar[offset:]))
+		offset += 2
+		if offset+4*count > len(avar) {
+			vf.avar = nil
+			return
+		}
+		for j := 0; j < count; j++ {
+			vf.avar[i] = append(vf.avar[i], axisSegment{
+				from: f2dot14ToFloat(binary.BigEndian.Uint16(avar[offset:])),
+				to:   f2dot14ToFloat(binary.BigEndian.Uint16(avar[offset+2:])),
+			})
+			offset += 4
+		}
+	}
+}
+
+// Converts user coordinates to normalized coordinates in range [-1, 1].
+// Returns nil if all coordinates are default.
+func (vf *variableFont) normalize(v Variation) []float64 {
+	var coords []float64
+	for i, axis := range vf.axes {
+		value, ok := v.Get(axis.Tag)
+		if !ok {
+			continue
+		}
+		value = common.Clamp(value, axis.Min, axis.Max)
+		n := 0.0
+		if value < axis.Default && axis.Default > axis.Min {
+			n = (value - axis.Default) / (axis.Default - axis.Min)
+		} else if value > axis.Default && axis.Max > axis.Default {
+			n = (value - axis.Default) / (axis.Max - axis.Default)
+		}
+		if vf.avar != nil {
+			n = mapAxisSegments(vf.avar[i], n)
+		}
+		// Coordinates are stored as F2Dot14 in the font
+		n = math.Round(n*16384) / 16384
+		if n != 0 {
+			if coords == nil {
+				coords = make([]float64, len(vf.axes))
+			}
+			coords[i] = n
+		}
+	}
+	return coords
+}
+
+func mapAxisSegments(segments []axisSegment, n float64) float64 {
+	for i := 1; i < len(segments); i++ {
+		prev, next := segments[i-1], segments[i]
+		if n <= next.from {
+			if next.from == prev.from {
+				return next.to
+			}
+			return prev.to + (next.to-prev.to)*(n-prev.from)/(next.from-prev.from)
+		}
+	}
+	return n
+}
+
+func (vf *variableFont) axis(tag string) (Axis, bool) {
+	for _, axis := range vf.axes {
+		if axis.Tag == tag {
+			return axis, true
+		}
+	}
+	return Axis{}, false
+}
+
+func (vf *variableFont) instance(name string) (NamedInstance, bool) {
+	key := strings.ReplaceAll(strings.ToLower(name), " ", "")
+	for _, instance := range vf.instances {
+		if strings.ReplaceAll(strings.ToLower(instance.Name), " ", "") == key {
+			return instance, true
+		}
+	}
+	return NamedInstance{}, false
+}
+
+// Reports whether the font is a supported variable font.
+func (font *Font) IsVariable() bool {
+	return font.variable != nil
+}
+
+// Returns the variation axes of the font, nil if the font is not variable.
+func (font *Font) Axes() []Axis {
+	if font.variable == nil {
+		return nil
+	}
+	return font.variable.axes
+}
+
+// Returns the named instances of the font, nil if the font is not variable.
+func (font *Font) NamedInstances() []NamedInstance {
+	if font.variable == nil {
+		return nil
+	}
+	return font.variable.instances
+}
+
+// Returns the variation used for the regular or bold text. Second value
+// reports whether the variation is bold, which means synthetic bold is not
+// needed. Returns the default instance if the font is not variable.
+func (font *Font) StyleVariation(settings VariationSettings, bold bool) (Variation, bool) {
+	var v Variation
+	vf := font.variable
+	if vf == nil {
+		return v, false
+	}
+	instanceName := settings.Instance
+	weight := settings.Weight
+	if weight == 0 {
+		weight = font.weight
+	}
+	if bold {
+		instanceName = settings.BoldInstance
+		regular := weight
+		if regular == 0 {
+			regular = 400
+		}
+		weight = settings.BoldWeight
+		if weight == 0 {
+			// Same with the bold weight selection of the fontfinder
+			weight = common.Max(700, regular+200)
+		}
+	}
+	if instance, ok := vf.instance(instanceName); instanceName != "" && ok {
+		for i, axis := range vf.axes {
+			v = v.Set(axis.Tag, instance.Coords[i])
+		}
+	} else if wght, ok := vf.axis("wght"); ok && weight != 0 {
+		v = v.Set("wght", common.Clamp(float64(weight), wght.Min, wght.Max))
+	}
+	for _, axis := range settings.Axes {
+		if _, ok := vf.axis(axis.Tag); ok {
+			v = v.Set(axis.Tag, axis.Value)
+		}
+	}
+	value, ok := v.Get("wght")
+	if !ok {
+		wght, _ := vf.axis("wght")
+		value = wght.Default
+	}
+	return v, value >= 600
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -20,6 +21,11 @@ var cache struct {
 	buildtype BuildType // Build type of the program using this logger
 	file      *os.File  // File to write logs to
 	color     bool      // Whether to use color in the output
+	output    io.Writer // Logs are printed to here, stdout by default
+}
+
+func init() {
+	cache.output = os.Stdout
 }
 
 func Init(name string, version Version, buildtype BuildType, color bool) {
@@ -32,6 +38,13 @@ func Init(name string, version Version, buildtype BuildType, color bool) {
 	cache.color = color
 }
 
+// Changes where the logs and the terminal color reset are printed.
+func SetOutput(output io.Writer) {
+	guard.Lock()
+	defer guard.Unlock()
+	cache.output = output
+}
+
 func timeString(t time.Time) string {
 	return fmt.Sprintf("%s %d", t.UTC().Format("2006-01-02 15:04:05"), t.UnixMilli())
 }
@@ -79,7 +92,7 @@ func cleanup() {
 	}
 	// Reset terminal color
 	if cache.color {
-		fmt.Print(AnsiReset)
+		fmt.Fprint(cache.output, AnsiReset)
 	}
 }
 
@@ -106,6 +119,7 @@ func createCrashReport(msg string, isPanic bool) {
 func Log(logLevel LogLevel, message ...any) {
 	guard.Lock()
 	buildtype := cache.buildtype
+	output := cache.output
 	guard.Unlock()
 
 	if buildtype == ReleaseBuild && logLevel < TRACE {
@@ -116,11 +130,11 @@ func Log(logLevel LogLevel, message ...any) {
 
 	logString := fmt.Sprintf("%s %s", logLevel, messageStr)
 
-	// Print to stdout
+	// Print to the output
 	if cache.color {
-		fmt.Printf("%s%s\n", string(logLevel.Color()), logString)
+		fmt.Fprintf(output, "%s%s\n", string(logLevel.Color()), logString)
 	} else {
-		fmt.Printf("%s\n", logString)
+		fmt.Fprintf(output, "%s\n", logString)
 	}
 
 	// Print to verbose file if opened
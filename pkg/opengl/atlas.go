@@ -7,49 +7,105 @@ import (
 	"github.com/hismailbulut/Neoray/pkg/common"
 	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
+	"github.com/mattn/go-runewidth"
 )
 
 const (
 	UNSUPPORTED_GLYPH_ID = 0xffffffffffffffff // "Unsupported"
-	UNDERCURL_GLYPH_ID   = 0xfffffffffffffffe // "Undercurl"
+	// Minimum size of the atlas pages, pages are bigger for the big fonts
+	ATLAS_PAGE_SIZE = 512
+	// Least recently used page is evicted when this number of pages are full
+	MAX_ATLAS_PAGES = 8
 )
 
 type Atlas struct {
 	kit             *fontkit.FontKit
+	fallbacks       []*fontkit.FontKit  // Tried in order after the kit
+	wideKit         *fontkit.FontKit    // Preferred for double width characters, may be nil
+	ranges          []fontkit.FontRange // User specified fonts for unicode ranges
 	fontSize, dpi   float64
 	useBoxDrawing   bool
 	useBlockDrawing bool
+	usePowerline    bool // Draw Powerline separators instead of the font glyphs
+	adjust          fontkit.CellAdjust
+	undercurl       fontkit.UndercurlAdjust
+	rendering       fontkit.Rendering
+	syntheticBold   bool // Embolden glyphs if the font has no bold style
+	syntheticItalic bool // Oblique glyphs if the font has no italic style
+	variations      fontkit.VariationSettings
+	wideEmoji       bool // Color glyphs of the double width chars use two cells
 	texture         Texture
-	cache           map[uint64]common.Rectangle[int]
-	pen             common.Vector2[int]
+	packer          *shelfPacker
+	generation      uint64         // Increased every frame, pages are evicted by the last used generation
+	evictHandler    func(page int) // Called after a page is evicted, may be nil
+	cache           map[uint64]glyphPos
+	ligatures       map[ligatureKey]common.Rectangle[int]
+	shapes          map[shapeKey][]LigatureCluster
+}
+
+// Position of a glyph in the texture
+type glyphPos struct {
+	pos     common.Rectangle[int]
+	colored bool // Color glyphs are not tinted with the foreground color
 }
 
 func (atlas *Atlas) String() string {
-	return fmt.Sprintf("Atlas(ID: %d, Font Size: %f, Pen: %v)",
+	return fmt.Sprintf("Atlas(ID: %d, Font Size: %f, Pages: %d, Generation: %d)",
 		atlas.texture.id,
 		atlas.fontSize,
-		atlas.pen,
+		len(atlas.packer.pages),
+		atlas.generation,
 	)
 }
 
-func (context *Context) NewAtlas(kit *fontkit.FontKit, size, dpi float64, useBoxDrawing, useBlockDrawing bool) *Atlas {
+func (context *Context) NewAtlas(kit *fontkit.FontKit, size, dpi float64, useBoxDrawing, useBlockDrawing bool, adjust fontkit.CellAdjust) *Atlas {
 	atlas := new(Atlas)
 	atlas.kit = kit
 	atlas.fontSize = size
 	atlas.dpi = dpi
 	atlas.useBoxDrawing = useBoxDrawing
 	atlas.useBlockDrawing = useBlockDrawing
-	// 512 * 512 * RGBA8 = 1mib
-	const width = 512
-	const height = 512
-	// In most cases these size of texture is highly enough
-	// But we also grow it if needed
-	atlas.texture = context.CreateTexture(width, height)
-	atlas.cache = make(map[uint64]common.Rectangle[int])
+	atlas.adjust = adjust
+	atlas.usePowerline = true
+	atlas.wideEmoji = true
+	// 512 * 512 * RGBA8 = 1mib per page, pages are added when needed
+	pageSize := atlas.pageSize()
+	atlas.texture = context.CreateTextureArray(pageSize.Width(), pageSize.Height(), 1)
+	atlas.packer = newShelfPacker(pageSize, MAX_ATLAS_PAGES)
+	atlas.resetCache()
 	atlas.issueHack()
 	return atlas
 }
 
+// Returns the size of the pages for the current cell size. A page holds at
+// least 8 rows of cells and the image of the longest ligature.
+func (atlas *Atlas) pageSize() common.Vector2[int] {
+	cellSize := atlas.ImageSize()
+	size := ATLAS_PAGE_SIZE
+	for size < cellSize.Height()*8 || size < cellSize.Width()*MAX_LIGATURE_CELLS {
+		size *= 2
+	}
+	return common.Vec2(size, size)
+}
+
+// Handler is called when the least recently used page is evicted to make
+// space for a new image. Positions in the page are not valid anymore, cells
+// using them must get their positions again. Reset doesn't call the handler.
+func (atlas *Atlas) SetEvictHandler(handler func(page int)) {
+	atlas.evictHandler = handler
+}
+
+// Returns the page of the position returned by the atlas
+func (atlas *Atlas) Page(pos common.Rectangle[int]) int {
+	return pos.Y / atlas.packer.size.Height()
+}
+
+// Starts a new generation. Pages of the images used in the previous
+// generations are evicted first.
+func (atlas *Atlas) NextGeneration() {
+	atlas.generation++
+}
+
 func (atlas *Atlas) issueHack() {
 	// HACK
 	// I dont know why but if the renderer first draws a character
@@ -59,7 +115,7 @@ func (atlas *Atlas) issueHack() {
 	// But this is not a real fix. TODO: We should address this
 	// We are doing this every time atlas recreated
 	// Try change 'a' to one of characters in range [0x2588, 0x258F] and see the result
-	atlas.GetCharPos('a', false, false, false, false, atlas.ImageSize())
+	atlas.GetCharPos('a', false, false, atlas.ImageSize())
 }
 
 func (atlas *Atlas) FontKit() *fontkit.FontKit {
@@ -74,6 +130,33 @@ func (atlas *Atlas) SetFontKit(kit *fontkit.FontKit) {
 	atlas.Reset()
 }
 
+func (atlas *Atlas) FallbackFontKits() []*fontkit.FontKit {
+	return atlas.fallbacks
+}
+
+func (atlas *Atlas) SetFallbackFontKits(kits []*fontkit.FontKit) {
+	atlas.fallbacks = kits
+	atlas.Reset()
+}
+
+func (atlas *Atlas) WideFontKit() *fontkit.FontKit {
+	return atlas.wideKit
+}
+
+func (atlas *Atlas) SetWideFontKit(kit *fontkit.FontKit) {
+	atlas.wideKit = kit
+	atlas.Reset()
+}
+
+func (atlas *Atlas) FontRanges() []fontkit.FontRange {
+	return atlas.ranges
+}
+
+func (atlas *Atlas) SetFontRanges(ranges []fontkit.FontRange) {
+	atlas.ranges = ranges
+	atlas.Reset()
+}
+
 func (atlas *Atlas) FontSize() float64 {
 	return atlas.fontSize
 }
@@ -90,27 +173,119 @@ func (atlas *Atlas) SetBoxDrawing(useBoxDrawing, useBlockDrawing bool) {
 	atlas.Reset()
 }
 
+func (atlas *Atlas) SetPowerlineDrawing(usePowerline bool) {
+	if usePowerline == atlas.usePowerline {
+		return
+	}
+	atlas.usePowerline = usePowerline
+	atlas.Reset()
+}
+
+func (atlas *Atlas) SetSyntheticStyles(bold, italic bool) {
+	if bold == atlas.syntheticBold && italic == atlas.syntheticItalic {
+		return
+	}
+	atlas.syntheticBold = bold
+	atlas.syntheticItalic = italic
+	atlas.Reset()
+}
+
+func (atlas *Atlas) VariationSettings() fontkit.VariationSettings {
+	return atlas.variations
+}
+
+func (atlas *Atlas) SetVariationSettings(settings fontkit.VariationSettings) {
+	if settings == atlas.variations {
+		return
+	}
+	atlas.variations = settings
+	atlas.Reset()
+}
+
+func (atlas *Atlas) SetWideEmoji(wide bool) {
+	if wide == atlas.wideEmoji {
+		return
+	}
+	atlas.wideEmoji = wide
+	atlas.Reset()
+}
+
+func (atlas *Atlas) CellAdjust() fontkit.CellAdjust {
+	return atlas.adjust
+}
+
+func (atlas *Atlas) SetCellAdjust(adjust fontkit.CellAdjust) {
+	atlas.adjust = adjust
+	atlas.Reset()
+}
+
+// Undercurl is drawn by the shaders, changing it doesn't reset the atlas.
+func (atlas *Atlas) SetUndercurlAdjust(adjust fontkit.UndercurlAdjust) {
+	atlas.undercurl = adjust
+}
+
+func (atlas *Atlas) SetRendering(rendering fontkit.Rendering) {
+	if rendering == atlas.rendering {
+		return
+	}
+	atlas.rendering = rendering
+	atlas.Reset()
+}
+
 func (atlas *Atlas) Reset() {
+	size := atlas.pageSize()
+	atlas.texture.Bind()
+	// Pages are added again when needed
+	atlas.texture.SetLayers(1)
+	if size != atlas.texture.Size() {
+		atlas.texture.Resize(size.Width(), size.Height())
+	}
 	atlas.texture.Clear()
-	atlas.cache = make(map[uint64]common.Rectangle[int])
-	atlas.pen = common.Vector2[int]{}
+	atlas.packer.reset(size)
+	atlas.resetCache()
 	atlas.issueHack()
 }
 
+func (atlas *Atlas) resetCache() {
+	atlas.cache = make(map[uint64]glyphPos)
+	atlas.ligatures = make(map[ligatureKey]common.Rectangle[int])
+	atlas.shapes = make(map[shapeKey][]LigatureCluster)
+}
+
+func (atlas *Atlas) faceParams() fontkit.FaceParams {
+	return fontkit.FaceParams{
+		Size:                atlas.fontSize,
+		DPI:                 atlas.dpi,
+		UseBoxDrawing:       atlas.useBoxDrawing,
+		UseBlockDrawing:     atlas.useBlockDrawing,
+		UsePowerlineDrawing: atlas.usePowerline,
+		Adjust:              atlas.adjust,
+		Rendering:           atlas.rendering,
+	}
+}
+
 func (atlas *Atlas) ImageSize() common.Vector2[int] {
-	face, err := atlas.FontKit().DefaultFont().CreateFace(fontkit.FaceParams{
-		Size:            atlas.fontSize,
-		DPI:             atlas.dpi,
-		UseBoxDrawing:   atlas.useBoxDrawing,
-		UseBlockDrawing: atlas.useBlockDrawing,
-	})
+	face, err := atlas.FontKit().DefaultFont().CreateFace(atlas.faceParams())
 	if err != nil {
 		panic(err)
 	}
 	return face.ImageSize()
 }
 
-func getCharID(char rune, italic, bold, underline, strikethrough bool) uint64 {
+// Every font of the atlas uses the decoration metrics of the default font, so
+// the decorations are continuous.
+func (atlas *Atlas) DecorationMetrics() fontkit.DecorationMetrics {
+	face, err := atlas.FontKit().DefaultFont().CreateFace(atlas.faceParams())
+	if err != nil {
+		panic(err)
+	}
+	return face.DecorationMetrics(atlas.undercurl)
+}
+
+// Axis values of the variable fonts are determined by the variation settings
+// of the atlas and the bold flag. Changing the settings resets the atlas, so
+// every id always has the same axis values.
+func getCharID(char rune, italic, bold bool) uint64 {
 	id := uint64(char)
 	if italic {
 		id = id | uint64(1)<<32
@@ -118,134 +293,213 @@ func getCharID(char rune, italic, bold, underline, strikethrough bool) uint64 {
 	if bold {
 		id = id | uint64(1)<<40
 	}
-	if underline {
-		id = id | uint64(1)<<48
-	}
-	if strikethrough {
-		id = id | uint64(1)<<56
-	}
 	return id
 }
 
-// Draws img to texture and returns position
+// Draws img to texture and returns position. Pages are stacked vertically
+// in the positions, see Page.
 func (atlas *Atlas) drawImage(img *image.RGBA) common.Rectangle[int] {
-	textureSize := atlas.texture.Size()
-	// Check X
-	if atlas.pen.X+img.Rect.Dx() > textureSize.Width() {
-		atlas.pen.X = 0
-		atlas.pen.Y += img.Rect.Dy()
-	}
-	// Check Y
-	if atlas.pen.Y+img.Rect.Dy() > textureSize.Height() {
-		// We must grow the texture
-		atlas.texture.Resize(textureSize.Width()*2, textureSize.Height()*2)
-		logger.Log(logger.DEBUG, "Atlas", atlas.texture.id, "texture resized to %v", atlas.texture.Size())
-		// Resizing texture also clears it, so we should also clear the cache
-		atlas.cache = make(map[uint64]common.Rectangle[int])
-		atlas.pen = common.Vector2[int]{}
-		atlas.issueHack()
-	}
-	// draw image to current pen
-	dest := common.Rect(atlas.pen.X, atlas.pen.Y, img.Rect.Dx(), img.Rect.Dy())
+	size := common.Vec2(img.Rect.Dx(), img.Rect.Dy())
+	page, pos, evicted, ok := atlas.packer.packEvicting(size)
+	if evicted >= 0 {
+		atlas.evict(evicted)
+	}
+	if !ok {
+		logger.Log(logger.ERROR, "Image size", size, "doesn't fit to the atlas page", atlas.packer.size)
+		return common.Rectangle[int]{}
+	}
+	atlas.packer.touch(page, atlas.generation)
 	// We should bind texture before drawing to it
 	atlas.texture.Bind()
-	atlas.texture.Draw(img, dest)
-	// increment pen
-	atlas.pen.X += img.Rect.Dx()
-	return dest
+	if page >= atlas.texture.Layers() {
+		atlas.texture.SetLayers(page + 1)
+	}
+	atlas.texture.Draw(img, page, pos)
+	pos.Y += page * atlas.packer.size.Height()
+	return pos
+}
+
+// Clears the texture of the page cleared by the packer and removes the
+// images in it from the caches.
+func (atlas *Atlas) evict(page int) {
+	logger.Log(logger.DEBUG, "Atlas", atlas.texture.id, "evicted page", page)
+	atlas.texture.Bind()
+	atlas.texture.ClearLayer(page)
+	for id, glyph := range atlas.cache {
+		if atlas.Page(glyph.pos) == page {
+			delete(atlas.cache, id)
+		}
+	}
+	for key, pos := range atlas.ligatures {
+		if atlas.Page(pos) == page {
+			delete(atlas.ligatures, key)
+		}
+	}
+	if atlas.evictHandler != nil {
+		atlas.evictHandler(page)
+	}
+}
+
+// Marks the page of the position as used in this generation.
+func (atlas *Atlas) touch(pos common.Rectangle[int]) {
+	atlas.packer.touch(atlas.Page(pos), atlas.generation)
 }
 
-func (atlas *Atlas) drawChar(face *fontkit.Face, id uint64, char rune, underline, strikethrough bool, imgSize common.Vector2[int]) common.Rectangle[int] {
-	img := face.RenderChar(char, underline, strikethrough, imgSize)
+// Marks the page as used in this generation. Renderers touch the pages of
+// the rendered cells every frame, so the visible images are evicted last.
+// Integer part of the normalized vertical position is the page.
+func (atlas *Atlas) TouchPage(page int) {
+	if page >= 0 && page < len(atlas.packer.pages) {
+		atlas.packer.touch(page, atlas.generation)
+	}
+}
+
+func (atlas *Atlas) drawChar(face *fontkit.Face, id uint64, char rune, imgSize common.Vector2[int]) common.Rectangle[int] {
+	img := face.RenderChar(char, imgSize)
 	pos := atlas.drawImage(img)
-	atlas.cache[id] = pos
+	atlas.cache[id] = glyphPos{pos: pos}
 	return pos
 }
 
-func (atlas *Atlas) suitableFont(char rune, bold, italic bool) (*fontkit.Font, bool) {
-	if atlas.FontKit().SuitableFont(bold, italic).ContainsGlyph(char) {
-		return atlas.FontKit().SuitableFont(bold, italic), true
+// Returns the font in the kit which contains the glyph, nil if neither of them
+func kitFont(kit *fontkit.FontKit, char rune, bold, italic bool) *fontkit.Font {
+	if kit.SuitableFont(bold, italic).ContainsGlyph(char) {
+		return kit.SuitableFont(bold, italic)
+	}
+	if kit.DefaultFont().ContainsGlyph(char) {
+		return kit.DefaultFont()
+	}
+	return nil
+}
+
+// Returns the font contains the glyph and it's kit. Kit is nil if the font
+// is a system fallback font.
+func (atlas *Atlas) suitableFont(char rune, bold, italic bool) (*fontkit.Font, *fontkit.FontKit, bool) {
+	if runes := fontkit.ClusterRunes(char); runes != nil {
+		// Font of the base character draws the whole cluster
+		return atlas.suitableFont(runes[0], bold, italic)
+	}
+	// User specified ranges have the highest priority, last specified wins
+	for i := len(atlas.ranges) - 1; i >= 0; i-- {
+		if atlas.ranges[i].Contains(char) {
+			if font := kitFont(atlas.ranges[i].Kit, char, bold, italic); font != nil {
+				return font, atlas.ranges[i].Kit, true
+			}
+		}
 	}
-	if atlas.FontKit().DefaultFont().ContainsGlyph(char) {
-		return atlas.FontKit().DefaultFont(), true
+	if atlas.wideKit != nil && runewidth.RuneWidth(char) == 2 {
+		if font := kitFont(atlas.wideKit, char, bold, italic); font != nil {
+			return font, atlas.wideKit, true
+		}
 	}
-	if fontkit.Default().SuitableFont(bold, italic).ContainsGlyph(char) {
-		return fontkit.Default().SuitableFont(bold, italic), true
+	if font := kitFont(atlas.FontKit(), char, bold, italic); font != nil {
+		return font, atlas.FontKit(), true
 	}
-	if fontkit.Default().DefaultFont().ContainsGlyph(char) {
-		return fontkit.Default().DefaultFont(), true
+	for _, kit := range atlas.fallbacks {
+		if font := kitFont(kit, char, bold, italic); font != nil {
+			return font, kit, true
+		}
+	}
+	if font := kitFont(fontkit.Default(), char, bold, italic); font != nil {
+		return font, fontkit.Default(), true
+	}
+	// Search the system fonts
+	if font := fontkit.FallbackFont(char); font != nil {
+		return font, nil, true
 	}
 	// Neither of the fonts supports this glyph
-	return atlas.FontKit().SuitableFont(bold, italic), false
+	return atlas.FontKit().SuitableFont(bold, italic), atlas.FontKit(), false
 }
 
-// For the first time draws and caches undercurl image, returns image pos and true representing first time
-// After that uses cached image and returns false
-func (atlas *Atlas) Undercurl(imgSize common.Vector2[int]) (common.Rectangle[int], bool) {
-	pos, ok := atlas.cache[UNDERCURL_GLYPH_ID]
+func (atlas *Atlas) unsupported(face *fontkit.Face, char rune, imgSize common.Vector2[int]) common.Rectangle[int] {
+	glyph, ok := atlas.cache[UNSUPPORTED_GLYPH_ID]
 	if ok {
-		return pos, false
+		atlas.touch(glyph.pos)
+		return glyph.pos
 	}
 	// Draw and cache
-	face, err := atlas.FontKit().DefaultFont().CreateFace(fontkit.FaceParams{
-		Size:            atlas.fontSize,
-		DPI:             atlas.dpi,
-		UseBoxDrawing:   atlas.useBoxDrawing,
-		UseBlockDrawing: atlas.useBlockDrawing,
-	})
+	face, err := atlas.FontKit().DefaultFont().CreateFace(atlas.faceParams())
 	if err != nil {
 		panic(fmt.Errorf("face creation failed: %s", err))
 	}
-	img := face.RenderUndercurl(imgSize)
-	pos = atlas.drawImage(img)
-	atlas.cache[UNDERCURL_GLYPH_ID] = pos
-	return pos, true
+	// Draw and cache
+	return atlas.drawChar(face, UNSUPPORTED_GLYPH_ID, char, imgSize)
 }
 
-func (atlas *Atlas) unsupported(face *fontkit.Face, char rune, imgSize common.Vector2[int]) common.Rectangle[int] {
-	pos, ok := atlas.cache[UNSUPPORTED_GLYPH_ID]
+func (atlas *Atlas) GetCharPos(char rune, bold, italic bool, imgSize common.Vector2[int]) common.Rectangle[int] {
+	pos, _ := atlas.GetChar(char, bold, italic, imgSize)
+	return pos
+}
+
+// Returns the position of the char in the texture and whether it is a color
+// glyph. Color glyphs must be drawn with their own colors.
+func (atlas *Atlas) GetChar(char rune, bold, italic bool, imgSize common.Vector2[int]) (common.Rectangle[int], bool) {
+	id := getCharID(char, italic, bold)
+	glyph, ok := atlas.cache[id]
 	if ok {
-		return pos
+		atlas.touch(glyph.pos)
+		return glyph.pos, glyph.colored
 	}
-	// Draw and cache
-	face, err := atlas.FontKit().DefaultFont().CreateFace(fontkit.FaceParams{
-		Size:            atlas.fontSize,
-		DPI:             atlas.dpi,
-		UseBoxDrawing:   atlas.useBoxDrawing,
-		UseBlockDrawing: atlas.useBlockDrawing,
-	})
-	if err != nil {
-		panic(fmt.Errorf("face creation failed: %s", err))
+	font, kit, contains := atlas.suitableFont(char, bold, italic)
+	face := atlas.styledFace(font, kit, bold, italic)
+	if atlas.drawsItself(char) {
+		return atlas.drawChar(face, id, char, imgSize), false
+	}
+	if !contains {
+		return atlas.unsupported(face, char, imgSize), false
+	}
+	if img := face.RenderColorGlyph(char, atlas.colorImageSize(char, imgSize)); img != nil {
+		pos := atlas.drawImage(img)
+		atlas.cache[id] = glyphPos{pos: pos, colored: true}
+		return pos, true
 	}
 	// Draw and cache
-	pos = atlas.drawChar(face, UNSUPPORTED_GLYPH_ID, char, false, false, imgSize)
-	return pos
+	return atlas.drawChar(face, id, char, imgSize), false
 }
 
-func (atlas *Atlas) GetCharPos(char rune, bold, italic, underline, strikethrough bool, imgSize common.Vector2[int]) common.Rectangle[int] {
-	id := getCharID(char, italic, bold, underline, strikethrough)
-	pos, ok := atlas.cache[id]
-	if ok {
-		return pos
-	}
-	font, contains := atlas.suitableFont(char, bold, italic)
-	face, err := font.CreateFace(fontkit.FaceParams{
-		Size:            atlas.fontSize,
-		DPI:             atlas.dpi,
-		UseBoxDrawing:   atlas.useBoxDrawing,
-		UseBlockDrawing: atlas.useBlockDrawing,
-	})
+// Reports whether the char is drawn by the face itself instead of the font
+// glyph. These chars are drawn even if the font doesn't contain them.
+func (atlas *Atlas) drawsItself(char rune) bool {
+	return (atlas.useBoxDrawing && char >= 0x2500 && char <= 0x257F) ||
+		(atlas.useBlockDrawing && fontkit.IsBlockDrawingChar(char)) ||
+		(atlas.usePowerline && fontkit.IsPowerlineChar(char))
+}
+
+// Returns the image size of the color glyph. Neovim decides the width of
+// the emoji with its 'emoji' option.
+func (atlas *Atlas) colorImageSize(char rune, imgSize common.Vector2[int]) common.Vector2[int] {
+	width := runewidth.RuneWidth(char)
+	if runes := fontkit.ClusterRunes(char); runes != nil {
+		width = runewidth.StringWidth(string(runes))
+	}
+	if atlas.wideEmoji && width >= 2 {
+		return common.Vec2(imgSize.Width()*2, imgSize.Height())
+	}
+	return imgSize
+}
+
+// Returns the face of the font with the synthetic styles and variations
+// for the requested styles. Kit is nil for the system fallback fonts.
+func (atlas *Atlas) styledFace(font *fontkit.Font, kit *fontkit.FontKit, bold, italic bool) *fontkit.Face {
+	params := atlas.faceParams()
+	// Fallback fonts are always regular
+	missingBold, missingItalic := bold, italic
+	if kit != nil {
+		missingBold, missingItalic = kit.MissingStyles(font, bold, italic)
+	}
+	// Variable fonts use their weight axis instead of synthetic bold
+	variation, variedBold := font.StyleVariation(atlas.variations, bold)
+	params.Variation = variation
+	if variedBold {
+		missingBold = false
+	}
+	params.SyntheticBold = atlas.syntheticBold && missingBold
+	params.SyntheticItalic = atlas.syntheticItalic && missingItalic
+	face, err := font.CreateFace(params)
 	if err != nil {
 		panic(fmt.Errorf("face creation failed: %s", err))
 	}
-	if contains {
-		// Draw and cache
-		pos = atlas.drawChar(face, id, char, underline, strikethrough, imgSize)
-		return pos
-	} else {
-		// unsupported
-		return atlas.unsupported(face, char, imgSize)
-	}
+	return face
 }
 
 // Normalization required when updating texture position to the gpu
@@ -7,6 +7,7 @@ import (
 
 	"github.com/hismailbulut/Neoray/pkg/bench"
 	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
 	"github.com/hismailbulut/Neoray/pkg/logger"
 	"github.com/hismailbulut/Neoray/pkg/opengl/gl"
 )
@@ -26,20 +27,41 @@ type Vertex struct {
 	Bg common.Color // layout 4
 	// special color
 	Sp common.Color // layout 5
+	// whether the textures are color glyphs, 1 for color glyphs and 0 for
+	// masks tinted with the foreground color. X is for Tex1 and Y for Tex2
+	Colored common.Vector2[float32] // layout 6
+	// decorations of the cell drawn with the special color, see the
+	// Decoration flags
+	Style float32 // layout 7
 }
 
+// Decorations drawn by the fragment shader, a cell may have more than one of
+// them. Values must be the same with the grid.frag.
+type Decoration uint32
+
+const (
+	DecorationUnderline     Decoration = 1 << iota
+	DecorationUnderlineLine            // double underline
+	DecorationUnderdot
+	DecorationUnderdash
+	DecorationUndercurl
+	DecorationStrikethrough
+)
+
 func (vertex Vertex) String() string {
-	return fmt.Sprintf("Vertex(pos: %v, tex1: %v, tex2: %v, fg: %v, bg: %v, sp: %v)",
+	return fmt.Sprintf("Vertex(pos: %v, tex1: %v, tex2: %v, fg: %v, bg: %v, sp: %v, colored: %v, style: %v)",
 		vertex.Pos,
 		vertex.Tex1,
 		vertex.Tex2,
 		vertex.Fg,
 		vertex.Bg,
 		vertex.Sp,
+		vertex.Colored,
+		vertex.Style,
 	)
 }
 
-const sizeof_Vertex = int32(unsafe.Sizeof(Vertex{})) // 96 bytes
+const sizeof_Vertex = int32(unsafe.Sizeof(Vertex{})) // 108 bytes
 
 type VertexBuffer struct {
 	shader      *ShaderProgram
@@ -161,6 +183,20 @@ func (buffer *VertexBuffer) Render() {
 	checkGLError()
 }
 
+// Renders count vertices starting from begin
+// Caller responsible to bind buffer
+// Caller responsible to Flush
+func (buffer *VertexBuffer) RenderRange(begin, count int) {
+	if boundVertexArrayId != buffer.vaoid {
+		panic("vertex buffer must be bound before render")
+	}
+	if begin < 0 || count <= 0 || begin+count > buffer.updatedSize {
+		panic("render range is out of bounds")
+	}
+	gl.DrawArrays(gl.POINTS, int32(begin), int32(count))
+	checkGLError()
+}
+
 func orthoProjection(top, left, right, bottom, near, far float32) [16]float32 {
 	rml, tmb, fmn := (right - left), (top - bottom), (far - near)
 	matrix := [16]float32{}
@@ -180,9 +216,11 @@ func (buffer *VertexBuffer) SetProjection(rect common.Rectangle[float32]) {
 	gl.UniformMatrix4fv(loc, 1, true, &projection[0])
 }
 
-func (buffer *VertexBuffer) SetUndercurlRect(rect common.Rectangle[float32]) {
-	loc := buffer.shader.UniformLocation("undercurlRect")
-	gl.Uniform4f(loc, rect.X, rect.Y, rect.W, rect.H)
+func (buffer *VertexBuffer) SetDecorationMetrics(metrics fontkit.DecorationMetrics) {
+	loc := buffer.shader.UniformLocation("decorations")
+	gl.Uniform3f(loc, metrics.Underline, metrics.Strikethrough, metrics.Thickness)
+	loc = buffer.shader.UniformLocation("undercurl")
+	gl.Uniform4f(loc, metrics.Undercurl, metrics.Amplitude, metrics.Wavelength, metrics.CurlThickness)
 }
 
 func (buffer *VertexBuffer) Destroy() {
@@ -220,12 +258,37 @@ func (buffer *VertexBuffer) SetIndexSp(index int, sp common.Color) {
 	buffer.data[index].Sp = sp
 }
 
+func (buffer *VertexBuffer) SetIndexTex1Colored(index int, colored bool) {
+	buffer.data[index].Colored.X = boolToFloat(colored)
+}
+
+func (buffer *VertexBuffer) SetIndexTex2Colored(index int, colored bool) {
+	buffer.data[index].Colored.Y = boolToFloat(colored)
+}
+
+func (buffer *VertexBuffer) SetIndexStyle(index int, style Decoration) {
+	buffer.data[index].Style = float32(style)
+}
+
+func boolToFloat(b bool) float32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (buffer *VertexBuffer) SetVertex(index int, vertex Vertex) {
+	buffer.data[index] = vertex
+}
+
 func (buffer *VertexBuffer) CopyButPos(dst, src int) {
 	buffer.data[dst].Tex1 = buffer.data[src].Tex1
 	buffer.data[dst].Tex2 = buffer.data[src].Tex2
 	buffer.data[dst].Fg = buffer.data[src].Fg
 	buffer.data[dst].Bg = buffer.data[src].Bg
 	buffer.data[dst].Sp = buffer.data[src].Sp
+	buffer.data[dst].Colored = buffer.data[src].Colored
+	buffer.data[dst].Style = buffer.data[src].Style
 }
 
 func (buffer *VertexBuffer) VertexAt(index int) Vertex {
@@ -71,12 +71,14 @@ package gl
 // typedef void  (APIENTRYP GPBINDFRAMEBUFFER)(GLenum  target, GLuint  framebuffer);
 // typedef void  (APIENTRYP GPBINDTEXTURE)(GLenum  target, GLuint  texture);
 // typedef void  (APIENTRYP GPBINDVERTEXARRAY)(GLuint  array);
+// typedef void  (APIENTRYP GPBLENDFUNC)(GLenum  sfactor, GLenum  dfactor);
 // typedef void  (APIENTRYP GPBUFFERDATA)(GLenum  target, GLsizeiptr  size, const void * data, GLenum  usage);
 // typedef void  (APIENTRYP GPBUFFERSUBDATA)(GLenum  target, GLintptr  offset, GLsizeiptr  size, const void * data);
 // typedef GLenum  (APIENTRYP GPCHECKFRAMEBUFFERSTATUS)(GLenum  target);
 // typedef void  (APIENTRYP GPCLEAR)(GLbitfield  mask);
 // typedef void  (APIENTRYP GPCLEARCOLOR)(GLfloat  red, GLfloat  green, GLfloat  blue, GLfloat  alpha);
 // typedef void  (APIENTRYP GPCOMPILESHADER)(GLuint  shader);
+// typedef void  (APIENTRYP GPCOPYTEXSUBIMAGE3D)(GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLint  zoffset, GLint  x, GLint  y, GLsizei  width, GLsizei  height);
 // typedef GLuint  (APIENTRYP GPCREATEPROGRAM)();
 // typedef GLuint  (APIENTRYP GPCREATESHADER)(GLenum  type);
 // typedef void  (APIENTRYP GPDELETEBUFFERS)(GLsizei  n, const GLuint * buffers);
@@ -91,6 +93,7 @@ package gl
 // typedef void  (APIENTRYP GPENABLEVERTEXATTRIBARRAY)(GLuint  index);
 // typedef void  (APIENTRYP GPFLUSH)();
 // typedef void  (APIENTRYP GPFRAMEBUFFERTEXTURE2D)(GLenum  target, GLenum  attachment, GLenum  textarget, GLuint  texture, GLint  level);
+// typedef void  (APIENTRYP GPFRAMEBUFFERTEXTURELAYER)(GLenum  target, GLenum  attachment, GLuint  texture, GLint  level, GLint  layer);
 // typedef void  (APIENTRYP GPGENBUFFERS)(GLsizei  n, GLuint * buffers);
 // typedef void  (APIENTRYP GPGENFRAMEBUFFERS)(GLsizei  n, GLuint * framebuffers);
 // typedef void  (APIENTRYP GPGENTEXTURES)(GLsizei  n, GLuint * textures);
@@ -104,10 +107,14 @@ package gl
 // typedef const GLubyte * (APIENTRYP GPGETSTRING)(GLenum  name);
 // typedef GLint  (APIENTRYP GPGETUNIFORMLOCATION)(GLuint  program, const GLchar * name);
 // typedef void  (APIENTRYP GPLINKPROGRAM)(GLuint  program);
+// typedef void  (APIENTRYP GPSCISSOR)(GLint  x, GLint  y, GLsizei  width, GLsizei  height);
 // typedef void  (APIENTRYP GPSHADERSOURCE)(GLuint  shader, GLsizei  count, const GLchar *const* string, const GLint * length);
 // typedef void  (APIENTRYP GPTEXIMAGE2D)(GLenum  target, GLint  level, GLint  internalformat, GLsizei  width, GLsizei  height, GLint  border, GLenum  format, GLenum  type, const void * pixels);
+// typedef void  (APIENTRYP GPTEXIMAGE3D)(GLenum  target, GLint  level, GLint  internalformat, GLsizei  width, GLsizei  height, GLsizei  depth, GLint  border, GLenum  format, GLenum  type, const void * pixels);
 // typedef void  (APIENTRYP GPTEXPARAMETERI)(GLenum  target, GLenum  pname, GLint  param);
 // typedef void  (APIENTRYP GPTEXSUBIMAGE2D)(GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLsizei  width, GLsizei  height, GLenum  format, GLenum  type, const void * pixels);
+// typedef void  (APIENTRYP GPTEXSUBIMAGE3D)(GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLint  zoffset, GLsizei  width, GLsizei  height, GLsizei  depth, GLenum  format, GLenum  type, const void * pixels);
+// typedef void  (APIENTRYP GPUNIFORM3F)(GLint  location, GLfloat  v0, GLfloat  v1, GLfloat  v2);
 // typedef void  (APIENTRYP GPUNIFORM4F)(GLint  location, GLfloat  v0, GLfloat  v1, GLfloat  v2, GLfloat  v3);
 // typedef void  (APIENTRYP GPUNIFORM4FV)(GLint  location, GLsizei  count, const GLfloat * value);
 // typedef void  (APIENTRYP GPUNIFORMMATRIX4FV)(GLint  location, GLsizei  count, GLboolean  transpose, const GLfloat * value);
@@ -132,6 +139,9 @@ package gl
 // static void  glowBindVertexArray(GPBINDVERTEXARRAY fnptr, GLuint  array) {
 //   (*fnptr)(array);
 // }
+// static void  glowBlendFunc(GPBLENDFUNC fnptr, GLenum  sfactor, GLenum  dfactor) {
+//   (*fnptr)(sfactor, dfactor);
+// }
 // static void  glowBufferData(GPBUFFERDATA fnptr, GLenum  target, GLsizeiptr  size, const void * data, GLenum  usage) {
 //   (*fnptr)(target, size, data, usage);
 // }
@@ -150,6 +160,9 @@ package gl
 // static void  glowCompileShader(GPCOMPILESHADER fnptr, GLuint  shader) {
 //   (*fnptr)(shader);
 // }
+// static void  glowCopyTexSubImage3D(GPCOPYTEXSUBIMAGE3D fnptr, GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLint  zoffset, GLint  x, GLint  y, GLsizei  width, GLsizei  height) {
+//   (*fnptr)(target, level, xoffset, yoffset, zoffset, x, y, width, height);
+// }
 // static GLuint  glowCreateProgram(GPCREATEPROGRAM fnptr) {
 //   return (*fnptr)();
 // }
@@ -192,6 +205,9 @@ package gl
 // static void  glowFramebufferTexture2D(GPFRAMEBUFFERTEXTURE2D fnptr, GLenum  target, GLenum  attachment, GLenum  textarget, GLuint  texture, GLint  level) {
 //   (*fnptr)(target, attachment, textarget, texture, level);
 // }
+// static void  glowFramebufferTextureLayer(GPFRAMEBUFFERTEXTURELAYER fnptr, GLenum  target, GLenum  attachment, GLuint  texture, GLint  level, GLint  layer) {
+//   (*fnptr)(target, attachment, texture, level, layer);
+// }
 // static void  glowGenBuffers(GPGENBUFFERS fnptr, GLsizei  n, GLuint * buffers) {
 //   (*fnptr)(n, buffers);
 // }
@@ -231,18 +247,30 @@ package gl
 // static void  glowLinkProgram(GPLINKPROGRAM fnptr, GLuint  program) {
 //   (*fnptr)(program);
 // }
+// static void  glowScissor(GPSCISSOR fnptr, GLint  x, GLint  y, GLsizei  width, GLsizei  height) {
+//   (*fnptr)(x, y, width, height);
+// }
 // static void  glowShaderSource(GPSHADERSOURCE fnptr, GLuint  shader, GLsizei  count, const GLchar *const* string, const GLint * length) {
 //   (*fnptr)(shader, count, string, length);
 // }
 // static void  glowTexImage2D(GPTEXIMAGE2D fnptr, GLenum  target, GLint  level, GLint  internalformat, GLsizei  width, GLsizei  height, GLint  border, GLenum  format, GLenum  type, const void * pixels) {
 //   (*fnptr)(target, level, internalformat, width, height, border, format, type, pixels);
 // }
+// static void  glowTexImage3D(GPTEXIMAGE3D fnptr, GLenum  target, GLint  level, GLint  internalformat, GLsizei  width, GLsizei  height, GLsizei  depth, GLint  border, GLenum  format, GLenum  type, const void * pixels) {
+//   (*fnptr)(target, level, internalformat, width, height, depth, border, format, type, pixels);
+// }
 // static void  glowTexParameteri(GPTEXPARAMETERI fnptr, GLenum  target, GLenum  pname, GLint  param) {
 //   (*fnptr)(target, pname, param);
 // }
 // static void  glowTexSubImage2D(GPTEXSUBIMAGE2D fnptr, GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLsizei  width, GLsizei  height, GLenum  format, GLenum  type, const void * pixels) {
 //   (*fnptr)(target, level, xoffset, yoffset, width, height, format, type, pixels);
 // }
+// static void  glowTexSubImage3D(GPTEXSUBIMAGE3D fnptr, GLenum  target, GLint  level, GLint  xoffset, GLint  yoffset, GLint  zoffset, GLsizei  width, GLsizei  height, GLsizei  depth, GLenum  format, GLenum  type, const void * pixels) {
+//   (*fnptr)(target, level, xoffset, yoffset, zoffset, width, height, depth, format, type, pixels);
+// }
+// static void  glowUniform3f(GPUNIFORM3F fnptr, GLint  location, GLfloat  v0, GLfloat  v1, GLfloat  v2) {
+//   (*fnptr)(location, v0, v1, v2);
+// }
 // static void  glowUniform4f(GPUNIFORM4F fnptr, GLint  location, GLfloat  v0, GLfloat  v1, GLfloat  v2, GLfloat  v3) {
 //   (*fnptr)(location, v0, v1, v2, v3);
 // }
@@ -295,16 +323,23 @@ const (
 	MAX_TEXTURE_SIZE         = 0x0D33
 	NEAREST                  = 0x2600
 	NO_ERROR                 = 0
+	ONE_MINUS_SRC1_COLOR     = 0x88FA
+	ONE_MINUS_SRC_ALPHA      = 0x0303
 	OUT_OF_MEMORY            = 0x0505
 	POINTS                   = 0x0000
+	READ_FRAMEBUFFER         = 0x8CA8
 	RENDERER                 = 0x1F01
 	RGBA                     = 0x1908
 	RGBA8                    = 0x8058
+	SCISSOR_TEST             = 0x0C11
 	SHADING_LANGUAGE_VERSION = 0x8B8C
+	SRC1_COLOR               = 0x88F9
+	SRC_ALPHA                = 0x0302
 	STACK_OVERFLOW           = 0x0503
 	STACK_UNDERFLOW          = 0x0504
 	TEXTURE0                 = 0x84C0
 	TEXTURE_2D               = 0x0DE1
+	TEXTURE_2D_ARRAY         = 0x8C1A
 	TEXTURE_MAG_FILTER       = 0x2800
 	TEXTURE_MIN_FILTER       = 0x2801
 	TEXTURE_WRAP_S           = 0x2802
@@ -322,12 +357,14 @@ var (
 	gpBindFramebuffer         C.GPBINDFRAMEBUFFER
 	gpBindTexture             C.GPBINDTEXTURE
 	gpBindVertexArray         C.GPBINDVERTEXARRAY
+	gpBlendFunc               C.GPBLENDFUNC
 	gpBufferData              C.GPBUFFERDATA
 	gpBufferSubData           C.GPBUFFERSUBDATA
 	gpCheckFramebufferStatus  C.GPCHECKFRAMEBUFFERSTATUS
 	gpClear                   C.GPCLEAR
 	gpClearColor              C.GPCLEARCOLOR
 	gpCompileShader           C.GPCOMPILESHADER
+	gpCopyTexSubImage3D       C.GPCOPYTEXSUBIMAGE3D
 	gpCreateProgram           C.GPCREATEPROGRAM
 	gpCreateShader            C.GPCREATESHADER
 	gpDeleteBuffers           C.GPDELETEBUFFERS
@@ -342,6 +379,7 @@ var (
 	gpEnableVertexAttribArray C.GPENABLEVERTEXATTRIBARRAY
 	gpFlush                   C.GPFLUSH
 	gpFramebufferTexture2D    C.GPFRAMEBUFFERTEXTURE2D
+	gpFramebufferTextureLayer C.GPFRAMEBUFFERTEXTURELAYER
 	gpGenBuffers              C.GPGENBUFFERS
 	gpGenFramebuffers         C.GPGENFRAMEBUFFERS
 	gpGenTextures             C.GPGENTEXTURES
@@ -355,10 +393,14 @@ var (
 	gpGetString               C.GPGETSTRING
 	gpGetUniformLocation      C.GPGETUNIFORMLOCATION
 	gpLinkProgram             C.GPLINKPROGRAM
+	gpScissor                 C.GPSCISSOR
 	gpShaderSource            C.GPSHADERSOURCE
 	gpTexImage2D              C.GPTEXIMAGE2D
+	gpTexImage3D              C.GPTEXIMAGE3D
 	gpTexParameteri           C.GPTEXPARAMETERI
 	gpTexSubImage2D           C.GPTEXSUBIMAGE2D
+	gpTexSubImage3D           C.GPTEXSUBIMAGE3D
+	gpUniform3f               C.GPUNIFORM3F
 	gpUniform4f               C.GPUNIFORM4F
 	gpUniform4fv              C.GPUNIFORM4FV
 	gpUniformMatrix4fv        C.GPUNIFORMMATRIX4FV
@@ -405,6 +447,11 @@ func BindVertexArray(array uint32) {
 	C.glowBindVertexArray(gpBindVertexArray, (C.GLuint)(array))
 }
 
+// specify pixel arithmetic
+func BlendFunc(sfactor uint32, dfactor uint32) {
+	C.glowBlendFunc(gpBlendFunc, (C.GLenum)(sfactor), (C.GLenum)(dfactor))
+}
+
 // creates and initializes a buffer object's data     store
 func BufferData(target uint32, size int, data unsafe.Pointer, usage uint32) {
 	C.glowBufferData(gpBufferData, (C.GLenum)(target), (C.GLsizeiptr)(size), data, (C.GLenum)(usage))
@@ -436,6 +483,11 @@ func CompileShader(shader uint32) {
 	C.glowCompileShader(gpCompileShader, (C.GLuint)(shader))
 }
 
+// copy a three-dimensional texture subimage
+func CopyTexSubImage3D(target uint32, level int32, xoffset int32, yoffset int32, zoffset int32, x int32, y int32, width int32, height int32) {
+	C.glowCopyTexSubImage3D(gpCopyTexSubImage3D, (C.GLenum)(target), (C.GLint)(level), (C.GLint)(xoffset), (C.GLint)(yoffset), (C.GLint)(zoffset), (C.GLint)(x), (C.GLint)(y), (C.GLsizei)(width), (C.GLsizei)(height))
+}
+
 // Creates a program object
 func CreateProgram() uint32 {
 	ret := C.glowCreateProgram(gpCreateProgram)
@@ -506,6 +558,11 @@ func FramebufferTexture2D(target uint32, attachment uint32, textarget uint32, te
 	C.glowFramebufferTexture2D(gpFramebufferTexture2D, (C.GLenum)(target), (C.GLenum)(attachment), (C.GLenum)(textarget), (C.GLuint)(texture), (C.GLint)(level))
 }
 
+// attach a single layer of a texture object as a logical buffer of a framebuffer object
+func FramebufferTextureLayer(target uint32, attachment uint32, texture uint32, level int32, layer int32) {
+	C.glowFramebufferTextureLayer(gpFramebufferTextureLayer, (C.GLenum)(target), (C.GLenum)(attachment), (C.GLuint)(texture), (C.GLint)(level), (C.GLint)(layer))
+}
+
 // generate buffer object names
 func GenBuffers(n int32, buffers *uint32) {
 	C.glowGenBuffers(gpGenBuffers, (C.GLsizei)(n), (*C.GLuint)(unsafe.Pointer(buffers)))
@@ -572,6 +629,11 @@ func LinkProgram(program uint32) {
 	C.glowLinkProgram(gpLinkProgram, (C.GLuint)(program))
 }
 
+// define the scissor box
+func Scissor(x int32, y int32, width int32, height int32) {
+	C.glowScissor(gpScissor, (C.GLint)(x), (C.GLint)(y), (C.GLsizei)(width), (C.GLsizei)(height))
+}
+
 // Replaces the source code in a shader object
 func ShaderSource(shader uint32, count int32, xstring **uint8, length *int32) {
 	C.glowShaderSource(gpShaderSource, (C.GLuint)(shader), (C.GLsizei)(count), (**C.GLchar)(unsafe.Pointer(xstring)), (*C.GLint)(unsafe.Pointer(length)))
@@ -581,6 +643,12 @@ func ShaderSource(shader uint32, count int32, xstring **uint8, length *int32) {
 func TexImage2D(target uint32, level int32, internalformat int32, width int32, height int32, border int32, format uint32, xtype uint32, pixels unsafe.Pointer) {
 	C.glowTexImage2D(gpTexImage2D, (C.GLenum)(target), (C.GLint)(level), (C.GLint)(internalformat), (C.GLsizei)(width), (C.GLsizei)(height), (C.GLint)(border), (C.GLenum)(format), (C.GLenum)(xtype), pixels)
 }
+
+// specify a three-dimensional texture image
+func TexImage3D(target uint32, level int32, internalformat int32, width int32, height int32, depth int32, border int32, format uint32, xtype uint32, pixels unsafe.Pointer) {
+	C.glowTexImage3D(gpTexImage3D, (C.GLenum)(target), (C.GLint)(level), (C.GLint)(internalformat), (C.GLsizei)(width), (C.GLsizei)(height), (C.GLsizei)(depth), (C.GLint)(border), (C.GLenum)(format), (C.GLenum)(xtype), pixels)
+}
+
 func TexParameteri(target uint32, pname uint32, param int32) {
 	C.glowTexParameteri(gpTexParameteri, (C.GLenum)(target), (C.GLenum)(pname), (C.GLint)(param))
 }
@@ -590,6 +658,16 @@ func TexSubImage2D(target uint32, level int32, xoffset int32, yoffset int32, wid
 	C.glowTexSubImage2D(gpTexSubImage2D, (C.GLenum)(target), (C.GLint)(level), (C.GLint)(xoffset), (C.GLint)(yoffset), (C.GLsizei)(width), (C.GLsizei)(height), (C.GLenum)(format), (C.GLenum)(xtype), pixels)
 }
 
+// specify a three-dimensional texture subimage
+func TexSubImage3D(target uint32, level int32, xoffset int32, yoffset int32, zoffset int32, width int32, height int32, depth int32, format uint32, xtype uint32, pixels unsafe.Pointer) {
+	C.glowTexSubImage3D(gpTexSubImage3D, (C.GLenum)(target), (C.GLint)(level), (C.GLint)(xoffset), (C.GLint)(yoffset), (C.GLint)(zoffset), (C.GLsizei)(width), (C.GLsizei)(height), (C.GLsizei)(depth), (C.GLenum)(format), (C.GLenum)(xtype), pixels)
+}
+
+// Specify the value of a uniform variable for the current program object
+func Uniform3f(location int32, v0 float32, v1 float32, v2 float32) {
+	C.glowUniform3f(gpUniform3f, (C.GLint)(location), (C.GLfloat)(v0), (C.GLfloat)(v1), (C.GLfloat)(v2))
+}
+
 // Specify the value of a uniform variable for the current program object
 func Uniform4f(location int32, v0 float32, v1 float32, v2 float32, v3 float32) {
 	C.glowUniform4f(gpUniform4f, (C.GLint)(location), (C.GLfloat)(v0), (C.GLfloat)(v1), (C.GLfloat)(v2), (C.GLfloat)(v3))
@@ -674,6 +752,10 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpBindVertexArray == nil {
 		return errors.New("glBindVertexArray")
 	}
+	gpBlendFunc = (C.GPBLENDFUNC)(getProcAddr("glBlendFunc"))
+	if gpBlendFunc == nil {
+		return errors.New("glBlendFunc")
+	}
 	gpBufferData = (C.GPBUFFERDATA)(getProcAddr("glBufferData"))
 	if gpBufferData == nil {
 		return errors.New("glBufferData")
@@ -698,6 +780,10 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpCompileShader == nil {
 		return errors.New("glCompileShader")
 	}
+	gpCopyTexSubImage3D = (C.GPCOPYTEXSUBIMAGE3D)(getProcAddr("glCopyTexSubImage3D"))
+	if gpCopyTexSubImage3D == nil {
+		return errors.New("glCopyTexSubImage3D")
+	}
 	gpCreateProgram = (C.GPCREATEPROGRAM)(getProcAddr("glCreateProgram"))
 	if gpCreateProgram == nil {
 		return errors.New("glCreateProgram")
@@ -754,6 +840,10 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpFramebufferTexture2D == nil {
 		return errors.New("glFramebufferTexture2D")
 	}
+	gpFramebufferTextureLayer = (C.GPFRAMEBUFFERTEXTURELAYER)(getProcAddr("glFramebufferTextureLayer"))
+	if gpFramebufferTextureLayer == nil {
+		return errors.New("glFramebufferTextureLayer")
+	}
 	gpGenBuffers = (C.GPGENBUFFERS)(getProcAddr("glGenBuffers"))
 	if gpGenBuffers == nil {
 		return errors.New("glGenBuffers")
@@ -806,6 +896,10 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpLinkProgram == nil {
 		return errors.New("glLinkProgram")
 	}
+	gpScissor = (C.GPSCISSOR)(getProcAddr("glScissor"))
+	if gpScissor == nil {
+		return errors.New("glScissor")
+	}
 	gpShaderSource = (C.GPSHADERSOURCE)(getProcAddr("glShaderSource"))
 	if gpShaderSource == nil {
 		return errors.New("glShaderSource")
@@ -814,6 +908,10 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpTexImage2D == nil {
 		return errors.New("glTexImage2D")
 	}
+	gpTexImage3D = (C.GPTEXIMAGE3D)(getProcAddr("glTexImage3D"))
+	if gpTexImage3D == nil {
+		return errors.New("glTexImage3D")
+	}
 	gpTexParameteri = (C.GPTEXPARAMETERI)(getProcAddr("glTexParameteri"))
 	if gpTexParameteri == nil {
 		return errors.New("glTexParameteri")
@@ -822,6 +920,14 @@ func InitWithProcAddrFunc(getProcAddr func(name string) unsafe.Pointer) error {
 	if gpTexSubImage2D == nil {
 		return errors.New("glTexSubImage2D")
 	}
+	gpTexSubImage3D = (C.GPTEXSUBIMAGE3D)(getProcAddr("glTexSubImage3D"))
+	if gpTexSubImage3D == nil {
+		return errors.New("glTexSubImage3D")
+	}
+	gpUniform3f = (C.GPUNIFORM3F)(getProcAddr("glUniform3f"))
+	if gpUniform3f == nil {
+		return errors.New("glUniform3f")
+	}
 	gpUniform4f = (C.GPUNIFORM4F)(getProcAddr("glUniform4f"))
 	if gpUniform4f == nil {
 		return errors.New("glUniform4f")
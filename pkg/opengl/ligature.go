@@ -0,0 +1,156 @@
+package opengl
+
+import (
+	"strings"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	// Maximum number of cells of a ligature cluster. Longer clusters are
+	// split, ligatures are rarely longer than a few characters.
+	MAX_LIGATURE_CELLS = 16
+	// Shaped runs are cached until this number of runs, then the cache is
+	// cleared
+	MAX_SHAPE_CACHE = 4096
+)
+
+// Consecutive cells whose glyphs are changed by the shaping. Cluster is
+// drawn as one image and every cell draws its own part of the image.
+type LigatureCluster struct {
+	Start, End int // Cells of the cluster, [Start, End) in the shaped chars
+	font       *fontkit.Font
+	kit        *fontkit.FontKit // nil for the system fallback fonts
+	glyphs     []sfnt.GlyphIndex
+}
+
+type shapeKey struct {
+	chars        string
+	bold, italic bool
+}
+
+type ligatureKey struct {
+	font   *fontkit.Font
+	glyphs string
+	flags  uint8
+}
+
+// Reports whether the char can be a part of a ligature. Double width chars,
+// grapheme clusters and the chars drawn by us are always drawn alone.
+func (atlas *Atlas) shapeable(char rune) bool {
+	if fontkit.IsCluster(char) {
+		return false
+	}
+	if atlas.drawsItself(char) {
+		return false
+	}
+	return runewidth.RuneWidth(char) == 1
+}
+
+// Shapes the chars of the same style and returns the clusters of the cells
+// which must be drawn with the ligature glyphs. Chars which are not in a
+// cluster are drawn as usual. Chars are split to the runs of the same font,
+// ligatures are not formed between different fonts.
+func (atlas *Atlas) Shape(chars []rune, bold, italic bool) []LigatureCluster {
+	key := shapeKey{chars: string(chars), bold: bold, italic: italic}
+	clusters, ok := atlas.shapes[key]
+	if ok {
+		return clusters
+	}
+	for start := 0; start < len(chars); {
+		end := start + 1
+		font, kit, contains := atlas.suitableFont(chars[start], bold, italic)
+		if contains && font.HasLigatures() && atlas.shapeable(chars[start]) {
+			for end < len(chars) && atlas.shapeable(chars[end]) {
+				next, _, contains := atlas.suitableFont(chars[end], bold, italic)
+				if !contains || next != font {
+					break
+				}
+				end++
+			}
+			clusters = append(clusters, atlas.shapeRun(chars[start:end], start, font, kit)...)
+		}
+		start = end
+	}
+	if len(atlas.shapes) >= MAX_SHAPE_CACHE {
+		atlas.shapes = make(map[shapeKey][]LigatureCluster)
+	}
+	atlas.shapes[key] = clusters
+	return clusters
+}
+
+func (atlas *Atlas) shapeRun(chars []rune, offset int, font *fontkit.Font, kit *fontkit.FontKit) []LigatureCluster {
+	// Cluster image must fit to the texture
+	maxCells := common.Min(MAX_LIGATURE_CELLS, atlas.texture.Size().Width()/atlas.ImageSize().Width())
+	glyphs := font.Shape(chars)
+	plain := make([]sfnt.GlyphIndex, len(chars))
+	for i, char := range chars {
+		plain[i] = font.GlyphIndex(char)
+	}
+	var clusters []LigatureCluster
+	for _, cells := range changedCells(glyphs, plain, maxCells) {
+		clusters = append(clusters, LigatureCluster{
+			Start:  offset + cells[0],
+			End:    offset + cells[1],
+			font:   font,
+			kit:    kit,
+			glyphs: glyphs[cells[0]:cells[1]],
+		})
+	}
+	return clusters
+}
+
+// Returns the cells [start, end) whose shaped glyphs are different than the
+// glyphs of their chars. Consecutive changed cells are one cluster, clusters
+// longer than maxCells are split.
+func changedCells(shaped, plain []sfnt.GlyphIndex, maxCells int) [][2]int {
+	var cells [][2]int
+	for i := 0; i < len(shaped); {
+		if shaped[i] == plain[i] {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(shaped) && j-i < maxCells && shaped[j] != plain[j] {
+			j++
+		}
+		cells = append(cells, [2]int{i, j})
+		i = j
+	}
+	return cells
+}
+
+func getLigatureKey(cluster LigatureCluster, bold, italic bool) ligatureKey {
+	var glyphs strings.Builder
+	for _, glyph := range cluster.glyphs {
+		glyphs.WriteByte(byte(glyph >> 8))
+		glyphs.WriteByte(byte(glyph))
+	}
+	var flags uint8
+	for i, flag := range []bool{bold, italic} {
+		if flag {
+			flags |= 1 << i
+		}
+	}
+	return ligatureKey{font: cluster.font, glyphs: glyphs.String(), flags: flags}
+}
+
+// Returns the position of the cluster image in the texture, draws the image
+// for the first time. Width of the image is the cell width times the cells
+// of the cluster.
+func (atlas *Atlas) GetClusterPos(cluster LigatureCluster, bold, italic bool, imgSize common.Vector2[int]) common.Rectangle[int] {
+	key := getLigatureKey(cluster, bold, italic)
+	pos, ok := atlas.ligatures[key]
+	if ok {
+		atlas.touch(pos)
+		return pos
+	}
+	face := atlas.styledFace(cluster.font, cluster.kit, bold, italic)
+	img := face.RenderGlyphs(cluster.glyphs, imgSize)
+	pos = atlas.drawImage(img)
+	atlas.ligatures[key] = pos
+	return pos
+}
@@ -0,0 +1,40 @@
+package opengl
+
+import (
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/fontkit"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestChangedCells(t *testing.T) {
+	const (
+		a, b, c     = 10, 11, 12
+		arrow, bold = 20, 21
+		none        = fontkit.NoGlyph
+	)
+	for _, test := range []struct {
+		name     string
+		shaped   []sfnt.GlyphIndex
+		plain    []sfnt.GlyphIndex
+		maxCells int
+		want     [][2]int
+	}{
+		{"unchanged", []sfnt.GlyphIndex{a, b, c}, []sfnt.GlyphIndex{a, b, c}, 16, nil},
+		{"ligature", []sfnt.GlyphIndex{a, arrow, none, c}, []sfnt.GlyphIndex{a, b, b, c}, 16, [][2]int{{1, 3}}},
+		{"two clusters", []sfnt.GlyphIndex{arrow, none, a, bold, bold}, []sfnt.GlyphIndex{b, b, a, c, c}, 16, [][2]int{{0, 2}, {3, 5}}},
+		{"split", []sfnt.GlyphIndex{bold, bold, bold, bold, bold}, []sfnt.GlyphIndex{c, c, c, c, c}, 2, [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+	} {
+		got := changedCells(test.shaped, test.plain, test.maxCells)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: cells are %v, expected %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: cells are %v, expected %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -20,6 +20,7 @@ type ContextInfo struct {
 type Context struct {
 	shader      *ShaderProgram // default shader for monospaced font rendering
 	framebuffer uint32         // only for clearing textures
+	viewport    common.Rectangle[int]
 }
 
 // Call per window
@@ -42,7 +43,8 @@ func New(getProcAddress func(name string) unsafe.Pointer) (*Context, error) {
 	geom.Delete()
 	frag.Delete()
 	// TODO: When we start using multiple shaders this line will be deleted
-	context.shader.Use()
+	// This also resets caches, required when there are multiple contexts
+	context.MakeCurrent()
 
 	// Create framebuffer object
 	// We dont need to bind framebuffer because we need it only when clearing texture
@@ -52,6 +54,16 @@ func New(getProcAddress func(name string) unsafe.Pointer) (*Context, error) {
 	return context, nil
 }
 
+// Must be called after the context made current for the calling thread.
+// Bound object caches are only valid for one context and object ids may be
+// same between different contexts.
+func (context *Context) MakeCurrent() {
+	boundVertexArrayId = 0
+	boundTextureId = 0
+	currentShaderProgramID = 0
+	context.shader.Use()
+}
+
 func (context *Context) Info() ContextInfo {
 	info := ContextInfo{
 		Version:                gl.GoStr(gl.GetString(gl.VERSION)),
@@ -66,6 +78,23 @@ func (context *Context) Info() ContextInfo {
 func (context *Context) SetViewport(rect common.Rectangle[int]) {
 	gl.Viewport(int32(rect.X), int32(rect.Y), int32(rect.W), int32(rect.H))
 	checkGLError()
+	context.viewport = rect
+}
+
+// Restricts drawing and clearing to the given rectangle until DisableScissor
+// called. Rectangle is in window coordinates, origin is top left corner.
+func (context *Context) EnableScissor(rect common.Rectangle[int]) {
+	gl.Enable(gl.SCISSOR_TEST)
+	checkGLError()
+	// Opengl origin is bottom left corner
+	y := context.viewport.H - (rect.Y + rect.H)
+	gl.Scissor(int32(rect.X), int32(y), int32(rect.W), int32(rect.H))
+	checkGLError()
+}
+
+func (context *Context) DisableScissor() {
+	gl.Disable(gl.SCISSOR_TEST)
+	checkGLError()
 }
 
 func (context *Context) ClearScreen(c common.Color) {
@@ -75,6 +104,21 @@ func (context *Context) ClearScreen(c common.Color) {
 	checkGLError()
 }
 
+// Enables alpha blending for the following draw calls. Grids are always
+// rendered opaque, blending is only required for overlays. Shaders output
+// the opacity of every channel as the second color (dual source blending).
+func (context *Context) EnableBlending() {
+	gl.Enable(gl.BLEND)
+	checkGLError()
+	gl.BlendFunc(gl.SRC1_COLOR, gl.ONE_MINUS_SRC1_COLOR)
+	checkGLError()
+}
+
+func (context *Context) DisableBlending() {
+	gl.Disable(gl.BLEND)
+	checkGLError()
+}
+
 func (context *Context) Flush() {
 	// Since we are not using doublebuffering, we don't need to swap buffers, but we need to flush.
 	gl.Flush()
@@ -0,0 +1,133 @@
+package opengl
+
+import (
+	"github.com/hismailbulut/Neoray/pkg/common"
+)
+
+// Images are packed to the pages of the atlas in shelves. A shelf is a row
+// as tall as the image which opened it, the following images are placed
+// next to each other on the shelf which wastes the least height. Glyphs
+// mostly have the cell height, taller images (double height, color glyphs)
+// open their own shelves.
+
+type shelf struct {
+	y, height int
+	x         int // Start of the free space
+}
+
+type packerPage struct {
+	shelves    []shelf
+	bottom     int    // Start of the free space under the shelves
+	generation uint64 // Last generation an image of the page is used
+}
+
+type shelfPacker struct {
+	size     common.Vector2[int] // Size of every page
+	maxPages int
+	pages    []packerPage
+}
+
+func newShelfPacker(size common.Vector2[int], maxPages int) *shelfPacker {
+	return &shelfPacker{
+		size:     size,
+		maxPages: maxPages,
+	}
+}
+
+// Returns the page and the position of the image in the page. A new page is
+// added if none of the pages has space, ok is false if the page limit is
+// reached or the image is bigger than the page.
+func (packer *shelfPacker) pack(size common.Vector2[int]) (int, common.Rectangle[int], bool) {
+	if !packer.fits(size) {
+		return 0, common.Rectangle[int]{}, false
+	}
+	for i := range packer.pages {
+		if pos, ok := packer.pages[i].pack(size, packer.size); ok {
+			return i, pos, true
+		}
+	}
+	if len(packer.pages) >= packer.maxPages {
+		return 0, common.Rectangle[int]{}, false
+	}
+	packer.pages = append(packer.pages, packerPage{})
+	page := len(packer.pages) - 1
+	pos, ok := packer.pages[page].pack(size, packer.size)
+	return page, pos, ok
+}
+
+// Packs the image like pack, but clears the least recently used page and
+// packs the image to it when every page is full. evicted is the cleared
+// page, -1 if no page is cleared. Images bigger than a page never clear a
+// page.
+func (packer *shelfPacker) packEvicting(size common.Vector2[int]) (page int, pos common.Rectangle[int], evicted int, ok bool) {
+	evicted = -1
+	if !packer.fits(size) {
+		return 0, common.Rectangle[int]{}, evicted, false
+	}
+	page, pos, ok = packer.pack(size)
+	if !ok && len(packer.pages) > 0 {
+		evicted = packer.leastRecentlyUsed()
+		packer.clear(evicted)
+		page, pos, ok = packer.pack(size)
+	}
+	return page, pos, evicted, ok
+}
+
+// Reports whether the image is not bigger than a page.
+func (packer *shelfPacker) fits(size common.Vector2[int]) bool {
+	return size.Width() <= packer.size.Width() && size.Height() <= packer.size.Height()
+}
+
+func (page *packerPage) pack(size, pageSize common.Vector2[int]) (common.Rectangle[int], bool) {
+	width, height := size.Width(), size.Height()
+	best := -1
+	for i, shelf := range page.shelves {
+		if shelf.height < height || shelf.x+width > pageSize.Width() {
+			continue
+		}
+		if best == -1 || shelf.height < page.shelves[best].height {
+			best = i
+		}
+	}
+	// Opening a new shelf is better than wasting more than half of a shelf
+	fits := page.bottom+height <= pageSize.Height()
+	if best == -1 || (fits && page.shelves[best].height-height > height/2) {
+		if !fits {
+			return common.Rectangle[int]{}, false
+		}
+		page.shelves = append(page.shelves, shelf{y: page.bottom, height: height})
+		page.bottom += height
+		best = len(page.shelves) - 1
+	}
+	shelf := &page.shelves[best]
+	pos := common.Rect(shelf.x, shelf.y, width, height)
+	shelf.x += width
+	return pos, true
+}
+
+// Marks the page as used in the generation.
+func (packer *shelfPacker) touch(page int, generation uint64) {
+	packer.pages[page].generation = common.Max(packer.pages[page].generation, generation)
+}
+
+// Returns the page which is not used for the longest time.
+func (packer *shelfPacker) leastRecentlyUsed() int {
+	lru := 0
+	for i, page := range packer.pages {
+		if page.generation < packer.pages[lru].generation {
+			lru = i
+		}
+	}
+	return lru
+}
+
+// Removes every image of the page, the page can be filled again.
+func (packer *shelfPacker) clear(page int) {
+	packer.pages[page] = packerPage{}
+}
+
+// Removes every page.
+func (packer *shelfPacker) reset(size common.Vector2[int]) {
+	packer.size = size
+	packer.pages = nil
+}
@@ -0,0 +1,159 @@
+package opengl
+
+import (
+	"testing"
+
+	"github.com/hismailbulut/Neoray/pkg/common"
+)
+
+type packedImage struct {
+	page int
+	pos  common.Rectangle[int]
+}
+
+func packAll(t *testing.T, packer *shelfPacker, sizes []common.Vector2[int]) []packedImage {
+	packed := make([]packedImage, len(sizes))
+	for i, size := range sizes {
+		page, pos, ok := packer.pack(size)
+		if !ok {
+			t.Fatalf("image %d with size %v is not packed", i, size)
+		}
+		if pos.W != size.Width() || pos.H != size.Height() {
+			t.Fatalf("image %d with size %v is packed as %v", i, size, pos)
+		}
+		packed[i] = packedImage{page: page, pos: pos}
+	}
+	return packed
+}
+
+func checkPacking(t *testing.T, packer *shelfPacker, packed []packedImage) {
+	for i, a := range packed {
+		if a.pos.X < 0 || a.pos.Y < 0 || a.pos.X+a.pos.W > packer.size.Width() || a.pos.Y+a.pos.H > packer.size.Height() {
+			t.Errorf("image %d %v is outside of the page", i, a.pos)
+		}
+		for j, b := range packed[:i] {
+			if a.page == b.page && a.pos.X < b.pos.X+b.pos.W && b.pos.X < a.pos.X+a.pos.W && a.pos.Y < b.pos.Y+b.pos.H && b.pos.Y < a.pos.Y+a.pos.H {
+				t.Errorf("image %d %v overlaps with image %d %v", i, a.pos, j, b.pos)
+			}
+		}
+	}
+}
+
+func TestShelfPackerMixedHeights(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(64, 64), 1)
+	cell := common.Vec2(8, 16)
+	tall := common.Vec2(16, 32)
+	short := common.Vec2(8, 12)
+	packed := packAll(t, packer, []common.Vector2[int]{cell, tall, cell, short, tall, cell})
+	checkPacking(t, packer, packed)
+	// Cells and the short image share the first shelf, tall images open a
+	// shelf under it
+	for _, i := range []int{0, 2, 3, 5} {
+		if packed[i].pos.Y != 0 {
+			t.Errorf("image %d is not on the first shelf: %v", i, packed[i].pos)
+		}
+	}
+	for _, i := range []int{1, 4} {
+		if packed[i].pos.Y != 16 {
+			t.Errorf("tall image %d is not on the second shelf: %v", i, packed[i].pos)
+		}
+	}
+	if len(packer.pages[0].shelves) != 2 {
+		t.Errorf("page has %d shelves, expected 2", len(packer.pages[0].shelves))
+	}
+}
+
+func TestShelfPackerAddsPages(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(32, 32), 3)
+	cell := common.Vec2(8, 16)
+	// Every page fits 8 cells
+	sizes := make([]common.Vector2[int], 24)
+	for i := range sizes {
+		sizes[i] = cell
+	}
+	packed := packAll(t, packer, sizes)
+	checkPacking(t, packer, packed)
+	for i, p := range packed {
+		if p.page != i/8 {
+			t.Errorf("image %d is on page %d, expected %d", i, p.page, i/8)
+		}
+	}
+	if _, _, ok := packer.pack(cell); ok {
+		t.Error("image is packed after the page limit is reached")
+	}
+}
+
+func TestShelfPackerRejectsLargeImages(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(32, 32), 2)
+	for _, size := range []common.Vector2[int]{common.Vec2(33, 8), common.Vec2(8, 33)} {
+		if _, _, ok := packer.pack(size); ok {
+			t.Errorf("image with size %v is bigger than the page but packed", size)
+		}
+	}
+	if len(packer.pages) != 0 {
+		t.Errorf("packer has %d pages after failed packs", len(packer.pages))
+	}
+}
+
+func TestShelfPackerLeastRecentlyUsed(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(16, 16), 3)
+	packAll(t, packer, []common.Vector2[int]{common.Vec2(16, 16), common.Vec2(16, 16), common.Vec2(16, 16)})
+	packer.touch(0, 5)
+	packer.touch(1, 2)
+	packer.touch(2, 7)
+	// Older generation doesn't move the page back
+	packer.touch(0, 1)
+	if lru := packer.leastRecentlyUsed(); lru != 1 {
+		t.Errorf("least recently used page is %d, expected 1", lru)
+	}
+	packer.touch(1, 9)
+	if lru := packer.leastRecentlyUsed(); lru != 0 {
+		t.Errorf("least recently used page is %d, expected 0", lru)
+	}
+}
+
+func TestShelfPackerClearReusesPage(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(16, 16), 2)
+	full := common.Vec2(16, 16)
+	packAll(t, packer, []common.Vector2[int]{full, full})
+	if _, _, ok := packer.pack(full); ok {
+		t.Fatal("image is packed to the full pages")
+	}
+	packer.clear(1)
+	page, pos, ok := packer.pack(full)
+	if !ok || page != 1 || pos != common.Rect(0, 0, 16, 16) {
+		t.Errorf("image is packed to page %d at %v (ok %t), expected the cleared page", page, pos, ok)
+	}
+}
+
+func TestShelfPackerEvictsLeastRecentlyUsed(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(16, 16), 2)
+	full := common.Vec2(16, 16)
+	packAll(t, packer, []common.Vector2[int]{full, full})
+	packer.touch(0, 3)
+	packer.touch(1, 1)
+	page, pos, evicted, ok := packer.packEvicting(common.Vec2(8, 8))
+	if !ok || evicted != 1 || page != 1 || pos != common.Rect(0, 0, 8, 8) {
+		t.Errorf("image is packed to page %d at %v (ok %t) after evicting %d, expected page 1", page, pos, ok, evicted)
+	}
+	// Image is packed without eviction when there is space
+	if _, _, evicted, ok := packer.packEvicting(common.Vec2(8, 8)); !ok || evicted != -1 {
+		t.Errorf("page %d is evicted (ok %t) while there is space", evicted, ok)
+	}
+}
+
+func TestShelfPackerOversizeDoesntEvict(t *testing.T) {
+	packer := newShelfPacker(common.Vec2(16, 16), 2)
+	full := common.Vec2(16, 16)
+	packAll(t, packer, []common.Vector2[int]{full, full})
+	for _, size := range []common.Vector2[int]{common.Vec2(17, 8), common.Vec2(8, 17)} {
+		if _, _, evicted, ok := packer.packEvicting(size); ok || evicted != -1 {
+			t.Errorf("image with size %v is packed (ok %t) or evicted page %d", size, ok, evicted)
+		}
+	}
+	for i, page := range packer.pages {
+		if len(page.shelves) != 1 {
+			t.Errorf("page %d is cleared by an image bigger than the page", i)
+		}
+	}
+}
@@ -1,22 +1,114 @@
 #version 330 core
-layout(location = 0) out vec4 outFragColor;
+// Dual source blending, color and the opacity of every channel. Subpixel
+// antialiased text has a different opacity in every channel.
+layout(location = 0, index = 0) out vec4 outFragColor;
+layout(location = 0, index = 1) out vec4 outBlendFactor;
 
 in GS_OUT {
-	vec2 tex1pos;
-	vec2 tex2pos;
-	vec2 ucPos;
+	vec3 tex1pos;
+	vec3 tex2pos;
 	vec4 fgColor;
 	vec4 bgColor;
 	vec4 spColor;
+	vec2 colored;
+	vec2 cellPos;
+	flat int style;
 } fs_in;
 
-uniform sampler2D atlas;
+uniform sampler2DArray atlas;
+uniform vec3 decorations; // Underline and strikethrough positions and the thickness in pixels
+uniform vec4 undercurl;   // Center, amplitude, wavelength and thickness of the wave in pixels
+
+const float PI = 3.14159265;
+
+// Decoration flags, same with the opengl.Decoration
+const int UNDERLINE     = 1;
+const int UNDERLINELINE = 2;
+const int UNDERDOT      = 4;
+const int UNDERDASH     = 8;
+const int UNDERCURL     = 16;
+const int STRIKETHROUGH = 32;
+
+// Returns the coverage of the pixel row at y by the horizontal line
+float hline(float y, float center, float thickness) {
+	float top = center - thickness / 2.0;
+	return clamp(min(y + 0.5, top + thickness) - max(y - 0.5, top), 0.0, 1.0);
+}
+
+// Returns 1 if the pixel column is in the first on pixels of the period.
+// Window position is used, so the pattern continues across the cells.
+float pattern(float on, float period) {
+	return float(mod(floor(gl_FragCoord.x), period) < on);
+}
+
+// Returns the coverage of the pixel by the undercurl. The wave is calculated
+// from the window position, so it continues across the cells. Distance to the
+// wave is approximated from the vertical distance and the slope.
+float curl(float y) {
+	float k     = 2.0 * PI / undercurl.z;
+	float x     = gl_FragCoord.x * k;
+	float slope = undercurl.y * k * cos(x);
+	float dist  = abs(y - (undercurl.x + undercurl.y * sin(x))) / sqrt(1.0 + slope * slope);
+	return clamp(undercurl.w / 2.0 - dist + 0.5, 0.0, 1.0);
+}
+
+// Returns the alpha of the decorations at the cell position
+float decoration(int style, vec2 pos) {
+	float thickness = decorations.z;
+	float size      = max(round(thickness), 1.0);
+	float underline = hline(pos.y, decorations.x, thickness);
+	float alpha     = 0.0;
+	if ((style & UNDERLINE) != 0) {
+		alpha = max(alpha, underline);
+	}
+	if ((style & UNDERLINELINE) != 0) {
+		// Two lines with a gap as thick as the lines
+		alpha = max(alpha, hline(pos.y, decorations.x - thickness, thickness));
+		alpha = max(alpha, hline(pos.y, decorations.x + thickness, thickness));
+	}
+	if ((style & UNDERDOT) != 0) {
+		alpha = max(alpha, underline * pattern(size, size * 2.0));
+	}
+	if ((style & UNDERDASH) != 0) {
+		alpha = max(alpha, underline * pattern(size * 3.0, size * 5.0));
+	}
+	if ((style & UNDERCURL) != 0) {
+		alpha = max(alpha, curl(pos.y));
+	}
+	if ((style & STRIKETHROUGH) != 0) {
+		alpha = max(alpha, hline(pos.y, decorations.y, thickness));
+	}
+	return alpha;
+}
+
+// Returns the coverage of every color channel by the mask. Subpixel
+// antialiased glyphs have a different coverage in every channel, grayscale
+// ones have the alpha in all channels. Texture colors are used when fg.A < 1,
+// they only have the alpha coverage.
+vec3 coverage(vec4 tex) {
+	return mix(vec3(tex.a), tex.rgb, fs_in.fgColor.a);
+}
 
 void main() {
-	vec4 tex1    = texture(atlas, fs_in.tex1pos);
-	vec4 fg      = mix(tex1, fs_in.fgColor, fs_in.fgColor.a);           // Use texture color if fg.A < 1
-	float texA   = max(tex1.a, texture(atlas, fs_in.tex2pos).a);        // Use both of textures
-	float ucA    = min(texture(atlas, fs_in.ucPos).a, fs_in.spColor.a); // If sp.A == 0 we don't draw undercurl
-	vec4 result  = mix(fs_in.bgColor, fg, texA);                        // Draw foreground over background
-	outFragColor = mix(result, fs_in.spColor, ucA);                     // Draw special over result color
+	vec4 tex1   = texture(atlas, fs_in.tex1pos);
+	vec4 tex2   = texture(atlas, fs_in.tex2pos);
+	vec4 bg     = fs_in.bgColor;
+	vec4 fg     = mix(tex1, fs_in.fgColor, fs_in.fgColor.a);        // Use texture color if fg.A < 1
+	vec3 mask1  = coverage(tex1) * (1.0 - fs_in.colored.x);         // Color glyphs are not masks
+	vec3 mask2  = coverage(tex2) * (1.0 - fs_in.colored.y);
+	vec3 texC   = max(mask1, mask2);                                 // Use both of textures
+	float spA   = decoration(fs_in.style, fs_in.cellPos);            // Decorations are drawn with special
+	// Colors are premultiplied until the end, every channel has its own alpha
+	vec3 alpha  = texC + bg.a * (1.0 - texC);                        // Draw foreground over background
+	vec3 color  = fg.rgb * texC + bg.rgb * bg.a * (1.0 - texC);
+	color       = tex1.rgb * fs_in.colored.x + color * (1.0 - tex1.a * fs_in.colored.x); // Draw premultiplied color glyphs
+	alpha       = tex1.a * fs_in.colored.x + alpha * (1.0 - tex1.a * fs_in.colored.x);   // over the result
+	color       = tex2.rgb * fs_in.colored.y + color * (1.0 - tex2.a * fs_in.colored.y);
+	alpha       = tex2.a * fs_in.colored.y + alpha * (1.0 - tex2.a * fs_in.colored.y);
+	color       = mix(color, fs_in.spColor.rgb * fs_in.spColor.a, spA); // Draw special over result color
+	alpha       = mix(alpha, vec3(fs_in.spColor.a), spA);
+	float maxA  = max(alpha.r, max(alpha.g, alpha.b));
+	// Grids are rendered without blending, color must be straight
+	outFragColor   = vec4(color / max(alpha, vec3(1.0 / 255.0)), maxA);
+	outBlendFactor = vec4(alpha, maxA);
 }
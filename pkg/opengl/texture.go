@@ -12,61 +12,118 @@ import (
 
 var boundTextureId uint32
 
+// Textures are texture arrays, the shader samples every texture as an
+// array. Textures with one layer are used like 2D textures.
 type Texture struct {
 	id     uint32
 	width  int
 	height int
+	layers int
 	fbo    uint32 // this is like pointer to the framebuffer because framebuffer is in gpu memory
 }
 
 func (texture Texture) String() string {
-	return fmt.Sprintf("Texture(ID: %d, Width: %d, Height: %d)", texture.id, texture.width, texture.height)
+	return fmt.Sprintf("Texture(ID: %d, Width: %d, Height: %d, Layers: %d)", texture.id, texture.width, texture.height, texture.layers)
 }
 
 func (context *Context) CreateTexture(width, height int) Texture {
+	return context.CreateTextureArray(width, height, 1)
+}
+
+func (context *Context) CreateTextureArray(width, height, layers int) Texture {
 	texture := Texture{
-		fbo: context.framebuffer,
+		layers: layers,
+		fbo:    context.framebuffer,
 	}
+	texture.id = genTexture()
+	texture.Resize(width, height)
+	logger.Log(logger.DEBUG, "Texture created:", texture)
+	return texture
+}
+
+// Creates and binds a new texture object
+func genTexture() uint32 {
+	var id uint32
 	// NOTE: There can be multiple textures but only one can bind at a time
-	gl.GenTextures(1, &texture.id)
+	gl.GenTextures(1, &id)
 	checkGLError()
-	gl.BindTexture(gl.TEXTURE_2D, texture.id)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, id)
 	checkGLError()
-	boundTextureId = texture.id
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	boundTextureId = id
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	checkGLError()
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
 	checkGLError()
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
 	checkGLError()
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	checkGLError()
-	texture.Resize(width, height)
-	logger.Log(logger.DEBUG, "Texture created:", texture)
-	return texture
+	return id
 }
 
 func (texture *Texture) Size() common.Vector2[int] {
 	return common.Vec2(texture.width, texture.height)
 }
 
-// Texture must bound before resizing
+func (texture *Texture) Layers() int {
+	return texture.layers
+}
+
+// Texture must bound before resizing. Every layer is resized and the
+// contents are cleared.
 func (texture *Texture) Resize(width, height int) {
 	if boundTextureId != texture.id {
 		panic("texture must be bound before resize")
 	}
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(width), int32(height), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.RGBA8, int32(width), int32(height), int32(texture.layers), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
 	checkGLError()
 	texture.width = width
 	texture.height = height
 }
 
+// Changes the number of the layers, contents of the remaining layers are
+// kept. Texture must bound before. Texture object is recreated because the
+// layers of a texture can't be changed, the new one is bound.
+func (texture *Texture) SetLayers(layers int) {
+	if boundTextureId != texture.id {
+		panic("texture must be bound before changing layers")
+	}
+	if layers == texture.layers {
+		return
+	}
+	old := *texture
+	texture.id = genTexture()
+	texture.layers = layers
+	texture.Resize(old.width, old.height)
+	// Copy remaining layers from the old texture to the bound new one
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, texture.fbo)
+	checkGLError()
+	for layer := 0; layer < common.Min(old.layers, layers); layer++ {
+		gl.FramebufferTextureLayer(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, old.id, 0, int32(layer))
+		checkGLError()
+		gl.CopyTexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, 0, 0, int32(layer), 0, 0, int32(old.width), int32(old.height))
+		checkGLError()
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+	checkGLError()
+	gl.DeleteTextures(1, &old.id)
+	checkGLError()
+	logger.Log(logger.DEBUG, "Texture layers changed:", texture)
+}
+
+// Clears every layer of the texture.
 func (texture *Texture) Clear() {
+	for layer := 0; layer < texture.layers; layer++ {
+		texture.ClearLayer(layer)
+	}
+}
+
+func (texture *Texture) ClearLayer(layer int) {
 	// Bind framebuffer
 	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, texture.fbo)
 	checkGLError()
-	// Init framebuffer with texture
-	gl.FramebufferTexture2D(gl.DRAW_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, texture.id, 0)
+	// Init framebuffer with the layer of the texture
+	gl.FramebufferTextureLayer(gl.DRAW_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, texture.id, 0, int32(layer))
 	checkGLError()
 	// Check if the framebuffer is complete and ready for draw
 	fbo_status := gl.CheckFramebufferStatus(gl.DRAW_FRAMEBUFFER)
@@ -88,21 +145,24 @@ func (texture *Texture) Bind() {
 	if boundTextureId == texture.id {
 		return
 	}
-	gl.BindTexture(gl.TEXTURE_2D, texture.id)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, texture.id)
 	checkGLError()
 	boundTextureId = texture.id
 }
 
 // Texture must bound before drawing
-func (texture *Texture) Draw(image *image.RGBA, dest common.Rectangle[int]) {
+func (texture *Texture) Draw(image *image.RGBA, layer int, dest common.Rectangle[int]) {
 	if boundTextureId != texture.id {
-		panic("texture must be bound before resize")
+		panic("texture must be bound before drawing")
 	}
-	gl.TexSubImage2D(gl.TEXTURE_2D, 0, int32(dest.X), int32(dest.Y), int32(dest.W), int32(dest.H), gl.RGBA, gl.UNSIGNED_BYTE, unsafe.Pointer(&image.Pix[0]))
+	gl.TexSubImage3D(gl.TEXTURE_2D_ARRAY, 0, int32(dest.X), int32(dest.Y), int32(layer), int32(dest.W), int32(dest.H), 1, gl.RGBA, gl.UNSIGNED_BYTE, unsafe.Pointer(&image.Pix[0]))
 	checkGLError()
 }
 
-// Converts coordinates to opengl understandable coordinates, 0 to 1
+// Converts coordinates to opengl understandable coordinates, 0 to 1. Layers
+// are stacked vertically, Y of the position may continue through the
+// layers. Integer part of the normalized Y is the layer, the geometry shader
+// splits it.
 func (texture *Texture) Normalize(pos common.Rectangle[int]) common.Rectangle[float32] {
 	return common.Rectangle[float32]{
 		X: float32(pos.X) / float32(texture.width),
@@ -117,6 +177,7 @@ func (texture *Texture) Delete() {
 	texture.id = 0
 	texture.width = 0
 	texture.height = 0
+	texture.layers = 0
 	texture.fbo = 0
 	logger.Log(logger.DEBUG, "Texture deleted:", texture)
 }
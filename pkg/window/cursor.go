@@ -0,0 +1,125 @@
+package window
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/hismailbulut/Neoray/pkg/common"
+)
+
+type CursorShape uint32
+
+const (
+	CursorShapeDefault CursorShape = iota // Platform default, same as arrow on most platforms
+	CursorShapeArrow
+	CursorShapeIBeam
+	CursorShapeCrosshair
+	CursorShapeHand
+	CursorShapeHResize
+	CursorShapeVResize
+	CursorShapeBusy
+)
+
+func (shape CursorShape) String() string {
+	switch shape {
+	case CursorShapeDefault:
+		return "CursorShapeDefault"
+	case CursorShapeArrow:
+		return "CursorShapeArrow"
+	case CursorShapeIBeam:
+		return "CursorShapeIBeam"
+	case CursorShapeCrosshair:
+		return "CursorShapeCrosshair"
+	case CursorShapeHand:
+		return "CursorShapeHand"
+	case CursorShapeHResize:
+		return "CursorShapeHResize"
+	case CursorShapeVResize:
+		return "CursorShapeVResize"
+	case CursorShapeBusy:
+		return "CursorShapeBusy"
+	default:
+		panic("unknown cursor shape")
+	}
+}
+
+// Sets the shape of the mouse cursor when it is over the window.
+// Cursors are created once and cached until the window is destroyed.
+func (window *Window) SetCursorShape(shape CursorShape) {
+	if shape == window.cursorShape {
+		return
+	}
+	window.cursorShape = shape
+	if shape == CursorShapeDefault {
+		window.handle.SetCursor(nil)
+		return
+	}
+	cursor, ok := window.cursors[shape]
+	if !ok {
+		cursor = createCursor(shape)
+		window.cursors[shape] = cursor
+	}
+	window.handle.SetCursor(cursor)
+}
+
+func (window *Window) CursorShape() CursorShape {
+	return window.cursorShape
+}
+
+func (window *Window) destroyCursors() {
+	window.handle.SetCursor(nil)
+	for shape, cursor := range window.cursors {
+		cursor.Destroy()
+		delete(window.cursors, shape)
+	}
+}
+
+func createCursor(shape CursorShape) *glfw.Cursor {
+	switch shape {
+	case CursorShapeArrow:
+		return glfw.CreateStandardCursor(glfw.ArrowCursor)
+	case CursorShapeIBeam:
+		return glfw.CreateStandardCursor(glfw.IBeamCursor)
+	case CursorShapeCrosshair:
+		return glfw.CreateStandardCursor(glfw.CrosshairCursor)
+	case CursorShapeHand:
+		return glfw.CreateStandardCursor(glfw.HandCursor)
+	case CursorShapeHResize:
+		return glfw.CreateStandardCursor(glfw.HResizeCursor)
+	case CursorShapeVResize:
+		return glfw.CreateStandardCursor(glfw.VResizeCursor)
+	case CursorShapeBusy:
+		// Glfw doesn't have a standard busy cursor
+		img := busyCursorImage()
+		return glfw.CreateCursor(img, img.Bounds().Dx()/2, img.Bounds().Dy()/2)
+	default:
+		panic("unknown cursor shape")
+	}
+}
+
+// Draws a small black outlined hourglass
+func busyCursorImage() image.Image {
+	const size = 16
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	black := color.NRGBA{A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	// Top and bottom bars
+	for x := 2; x < size-2; x++ {
+		img.SetNRGBA(x, 1, black)
+		img.SetNRGBA(x, size-2, black)
+	}
+	// Glass, narrows towards the middle
+	for y := 2; y < size-2; y++ {
+		t := common.Min(common.Min(y-2, size-3-y), 4)
+		left, right := 3+t, size-4-t
+		for x := left; x <= right; x++ {
+			if x == left || x == right {
+				img.SetNRGBA(x, y, black)
+			} else {
+				img.SetNRGBA(x, y, white)
+			}
+		}
+	}
+	return img
+}
@@ -17,6 +17,10 @@ type Window struct {
 	dims         common.Rectangle[int]   // window dimensions used for restoring window from fullscreen
 	events       WindowEventStack        // Cached event stack
 	eventHandler func(event WindowEvent) // Event handler function will be called for every event at PollEvents call
+	title        string                  // Title of the window
+	iconTitle    string                  // Title used when the window is minimized, empty means same with title
+	cursorShape  CursorShape             // Current mouse cursor shape
+	cursors      map[CursorShape]*glfw.Cursor
 }
 
 // New creates a window and initializes an opengl context for it
@@ -28,6 +32,8 @@ func New(title string, width, height int, debugContext bool) (*Window, error) {
 	}
 
 	window := new(Window)
+	window.title = title
+	window.cursors = make(map[CursorShape]*glfw.Cursor)
 
 	// Set opengl library version
 	// TODO: make it 2.1 (needs some research)
@@ -99,6 +105,10 @@ func New(title string, width, height int, debugContext bool) (*Window, error) {
 		window.events.Push(WindowEventDrop, names)
 	})
 
+	window.handle.SetIconifyCallback(func(w *glfw.Window, iconified bool) {
+		window.updateTitle()
+	})
+
 	window.handle.SetContentScaleCallback(func(w *glfw.Window, x, y float32) {
 		window.events.Push(WindowEventScaleChanged)
 	})
@@ -116,16 +126,45 @@ func (window *Window) KeepAlive() {
 	window.handle.SetShouldClose(false)
 }
 
+// Makes the opengl context of this window current. Only required when there
+// are multiple windows, all opengl calls are made to the current context.
+func (window *Window) MakeContextCurrent() {
+	if glfw.GetCurrentContext() == window.handle {
+		return
+	}
+	window.handle.MakeContextCurrent()
+	window.context.MakeCurrent()
+}
+
 func (window *Window) Show() {
 	window.handle.Show()
 }
 
+func (window *Window) Hide() {
+	window.handle.Hide()
+}
+
 func (window *Window) IsVisible() bool {
 	return window.handle.GetAttrib(glfw.Visible) == glfw.True
 }
 
 func (window *Window) SetTitle(title string) {
-	window.handle.SetTitle(title)
+	window.title = title
+	window.updateTitle()
+}
+
+// Icon title is the title shown while the window is minimized
+func (window *Window) SetIconTitle(iconTitle string) {
+	window.iconTitle = iconTitle
+	window.updateTitle()
+}
+
+func (window *Window) updateTitle() {
+	if window.iconTitle != "" && window.IsMinimized() {
+		window.handle.SetTitle(window.iconTitle)
+	} else {
+		window.handle.SetTitle(window.title)
+	}
 }
 
 func (window *Window) Move(pos common.Vector2[int]) {
@@ -174,6 +213,16 @@ func (window *Window) Raise() {
 	window.handle.Focus()
 }
 
+func (window *Window) IsFocused() bool {
+	return window.handle.GetAttrib(glfw.Focused) == glfw.True
+}
+
+// Requests user attention to the window, eg. flashes the taskbar entry.
+// Does nothing if the window is already focused.
+func (window *Window) RequestAttention() {
+	window.handle.RequestAttention()
+}
+
 func (window *Window) Minimize() {
 	window.handle.Iconify()
 }
@@ -232,6 +281,7 @@ func (window *Window) DPI() float64 {
 }
 
 func (window *Window) Destroy() {
+	window.destroyCursors()
 	window.context.Destroy()
 	window.handle.Destroy()
 }